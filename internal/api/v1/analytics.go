@@ -0,0 +1,181 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/divinecoid/oneagent/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type AnalyticsHandler struct {
+	analyticsService *service.SearchAnalyticsService
+}
+
+func NewAnalyticsHandler(analyticsService *service.SearchAnalyticsService) *AnalyticsHandler {
+	return &AnalyticsHandler{analyticsService: analyticsService}
+}
+
+// logSearch records a search or chat retrieval for analytics. It runs in the
+// background so logging never slows down or fails the customer's request.
+func logSearch(entry model.SearchLog, started time.Time, results []SearchResult) {
+	entry.LatencyMs = int(time.Since(started).Milliseconds())
+	entry.ResultIDs = make([]int64, len(results))
+	for i, r := range results {
+		entry.ResultIDs[i] = r.ID
+		// Text-only retrieval leaves similarity at 0; only log real vector scores
+		if r.Similarity > 0 && (entry.TopSimilarity == nil || r.Similarity > *entry.TopSimilarity) {
+			similarity := r.Similarity
+			entry.TopSimilarity = &similarity
+		}
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := service.NewSearchAnalyticsService().LogSearch(ctx, &entry); err != nil {
+			fmt.Printf("Search analytics: %v\n", err)
+		}
+	}()
+}
+
+// reportParams reads the parameters shared by every report: the seller (the
+// caller, or ?seller_id= for super admins), the ?days= window and ?limit=.
+// It writes the error response itself.
+func reportParams(c *gin.Context) (sellerID int64, since time.Time, limit int, ok bool) {
+	sellerID = c.MustGet("user_id").(int64)
+	if raw := c.Query("seller_id"); raw != "" && model.Role(c.GetString("user_role")) == model.RoleSuperAdmin {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, APIResponse{
+				Success: false,
+				Message: "Invalid seller ID",
+				Errors:  gin.H{"error": "seller_id must be a number"},
+				Meta: MetaData{
+					RequestID: c.GetHeader("X-Request-ID"),
+					Timestamp: time.Now().UTC().Format(time.RFC3339),
+				},
+			})
+			return 0, time.Time{}, 0, false
+		}
+		sellerID = id
+	}
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days <= 0 || days > 365 {
+		days = 30
+	}
+	limit, err = strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 200 {
+		limit = 20
+	}
+	return sellerID, time.Now().AddDate(0, 0, -days), limit, true
+}
+
+func (h *AnalyticsHandler) TopQueries(c *gin.Context) {
+	sellerID, since, limit, ok := reportParams(c)
+	if !ok {
+		return
+	}
+
+	stats, err := h.analyticsService.TopQueries(c.Request.Context(), sellerID, since, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Message: "Failed to get top queries",
+			Errors:  gin.H{"error": err.Error()},
+			Meta: MetaData{
+				RequestID: c.GetHeader("X-Request-ID"),
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Top queries retrieved successfully",
+		Data:    gin.H{"queries": stats},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// PoorResultQueries reports queries with at most ?max_results= results
+// (default 0, i.e. zero-result queries), or with a best match below
+// ?min_similarity=.
+func (h *AnalyticsHandler) PoorResultQueries(c *gin.Context) {
+	sellerID, since, limit, ok := reportParams(c)
+	if !ok {
+		return
+	}
+	maxResults, err := strconv.Atoi(c.DefaultQuery("max_results", "0"))
+	if err != nil || maxResults < 0 {
+		maxResults = 0
+	}
+	minSimilarity, err := strconv.ParseFloat(c.DefaultQuery("min_similarity", "0"), 64)
+	if err != nil || minSimilarity < 0 || minSimilarity > 1 {
+		minSimilarity = 0
+	}
+
+	stats, err := h.analyticsService.PoorResultQueries(c.Request.Context(), sellerID, since, maxResults, minSimilarity, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Message: "Failed to get low-result queries",
+			Errors:  gin.H{"error": err.Error()},
+			Meta: MetaData{
+				RequestID: c.GetHeader("X-Request-ID"),
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Low-result queries retrieved successfully",
+		Data:    gin.H{"queries": stats},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+func (h *AnalyticsHandler) UnretrievedProducts(c *gin.Context) {
+	sellerID, since, limit, ok := reportParams(c)
+	if !ok {
+		return
+	}
+
+	products, err := h.analyticsService.UnretrievedProducts(c.Request.Context(), sellerID, since, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Message: "Failed to get unretrieved products",
+			Errors:  gin.H{"error": err.Error()},
+			Meta: MetaData{
+				RequestID: c.GetHeader("X-Request-ID"),
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Unretrieved products retrieved successfully",
+		Data:    gin.H{"products": products},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
@@ -0,0 +1,230 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/divinecoid/oneagent/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// AvailabilityHandler serves menu schedules. It loads products and
+// categories through their own handlers so ownership is checked the same way.
+type AvailabilityHandler struct {
+	availabilityService *service.AvailabilityService
+	products            *ProductHandler
+	categories          *CategoryHandler
+}
+
+func NewAvailabilityHandler(availabilityService *service.AvailabilityService, products *ProductHandler, categories *CategoryHandler) *AvailabilityHandler {
+	return &AvailabilityHandler{availabilityService: availabilityService, products: products, categories: categories}
+}
+
+// ScheduleRequest is the body of PUT /products/:id/schedule and
+// PUT /categories/:id/schedule. It replaces every window; an empty schedule
+// puts the item on the menu at all times, or back on its category's schedule.
+type ScheduleRequest struct {
+	Schedule []model.AvailabilityWindow `json:"schedule"`
+}
+
+// scheduleError writes the response for a failed schedule operation
+func scheduleError(c *gin.Context, err error, message string) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrInvalidSchedule):
+		status, message = http.StatusBadRequest, "Invalid schedule"
+	case errors.Is(err, errInvalidCursor):
+		status, message = http.StatusBadRequest, "Invalid request parameters"
+	}
+	c.JSON(status, APIResponse{
+		Success: false,
+		Message: message,
+		Errors:  gin.H{"error": err.Error()},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// scheduleResponse writes a schedule along with the time zone it is in
+func (h *AvailabilityHandler) scheduleResponse(c *gin.Context, status int, message string, sellerID int64, data gin.H) {
+	loc, err := h.availabilityService.SellerLocation(c.Request.Context(), sellerID)
+	if err != nil {
+		scheduleError(c, err, "Failed to get schedule")
+		return
+	}
+	data["timezone"] = loc.String()
+	c.JSON(status, APIResponse{
+		Success: true,
+		Message: message,
+		Data:    data,
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// GetProductSchedule returns the windows set on a product
+func (h *AvailabilityHandler) GetProductSchedule(c *gin.Context) {
+	product, ok := h.products.loadProduct(c)
+	if !ok {
+		return
+	}
+	schedule, err := h.availabilityService.ProductSchedule(c.Request.Context(), product.ID)
+	if err != nil {
+		scheduleError(c, err, "Failed to get schedule")
+		return
+	}
+	h.scheduleResponse(c, http.StatusOK, "Schedule retrieved successfully", product.SellerID,
+		gin.H{"product_id": product.ID, "schedule": schedule})
+}
+
+// SetProductSchedule replaces the windows of a product
+func (h *AvailabilityHandler) SetProductSchedule(c *gin.Context) {
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		productValidationError(c, err.Error())
+		return
+	}
+	product, ok := h.products.loadProduct(c)
+	if !ok {
+		return
+	}
+	if req.Schedule == nil {
+		req.Schedule = []model.AvailabilityWindow{}
+	}
+	if err := h.availabilityService.SetProductSchedule(c.Request.Context(), product.SellerID, product.ID, req.Schedule); err != nil {
+		scheduleError(c, err, "Failed to update schedule")
+		return
+	}
+	h.scheduleResponse(c, http.StatusOK, "Schedule updated successfully", product.SellerID,
+		gin.H{"product_id": product.ID, "schedule": req.Schedule})
+}
+
+// GetCategorySchedule returns the windows set on a category
+func (h *AvailabilityHandler) GetCategorySchedule(c *gin.Context) {
+	category, ok := h.categories.loadCategory(c, true)
+	if !ok {
+		return
+	}
+	schedule, err := h.availabilityService.CategorySchedule(c.Request.Context(), category.ID)
+	if err != nil {
+		scheduleError(c, err, "Failed to get schedule")
+		return
+	}
+	h.scheduleResponse(c, http.StatusOK, "Schedule retrieved successfully", category.SellerID,
+		gin.H{"category_id": category.ID, "schedule": schedule})
+}
+
+// SetCategorySchedule replaces the windows of a category
+func (h *AvailabilityHandler) SetCategorySchedule(c *gin.Context) {
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		productValidationError(c, err.Error())
+		return
+	}
+	category, ok := h.categories.loadCategory(c, true)
+	if !ok {
+		return
+	}
+	if req.Schedule == nil {
+		req.Schedule = []model.AvailabilityWindow{}
+	}
+	if err := h.availabilityService.SetCategorySchedule(c.Request.Context(), category.SellerID, category.ID, req.Schedule); err != nil {
+		scheduleError(c, err, "Failed to update schedule")
+		return
+	}
+	h.scheduleResponse(c, http.StatusOK, "Schedule updated successfully", category.SellerID,
+		gin.H{"category_id": category.ID, "schedule": req.Schedule})
+}
+
+// PreviewMenu lists the products on a seller's menu at a moment. Optional
+// query parameters: seller_id, at (RFC 3339, or "2006-01-02T15:04" in the
+// seller's time zone; defaults to now), in_stock, limit and cursor.
+func (h *AvailabilityHandler) PreviewMenu(c *gin.Context) {
+	var fallback *int64
+	if raw := c.Query("seller_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			productValidationError(c, "seller_id must be a number")
+			return
+		}
+		fallback = &id
+	}
+	sellerID, ok := searchScope(c, fallback, false)
+	if !ok {
+		return
+	}
+	loc, err := h.availabilityService.SellerLocation(c.Request.Context(), *sellerID)
+	if err != nil {
+		scheduleError(c, err, "Failed to preview menu")
+		return
+	}
+
+	at := time.Now()
+	if raw := c.Query("at"); raw != "" {
+		if at, err = time.Parse(time.RFC3339, raw); err != nil {
+			if at, err = time.ParseInLocation("2006-01-02T15:04", raw, loc); err != nil {
+				productValidationError(c, "at must be an RFC 3339 time or YYYY-MM-DDTHH:MM in the store's time zone")
+				return
+			}
+		}
+	}
+
+	available := true
+	q := productQuery{
+		SellerID: sellerID,
+		Filters:  SearchFilters{Available: &available, AvailableAt: &at},
+		Limit:    50,
+		At:       &at,
+	}
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > 100 {
+			productValidationError(c, "limit must be between 1 and 100")
+			return
+		}
+		q.Limit = limit
+	}
+	if q.Cursor = c.Query("cursor"); q.Cursor != "" {
+		if _, err := decodeSearchCursor(q.Cursor); err != nil {
+			productValidationError(c, "invalid cursor")
+			return
+		}
+	}
+	if raw := c.Query("in_stock"); raw != "" {
+		inStock, err := strconv.ParseBool(raw)
+		if err != nil {
+			productValidationError(c, "in_stock must be true or false")
+			return
+		}
+		q.Filters.InStock = &inStock
+	}
+
+	page, err := searchProducts(c.Request.Context(), q)
+	if err != nil {
+		scheduleError(c, err, "Failed to preview menu")
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Menu retrieved successfully",
+		Data: gin.H{
+			"seller_id":   *sellerID,
+			"timezone":    loc.String(),
+			"at":          at.In(loc).Format(time.RFC3339),
+			"products":    page.Results,
+			"total":       page.Total,
+			"next_cursor": page.NextCursor,
+		},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
@@ -0,0 +1,169 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/divinecoid/oneagent/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type CartHandler struct {
+	inventoryService *service.InventoryService
+}
+
+func NewCartHandler(inventoryService *service.InventoryService) *CartHandler {
+	return &CartHandler{inventoryService: inventoryService}
+}
+
+// CartItemRequest is the body of POST /cart/items
+type CartItemRequest struct {
+	ProductID int64  `json:"product_id" binding:"required"`
+	VariantID *int64 `json:"variant_id"`
+	Qty       int    `json:"qty" binding:"required,min=1,max=1000"`
+}
+
+// CartQtyRequest is the body of PATCH /cart/items/:id
+type CartQtyRequest struct {
+	Qty int `json:"qty" binding:"required,min=1,max=1000"`
+}
+
+// cartError writes the response for a failed cart operation
+func cartError(c *gin.Context, err error, message string) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrProductNotFound):
+		status, message = http.StatusNotFound, "Product not found"
+	case errors.Is(err, service.ErrVariantNotFound):
+		status, message = http.StatusNotFound, "Variant not found"
+	case errors.Is(err, service.ErrCartItemNotFound):
+		status, message = http.StatusNotFound, "Cart item not found"
+	case errors.Is(err, service.ErrVariantRequired):
+		status, message = http.StatusBadRequest, "Choose a variant"
+	case errors.Is(err, service.ErrCartEmpty):
+		status, message = http.StatusBadRequest, "Cart is empty"
+	case errors.Is(err, service.ErrProductUnavailable):
+		status, message = http.StatusConflict, "Product is not available"
+	case errors.Is(err, service.ErrInsufficientStock):
+		status, message = http.StatusConflict, "Not enough stock"
+	}
+	c.JSON(status, APIResponse{
+		Success: false,
+		Message: message,
+		Errors:  gin.H{"error": err.Error()},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// cartItemID parses the :id parameter. It writes the error response itself.
+func cartItemID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "Invalid cart item ID",
+			Errors:  gin.H{"error": "cart item ID must be a number"},
+			Meta: MetaData{
+				RequestID: c.GetHeader("X-Request-ID"),
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+			},
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// respondCart writes the caller's current cart
+func (h *CartHandler) respondCart(c *gin.Context, status int, message string) {
+	cart, err := h.inventoryService.GetCart(c.Request.Context(), c.MustGet("user_id").(int64))
+	if err != nil {
+		cartError(c, err, "Failed to get cart")
+		return
+	}
+	c.JSON(status, APIResponse{
+		Success: true,
+		Message: message,
+		Data:    cart,
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// GetCart returns the caller's cart
+func (h *CartHandler) GetCart(c *gin.Context) {
+	h.respondCart(c, http.StatusOK, "Cart retrieved successfully")
+}
+
+// AddCartItem puts a product in the caller's cart and reserves its stock for
+// service.CartReservationTTL. Adding a product that is already in the cart
+// increases its quantity.
+func (h *CartHandler) AddCartItem(c *gin.Context) {
+	var req CartItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		productValidationError(c, err.Error())
+		return
+	}
+	err := h.inventoryService.AddToCart(c.Request.Context(), c.MustGet("user_id").(int64), req.ProductID, req.VariantID, req.Qty)
+	if err != nil {
+		cartError(c, err, "Failed to add to cart")
+		return
+	}
+	h.respondCart(c, http.StatusOK, "Item added to cart")
+}
+
+// UpdateCartItem changes the quantity of a cart item and renews its reservation
+func (h *CartHandler) UpdateCartItem(c *gin.Context) {
+	id, ok := cartItemID(c)
+	if !ok {
+		return
+	}
+	var req CartQtyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		productValidationError(c, err.Error())
+		return
+	}
+	if err := h.inventoryService.UpdateCartItem(c.Request.Context(), c.MustGet("user_id").(int64), id, req.Qty); err != nil {
+		cartError(c, err, "Failed to update cart")
+		return
+	}
+	h.respondCart(c, http.StatusOK, "Cart item updated")
+}
+
+// RemoveCartItem takes an item out of the caller's cart, releasing its stock
+func (h *CartHandler) RemoveCartItem(c *gin.Context) {
+	id, ok := cartItemID(c)
+	if !ok {
+		return
+	}
+	if err := h.inventoryService.RemoveCartItem(c.Request.Context(), c.MustGet("user_id").(int64), id); err != nil {
+		cartError(c, err, "Failed to remove cart item")
+		return
+	}
+	h.respondCart(c, http.StatusOK, "Cart item removed")
+}
+
+// Checkout sells the caller's cart, deducting the stock, and returns what
+// was sold
+func (h *CartHandler) Checkout(c *gin.Context) {
+	cart, err := h.inventoryService.Checkout(c.Request.Context(), c.MustGet("user_id").(int64))
+	if err != nil {
+		cartError(c, err, "Failed to check out")
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Checkout completed successfully",
+		Data:    cart,
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
@@ -0,0 +1,301 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/divinecoid/oneagent/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type CategoryHandler struct {
+	categoryService *service.CategoryService
+}
+
+func NewCategoryHandler(categoryService *service.CategoryService) *CategoryHandler {
+	return &CategoryHandler{categoryService: categoryService}
+}
+
+// CategoryRequest is the body of POST /categories. On PATCH /categories/:id
+// only the fields sent are changed; a parent_id of 0 moves the category to
+// the top level.
+type CategoryRequest struct {
+	SellerID int64     `json:"seller_id"` // Super admins only; sellers manage their own
+	Name     *string   `json:"name" binding:"omitempty,min=1,max=100"`
+	Slug     *string   `json:"slug" binding:"omitempty,max=100"`
+	Aliases  *[]string `json:"aliases"`
+	ParentID *int64    `json:"parent_id"`
+	Position *int      `json:"position"`
+}
+
+// categoryError writes the response for a failed category operation
+func categoryError(c *gin.Context, err error, message string) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrCategoryNotFound):
+		status, message = http.StatusNotFound, "Category not found"
+	case errors.Is(err, service.ErrDuplicateCategory):
+		status, message = http.StatusConflict, "Category slug already in use"
+	case errors.Is(err, service.ErrCategoryHasChildren):
+		status, message = http.StatusConflict, "Category has subcategories"
+	case errors.Is(err, service.ErrCategoryCycle):
+		status, message = http.StatusBadRequest, "Invalid parent category"
+	case errors.Is(err, errInvalidCursor):
+		status, message = http.StatusBadRequest, "Invalid request parameters"
+	}
+	c.JSON(status, APIResponse{
+		Success: false,
+		Message: message,
+		Errors:  gin.H{"error": err.Error()},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// loadCategory parses the :id parameter and returns the category. Unless
+// manage is false, other sellers' categories are reported as not found to
+// everyone but super admins. It writes the error response itself.
+func (h *CategoryHandler) loadCategory(c *gin.Context, manage bool) (*model.Category, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		productValidationError(c, "category ID must be a number")
+		return nil, false
+	}
+	category, err := h.categoryService.GetCategory(c.Request.Context(), id)
+	if err == nil && manage && model.Role(c.GetString("user_role")) != model.RoleSuperAdmin &&
+		category.SellerID != c.MustGet("user_id").(int64) {
+		err = service.ErrCategoryNotFound
+	}
+	if err != nil {
+		categoryError(c, err, "Failed to get category")
+		return nil, false
+	}
+	return category, true
+}
+
+// applyCategoryRequest copies the fields sent in req onto category
+func applyCategoryRequest(category *model.Category, req CategoryRequest) {
+	if req.Name != nil {
+		category.Name = strings.Join(strings.Fields(*req.Name), " ")
+	}
+	if req.Slug != nil {
+		category.Slug = service.Slugify(*req.Slug)
+	}
+	if req.Aliases != nil {
+		category.Aliases = *req.Aliases
+	}
+	if req.ParentID != nil {
+		category.ParentID = req.ParentID
+		if *req.ParentID == 0 {
+			category.ParentID = nil
+		}
+	}
+	if req.Position != nil {
+		category.Position = *req.Position
+	}
+}
+
+// ListCategories returns a seller's category tree with the number of
+// products directly in each category. Customers pass the storefront seller
+// as X-Seller-ID or ?seller_id=.
+func (h *CategoryHandler) ListCategories(c *gin.Context) {
+	var fallback *int64
+	if raw := c.Query("seller_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			productValidationError(c, "seller_id must be a number")
+			return
+		}
+		fallback = &id
+	}
+	sellerID, ok := searchScope(c, fallback, false)
+	if !ok {
+		return
+	}
+
+	tree, err := h.categoryService.CategoryTree(c.Request.Context(), *sellerID)
+	if err != nil {
+		categoryError(c, err, "Failed to list categories")
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Categories retrieved successfully",
+		Data:    gin.H{"seller_id": *sellerID, "categories": tree},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// CategoryProducts browses the products of a category and its
+// subcategories. It takes limit, sort, cursor, available and in_stock
+// query parameters, paging like POST /products/search.
+func (h *CategoryHandler) CategoryProducts(c *gin.Context) {
+	category, ok := h.loadCategory(c, false)
+	if !ok {
+		return
+	}
+	sellerID, ok := searchScope(c, &category.SellerID, false)
+	if !ok {
+		return
+	}
+	if *sellerID != category.SellerID {
+		categoryError(c, service.ErrCategoryNotFound, "Failed to get category")
+		return
+	}
+
+	q := productQuery{SellerID: sellerID, Filters: SearchFilters{CategoryID: &category.ID}, Limit: 20}
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > 100 {
+			productValidationError(c, "limit must be between 1 and 100")
+			return
+		}
+		q.Limit = limit
+	}
+	switch q.Sort = c.Query("sort"); q.Sort {
+	case "", sortRelevance, "price_asc", "price_desc", "newest":
+	default:
+		productValidationError(c, "sort must be one of relevance, price_asc, price_desc, newest")
+		return
+	}
+	if q.Cursor = c.Query("cursor"); q.Cursor != "" {
+		if _, err := decodeSearchCursor(q.Cursor); err != nil {
+			productValidationError(c, "invalid cursor")
+			return
+		}
+	}
+	for name, target := range map[string]**bool{"available": &q.Filters.Available, "in_stock": &q.Filters.InStock} {
+		if raw := c.Query(name); raw != "" {
+			value, err := strconv.ParseBool(raw)
+			if err != nil {
+				productValidationError(c, name+" must be true or false")
+				return
+			}
+			*target = &value
+		}
+	}
+
+	page, err := searchProducts(c.Request.Context(), q)
+	if err != nil {
+		categoryError(c, err, "Failed to browse category")
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Products retrieved successfully",
+		Data: gin.H{
+			"category":    category,
+			"products":    page.Results,
+			"total":       page.Total,
+			"next_cursor": page.NextCursor,
+		},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// CreateCategory adds a category to the caller's tree, or to seller_id's
+// for super admins
+func (h *CategoryHandler) CreateCategory(c *gin.Context) {
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		productValidationError(c, err.Error())
+		return
+	}
+	if req.Name == nil || strings.TrimSpace(*req.Name) == "" {
+		productValidationError(c, "name is required")
+		return
+	}
+	sellerID, ok := productSeller(c, req.SellerID)
+	if !ok {
+		return
+	}
+
+	category := &model.Category{SellerID: sellerID}
+	applyCategoryRequest(category, req)
+	if err := h.categoryService.CreateCategory(c.Request.Context(), category); err != nil {
+		categoryError(c, err, "Failed to create category")
+		return
+	}
+	c.JSON(http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Category created successfully",
+		Data:    category,
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// UpdateCategory renames, moves or reorders a category. A rename is copied
+// to the category's products.
+func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		productValidationError(c, err.Error())
+		return
+	}
+	category, ok := h.loadCategory(c, true)
+	if !ok {
+		return
+	}
+
+	applyCategoryRequest(category, req)
+	if category.Name == "" {
+		productValidationError(c, "name can't be empty")
+		return
+	}
+	if category.Slug == "" {
+		category.Slug = service.Slugify(category.Name)
+	}
+	if category.Slug == "" {
+		productValidationError(c, "slug must contain letters or digits")
+		return
+	}
+	if err := h.categoryService.UpdateCategory(c.Request.Context(), category); err != nil {
+		categoryError(c, err, "Failed to update category")
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Category updated successfully",
+		Data:    category,
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// DeleteCategory removes a category that has no subcategories. Its products
+// keep their category text.
+func (h *CategoryHandler) DeleteCategory(c *gin.Context) {
+	category, ok := h.loadCategory(c, true)
+	if !ok {
+		return
+	}
+	if err := h.categoryService.DeleteCategory(c.Request.Context(), category.ID); err != nil {
+		categoryError(c, err, "Failed to delete category")
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Category deleted successfully",
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
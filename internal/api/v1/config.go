@@ -30,6 +30,16 @@ type CreateConfigRequest struct {
 	WhatsappTokenExpires  time.Time `json:"whatsapp_token_expires,omitempty"`
 	OpenAIModel           string    `json:"openai_model,omitempty"`
 	OpenAIEmbeddingModel  string    `json:"openai_embedding_model,omitempty"`
+	SearchMode            string    `json:"search_mode,omitempty" binding:"omitempty,oneof=vector text hybrid"`
+	Reranker              string    `json:"reranker,omitempty" binding:"omitempty,oneof=none lexical llm"`
+	RerankCandidates      int       `json:"rerank_candidates,omitempty" binding:"omitempty,min=1,max=100"`
+	SimilarityThreshold   *float64  `json:"similarity_threshold,omitempty" binding:"omitempty,min=0,max=1"`
+	TopK                  int       `json:"top_k,omitempty" binding:"omitempty,min=1,max=50"`
+	FallbackMode          string    `json:"fallback_mode,omitempty" binding:"omitempty,oneof=none broader"`
+	FallbackTopK          int       `json:"fallback_top_k,omitempty" binding:"omitempty,min=1,max=50"`
+	MaxContextChars       int       `json:"max_context_chars,omitempty" binding:"omitempty,min=500,max=100000"`
+	IncludePrices         *bool     `json:"include_prices,omitempty"`
+	Timezone              string    `json:"timezone,omitempty" binding:"omitempty,timezone"`
 }
 
 type UpdateConfigRequest struct {
@@ -44,6 +54,16 @@ type UpdateConfigRequest struct {
 	WhatsappTokenExpires  time.Time `json:"whatsapp_token_expires,omitempty"`
 	OpenAIModel           string    `json:"openai_model,omitempty"`
 	OpenAIEmbeddingModel  string    `json:"openai_embedding_model,omitempty"`
+	SearchMode            string    `json:"search_mode,omitempty" binding:"omitempty,oneof=vector text hybrid"`
+	Reranker              string    `json:"reranker,omitempty" binding:"omitempty,oneof=none lexical llm"`
+	RerankCandidates      int       `json:"rerank_candidates,omitempty" binding:"omitempty,min=1,max=100"`
+	SimilarityThreshold   *float64  `json:"similarity_threshold,omitempty" binding:"omitempty,min=0,max=1"`
+	TopK                  int       `json:"top_k,omitempty" binding:"omitempty,min=1,max=50"`
+	FallbackMode          string    `json:"fallback_mode,omitempty" binding:"omitempty,oneof=none broader"`
+	FallbackTopK          int       `json:"fallback_top_k,omitempty" binding:"omitempty,min=1,max=50"`
+	MaxContextChars       int       `json:"max_context_chars,omitempty" binding:"omitempty,min=500,max=100000"`
+	IncludePrices         *bool     `json:"include_prices,omitempty"`
+	Timezone              string    `json:"timezone,omitempty" binding:"omitempty,timezone"`
 }
 
 func (h *ConfigHandler) CreateConfiguration(c *gin.Context) {
@@ -95,6 +115,16 @@ func (h *ConfigHandler) CreateConfiguration(c *gin.Context) {
 		existingConfig.WhatsappTokenExpires = req.WhatsappTokenExpires
 		existingConfig.OpenAIModel = req.OpenAIModel
 		existingConfig.OpenAIEmbeddingModel = req.OpenAIEmbeddingModel
+		existingConfig.SearchMode = req.SearchMode
+		existingConfig.Reranker = req.Reranker
+		existingConfig.RerankCandidates = req.RerankCandidates
+		existingConfig.SimilarityThreshold = valueOr(req.SimilarityThreshold, 0.3)
+		existingConfig.TopK = req.TopK
+		existingConfig.FallbackMode = req.FallbackMode
+		existingConfig.FallbackTopK = req.FallbackTopK
+		existingConfig.MaxContextChars = req.MaxContextChars
+		existingConfig.IncludePrices = valueOr(req.IncludePrices, true)
+		existingConfig.Timezone = req.Timezone
 		existingConfig.UpdatedBy = userID
 
 		err = h.configService.UpdateConfiguration(c.Request.Context(), existingConfig)
@@ -137,6 +167,16 @@ func (h *ConfigHandler) CreateConfiguration(c *gin.Context) {
 		WhatsappTokenExpires:  req.WhatsappTokenExpires,
 		OpenAIModel:           req.OpenAIModel,
 		OpenAIEmbeddingModel:  req.OpenAIEmbeddingModel,
+		SearchMode:            req.SearchMode,
+		Reranker:              req.Reranker,
+		RerankCandidates:      req.RerankCandidates,
+		SimilarityThreshold:   valueOr(req.SimilarityThreshold, 0.3),
+		TopK:                  req.TopK,
+		FallbackMode:          req.FallbackMode,
+		FallbackTopK:          req.FallbackTopK,
+		MaxContextChars:       req.MaxContextChars,
+		IncludePrices:         valueOr(req.IncludePrices, true),
+		Timezone:              req.Timezone,
 		CreatedBy:             userID,
 		UpdatedBy:             userID,
 	}
@@ -263,6 +303,16 @@ func (h *ConfigHandler) UpdateConfiguration(c *gin.Context) {
 		WhatsappTokenExpires:  req.WhatsappTokenExpires,
 		OpenAIModel:           req.OpenAIModel,
 		OpenAIEmbeddingModel:  req.OpenAIEmbeddingModel,
+		SearchMode:            req.SearchMode,
+		Reranker:              req.Reranker,
+		RerankCandidates:      req.RerankCandidates,
+		SimilarityThreshold:   valueOr(req.SimilarityThreshold, 0.3),
+		TopK:                  req.TopK,
+		FallbackMode:          req.FallbackMode,
+		FallbackTopK:          req.FallbackTopK,
+		MaxContextChars:       req.MaxContextChars,
+		IncludePrices:         valueOr(req.IncludePrices, true),
+		Timezone:              req.Timezone,
 		UpdatedBy:             userID,
 	}
 
@@ -331,4 +381,12 @@ func (h *ConfigHandler) DeleteConfiguration(c *gin.Context) {
 			Timestamp: time.Now().UTC().Format(time.RFC3339),
 		},
 	})
-}
\ No newline at end of file
+}
+
+// valueOr returns *v, or def when the optional field was left out
+func valueOr[T any](v *T, def T) T {
+	if v == nil {
+		return def
+	}
+	return *v
+}
@@ -0,0 +1,226 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/divinecoid/oneagent/internal/db"
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// duplicateEmbeddingWeight is the share of a candidate's score that comes
+	// from embedding similarity; the rest comes from name similarity
+	duplicateEmbeddingWeight = 0.6
+	// duplicateNeighbours is how many nearest products by embedding are
+	// considered for each product
+	duplicateNeighbours = 5
+)
+
+// DuplicatePairRequest names two products of a seller
+type DuplicatePairRequest struct {
+	SellerID    int64 `json:"seller_id"` // Super admins only; sellers manage their own
+	ProductID   int64 `json:"product_id" binding:"required"`
+	DuplicateID int64 `json:"duplicate_id" binding:"required"`
+}
+
+// duplicateCandidates finds pairs of a seller's products that look like the
+// same item. Pairs come from names that share most of their trigrams and
+// from each product's nearest neighbours by embedding, with the model the
+// catalog is searched with; both similarities are then computed for every
+// pair. Pairs the seller kept as distinct are left out.
+func duplicateCandidates(ctx context.Context, sellerID int64, minScore float64, limit int) ([]model.DuplicateCandidate, error) {
+	embeddingModel, err := resolveSearchModel(ctx, sellerID)
+	if err != nil {
+		return nil, err
+	}
+	var dims int
+	err = db.Pool.QueryRow(ctx, `
+		SELECT e.dimensions FROM product_embeddings e
+		JOIN products p ON p.id = e.product_id
+		WHERE p.seller_id = $1 AND p.deleted_at IS NULL AND e.model = $2
+		GROUP BY e.dimensions
+		ORDER BY COUNT(*) DESC
+		LIMIT 1`, sellerID, embeddingModel).Scan(&dims)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get embedding dimensions: %w", err)
+	}
+
+	// Without vectors, names are all there is to go on
+	pairs := `
+		SELECT a.id AS a_id, b.id AS b_id
+		FROM products a
+		JOIN products b ON b.seller_id = a.seller_id AND b.id > a.id AND lower(b.name) % lower(a.name)
+		WHERE a.seller_id = $1 AND a.deleted_at IS NULL AND b.deleted_at IS NULL`
+	if dims > 0 {
+		pairs += fmt.Sprintf(`
+		UNION
+		SELECT LEAST(e.product_id, n.product_id), GREATEST(e.product_id, n.product_id)
+		FROM product_embeddings e
+		JOIN products p ON p.id = e.product_id
+		CROSS JOIN LATERAL (
+			SELECT e2.product_id
+			FROM product_embeddings e2
+			JOIN products p2 ON p2.id = e2.product_id
+			WHERE e2.model = $2 AND %s AND p2.seller_id = $1 AND p2.deleted_at IS NULL
+			AND e2.product_id <> e.product_id
+			ORDER BY %s
+			LIMIT %d
+		) n
+		WHERE e.model = $2 AND %s AND p.seller_id = $1 AND p.deleted_at IS NULL`,
+			embeddingDimensions("e2.dimensions", dims), embeddingDistance("e2.embedding", "e.embedding", dims),
+			duplicateNeighbours, embeddingDimensions("e.dimensions", dims))
+	}
+
+	product := func(alias string) string {
+		return fmt.Sprintf(`%[1]s.id, COALESCE(%[1]s.sku, ''), %[1]s.name, COALESCE(%[1]s.category, ''), %[1]s.price,
+			%[1]s.is_available, (SELECT COUNT(*) FROM carts c WHERE c.product_id = %[1]s.id), %[1]s.created_at`, alias)
+	}
+	rows, err := db.Pool.Query(ctx, fmt.Sprintf(`
+		WITH pairs AS (%[1]s
+		), scored AS (
+			SELECT x.a_id, x.b_id, name_similarity, embedding_similarity,
+				COALESCE($3 * embedding_similarity + (1 - $3) * name_similarity, name_similarity) AS score
+			FROM pairs x
+			JOIN products a ON a.id = x.a_id
+			JOIN products b ON b.id = x.b_id
+			LEFT JOIN product_embeddings ea ON ea.product_id = a.id AND ea.model = $2 AND %[4]s
+			LEFT JOIN product_embeddings eb ON eb.product_id = b.id AND eb.model = $2 AND %[5]s
+			CROSS JOIN LATERAL (
+				SELECT similarity(lower(a.name), lower(b.name))::FLOAT8 AS name_similarity,
+					(1 - (ea.embedding <=> eb.embedding))::FLOAT8 AS embedding_similarity
+			) s
+			WHERE NOT EXISTS (
+				SELECT 1 FROM product_duplicate_dismissals d WHERE d.product_id = x.a_id AND d.other_id = x.b_id
+			)
+		)
+		SELECT %[2]s, %[3]s, s.name_similarity, s.embedding_similarity, s.score
+		FROM scored s
+		JOIN products a ON a.id = s.a_id
+		JOIN products b ON b.id = s.b_id
+		WHERE s.score >= $4
+		ORDER BY s.score DESC, s.a_id, s.b_id
+		LIMIT $5`, pairs, product("a"), product("b"), embeddingDimensions("ea.dimensions", dims),
+		embeddingDimensions("eb.dimensions", dims)),
+		sellerID, embeddingModel, duplicateEmbeddingWeight, minScore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("duplicate candidates query failed: %w", err)
+	}
+	defer rows.Close()
+
+	candidates := []model.DuplicateCandidate{}
+	for rows.Next() {
+		var d model.DuplicateCandidate
+		a, b := &d.Product, &d.Duplicate
+		if err := rows.Scan(&a.ID, &a.SKU, &a.Name, &a.Category, &a.Price, &a.IsAvailable, &a.CartItems, &a.CreatedAt,
+			&b.ID, &b.SKU, &b.Name, &b.Category, &b.Price, &b.IsAvailable, &b.CartItems, &b.CreatedAt,
+			&d.NameSimilarity, &d.EmbeddingSimilarity, &d.Score); err != nil {
+			return nil, fmt.Errorf("error scanning duplicate candidates: %w", err)
+		}
+		candidates = append(candidates, d)
+	}
+	return candidates, rows.Err()
+}
+
+// ListDuplicates reports pairs of the caller's products (or, for super
+// admins, the ?seller_id= seller's) that are likely duplicates, most likely
+// first. Optional: min_score between 0 and 1 (default 0.8) and limit
+// (default 50).
+func (h *ProductHandler) ListDuplicates(c *gin.Context) {
+	minScore, err := strconv.ParseFloat(c.DefaultQuery("min_score", "0.8"), 64)
+	if err != nil || minScore < 0 || minScore > 1 {
+		productValidationError(c, "min_score must be between 0 and 1")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 200 {
+		productValidationError(c, "limit must be between 1 and 200")
+		return
+	}
+	requested, _ := strconv.ParseInt(c.Query("seller_id"), 10, 64)
+	sellerID, ok := productSeller(c, requested)
+	if !ok {
+		return
+	}
+
+	candidates, err := duplicateCandidates(c.Request.Context(), sellerID, minScore, limit)
+	if err != nil {
+		productError(c, err, "Failed to find duplicate products")
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Duplicate candidates retrieved successfully",
+		Data:    gin.H{"candidates": candidates},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// MergeDuplicate folds duplicate_id into product_id, which survives: carts,
+// search analytics and promotions that referred to the duplicate refer to
+// the surviving product, and the duplicate is deleted.
+func (h *ProductHandler) MergeDuplicate(c *gin.Context) {
+	var req DuplicatePairRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		productValidationError(c, err.Error())
+		return
+	}
+	sellerID, ok := productSeller(c, req.SellerID)
+	if !ok {
+		return
+	}
+
+	merge, err := h.productService.MergeProducts(c.Request.Context(), sellerID, req.ProductID, req.DuplicateID,
+		c.MustGet("user_id").(int64))
+	if err != nil {
+		productError(c, err, "Failed to merge products")
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Products merged successfully",
+		Data:    merge,
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// KeepDuplicate marks product_id and duplicate_id as distinct products, so
+// they are no longer reported as duplicates
+func (h *ProductHandler) KeepDuplicate(c *gin.Context) {
+	var req DuplicatePairRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		productValidationError(c, err.Error())
+		return
+	}
+	sellerID, ok := productSeller(c, req.SellerID)
+	if !ok {
+		return
+	}
+
+	err := h.productService.KeepProducts(c.Request.Context(), sellerID, req.ProductID, req.DuplicateID,
+		c.MustGet("user_id").(int64))
+	if err != nil {
+		productError(c, err, "Failed to keep products")
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Products kept as distinct",
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
@@ -8,10 +8,15 @@ import (
 	"net/http"
 	"os"
 	"strings"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/divinecoid/oneagent/internal/db"
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/divinecoid/oneagent/internal/service"
 	"github.com/joho/godotenv"
 )
 
+// defaultEmbeddingModel is used when a seller has no configured embedding model
+const defaultEmbeddingModel = "text-embedding-3-small"
+
 type openAIEmbeddingRequest struct {
 	Input string `json:"input"`
 	Model string `json:"model"`
@@ -41,7 +46,7 @@ func getEmbedding(text string, model string) ([]float32, error) {
 	}
 
 	if model == "" {
-		model = "text-embedding-3-small" // Default model
+		model = defaultEmbeddingModel
 	}
 
 	body, err := json.Marshal(openAIEmbeddingRequest{
@@ -52,7 +57,14 @@ func getEmbedding(text string, model string) ([]float32, error) {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/embeddings", bytes.NewBuffer(body))
+	// EMBEDDING_API_URL points at any OpenAI-compatible embeddings endpoint,
+	// e.g. a locally hosted model
+	apiURL := os.Getenv("EMBEDDING_API_URL")
+	if apiURL == "" {
+		apiURL = "https://api.openai.com/v1/embeddings"
+	}
+
+	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -84,103 +96,206 @@ func getEmbedding(text string, model string) ([]float32, error) {
 	return result.Data[0].Embedding, nil
 }
 
-// updateEmbeddings updates product embeddings in the database for records where embedding is NULL
-func updateEmbeddings() error {
-	ctx := context.Background()
-	
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		return fmt.Errorf("DATABASE_URL environment variable not set")
-	}
-
-	pool, err := pgxpool.New(ctx, dbURL)
-	if err != nil {
-		return fmt.Errorf("failed to create connection pool: %w", err)
-	}
-	defer pool.Close()
-
-	// First, check if there are any products in the database
-	var productCount int
-	err = pool.QueryRow(ctx, `SELECT COUNT(*) FROM products`).Scan(&productCount)
+// embeddingBatchSize is how many products an embedding job locks at a time
+const embeddingBatchSize = 20
+
+// embeddingStaleCondition matches products without an up-to-date vector from
+// the seller's configured embedding model. Deleted products are never embedded.
+const embeddingStaleCondition = `p.deleted_at IS NULL AND NOT EXISTS (
+	SELECT 1 FROM product_embeddings e
+	WHERE e.product_id = p.id
+	AND e.model = seller_embedding_model(p.seller_id)
+	AND e.content_hash = p.content_hash
+)`
+
+// runEmbeddingJob (re-)embeds stale products with each seller's embedding
+// model. Rows are claimed in small batches with FOR UPDATE SKIP LOCKED, so
+// concurrent runs never embed the same product twice.
+func runEmbeddingJob(ctx context.Context, job *model.Job, r *service.JobReporter) error {
+	var pending int
+	err := db.Pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM products p
+		WHERE `+embeddingStaleCondition+`
+		AND ($1::BIGINT IS NULL OR p.seller_id = $1)
+	`, job.SellerID).Scan(&pending)
 	if err != nil {
-		return fmt.Errorf("failed to count products: %w", err)
+		return fmt.Errorf("failed to count products needing embeddings: %w", err)
 	}
-	
-	if productCount == 0 {
-		fmt.Println("No products found in database. Please upload products first.")
-		return fmt.Errorf("no products found in database")
+	if err := r.SetTotal(ctx, pending); err != nil {
+		return err
 	}
 
-	// Check how many products need embeddings
-	var nullEmbeddingCount int
-	err = pool.QueryRow(ctx, `SELECT COUNT(*) FROM products WHERE embedding IS NULL`).Scan(&nullEmbeddingCount)
-	if err != nil {
-		return fmt.Errorf("failed to count products without embeddings: %w", err)
+	// Products that failed in this run are not retried until the next run
+	failedIDs := []int64{}
+	for {
+		claimed, err := embedProductBatch(ctx, job.SellerID, &failedIDs, r)
+		if err != nil {
+			return err
+		}
+		if claimed == 0 {
+			return pruneRetiredEmbeddings(ctx, job.SellerID)
+		}
+		if err := r.Checkpoint(ctx); err != nil {
+			return err
+		}
 	}
+}
 
-	fmt.Printf("Found %d products in database, %d need embeddings\n", productCount, nullEmbeddingCount)
-
-	if nullEmbeddingCount == 0 {
-		fmt.Println("All products already have embeddings.")
-		return nil
+// embedProductBatch locks up to embeddingBatchSize stale products, embeds
+// them and commits. It returns how many products were claimed.
+func embedProductBatch(ctx context.Context, sellerID *int64, failedIDs *[]int64, r *service.JobReporter) (int, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
-
-	rows, err := pool.Query(ctx, `SELECT id, name, category, description FROM products WHERE embedding IS NULL`)
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT p.id, p.name, COALESCE(p.category, ''), COALESCE(p.description, ''),
+			p.content_hash, seller_embedding_model(p.seller_id)
+		FROM products p
+		WHERE `+embeddingStaleCondition+`
+		AND ($1::BIGINT IS NULL OR p.seller_id = $1)
+		AND NOT (p.id = ANY($2))
+		ORDER BY p.id
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	`, sellerID, *failedIDs, embeddingBatchSize)
 	if err != nil {
-		return fmt.Errorf("failed to query products: %w", err)
+		return 0, fmt.Errorf("failed to query products: %w", err)
 	}
-	defer rows.Close()
-
-	successCount := 0
-	errorCount := 0
 
+	type pendingProduct struct {
+		id                   int64
+		name, category, desc string
+		contentHash, model   string
+	}
+	var batch []pendingProduct
 	for rows.Next() {
-		var id int
-		var name, category, desc string
-		if err := rows.Scan(&id, &name, &category, &desc); err != nil {
-			fmt.Printf("Error scanning row: %v\n", err)
-			errorCount++
-			continue
+		var p pendingProduct
+		if err := rows.Scan(&p.id, &p.name, &p.category, &p.desc, &p.contentHash, &p.model); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan product: %w", err)
 		}
+		batch = append(batch, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating over rows: %w", err)
+	}
 
-		// Combine name, category, and description for better embedding
-		var combinedText string
-		if category != "" {
-			combinedText = fmt.Sprintf("%s. Category: %s. %s", name, category, desc)
-		} else {
-			combinedText = fmt.Sprintf("%s. %s", name, desc)
-		}
-
-		fmt.Printf("Processing product %d: %s\n", id, name)
-
-		embedding, err := getEmbedding(combinedText, "")
+	for _, p := range batch {
+		itemRef := fmt.Sprintf("product %d", p.id)
+		embedding, err := getEmbedding(productEmbeddingText(p.name, p.category, p.desc), p.model)
 		if err != nil {
-			fmt.Printf("Embedding error for product %d (%s): %v\n", id, name, err)
-			errorCount++
+			r.Failed(ctx, itemRef, fmt.Errorf("embedding error: %w", err))
+			*failedIDs = append(*failedIDs, p.id)
 			continue
 		}
 
 		vectorStr := fmt.Sprintf("[%s]", formatFloatSlice(embedding))
-		
-		_, err = pool.Exec(ctx, `UPDATE products SET embedding = $1::vector WHERE id = $2`, vectorStr, id)
+		_, err = tx.Exec(ctx, `
+			INSERT INTO product_embeddings (product_id, model, dimensions, embedding, content_hash)
+			VALUES ($1, $2, $3, $4::vector, $5)
+			ON CONFLICT (product_id, model, dimensions) DO UPDATE
+			SET embedding = EXCLUDED.embedding, content_hash = EXCLUDED.content_hash, updated_at = NOW()
+		`, p.id, p.model, len(embedding), vectorStr, p.contentHash)
 		if err != nil {
-			fmt.Printf("DB update error for product %d (%s): %v\n", id, name, err)
-			errorCount++
-			continue
+			return 0, fmt.Errorf("failed to update embedding for product %d: %w", p.id, err)
 		}
+		r.Succeeded(1)
+	}
 
-		fmt.Printf("Successfully updated embedding for product %d: %s\n", id, name)
-		successCount++
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit embeddings: %w", err)
 	}
+	return len(batch), nil
+}
+
+// pruneRetiredEmbeddings deletes vectors from models sellers no longer use,
+// but only for sellers whose whole catalog has a vector from the configured
+// model. Until then search keeps serving from the old model.
+func pruneRetiredEmbeddings(ctx context.Context, sellerID *int64) error {
+	_, err := db.Pool.Exec(ctx, `
+		DELETE FROM product_embeddings e
+		USING products p
+		WHERE e.product_id = p.id
+		AND ($1::BIGINT IS NULL OR p.seller_id = $1)
+		AND e.model <> seller_embedding_model(p.seller_id)
+		AND NOT EXISTS (
+			SELECT 1 FROM products p2
+			WHERE p2.seller_id IS NOT DISTINCT FROM p.seller_id
+			AND p2.deleted_at IS NULL
+			AND NOT EXISTS (
+				SELECT 1 FROM product_embeddings e2
+				WHERE e2.product_id = p2.id AND e2.model = seller_embedding_model(p2.seller_id)
+			)
+		)
+	`, sellerID)
+	if err != nil {
+		return fmt.Errorf("failed to prune retired embeddings: %w", err)
+	}
+	return nil
+}
 
+// enqueueStaleEmbeddingJobs queues an embedding_update job for every seller
+// with stale products and no embedding job in progress. Sellers whose last
+// run failed items recently are left alone for a while to avoid retry storms.
+func enqueueStaleEmbeddingJobs(ctx context.Context, jobService *service.JobService) error {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT DISTINCT p.seller_id
+		FROM products p
+		WHERE p.seller_id IS NOT NULL
+		AND `+embeddingStaleCondition+`
+		AND NOT EXISTS (
+			SELECT 1 FROM jobs j
+			WHERE j.type = $1
+			AND (j.seller_id = p.seller_id OR j.seller_id IS NULL)
+			AND (
+				j.status IN ('queued', 'running')
+				OR (j.failed_items > 0 AND j.finished_at > NOW() - INTERVAL '15 minutes')
+			)
+		)
+	`, model.JobTypeEmbeddingUpdate)
+	if err != nil {
+		return fmt.Errorf("failed to find stale embeddings: %w", err)
+	}
+	var sellerIDs []int64
+	for rows.Next() {
+		var sellerID int64
+		if err := rows.Scan(&sellerID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan seller: %w", err)
+		}
+		sellerIDs = append(sellerIDs, sellerID)
+	}
+	rows.Close()
 	if err := rows.Err(); err != nil {
 		return fmt.Errorf("error iterating over rows: %w", err)
 	}
 
-	fmt.Printf("Embedding update completed. Success: %d, Errors: %d\n", successCount, errorCount)
+	for _, sellerID := range sellerIDs {
+		sellerID := sellerID
+		job := &model.Job{
+			Type:      model.JobTypeEmbeddingUpdate,
+			SellerID:  &sellerID,
+			CreatedBy: sellerID, // Queued on the seller's behalf
+		}
+		if err := jobService.Enqueue(ctx, job); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// productEmbeddingText combines name, category, and description for better embedding
+func productEmbeddingText(name, category, desc string) string {
+	if category != "" {
+		return fmt.Sprintf("%s. Category: %s. %s", name, category, desc)
+	}
+	return fmt.Sprintf("%s. %s", name, desc)
+}
+
 // formatFloatSlice formats a slice of float32 values into a comma-separated string
 func formatFloatSlice(floats []float32) string {
 	strs := make([]string, len(floats))
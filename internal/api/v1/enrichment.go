@@ -0,0 +1,451 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/divinecoid/oneagent/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// maxEnrichCategories caps how many of the seller's categories are offered
+// to the model, to keep the prompt bounded
+const maxEnrichCategories = 200
+
+type productEnrichmentPayload struct {
+	ProductIDs []int64  `json:"product_ids"`
+	Fields     []string `json:"fields"`
+}
+
+// EnrichProductsRequest selects products to write suggestions for. Fields
+// defaults to every field.
+type EnrichProductsRequest struct {
+	SellerID   int64    `json:"seller_id"` // Super admins only; sellers manage their own
+	ProductIDs []int64  `json:"product_ids" binding:"required,min=1,max=100"`
+	Fields     []string `json:"fields"`
+}
+
+// ReviewSuggestionRequest picks the fields of a suggestion to approve;
+// every suggested field when empty
+type ReviewSuggestionRequest struct {
+	Fields []string `json:"fields"`
+}
+
+// enrichmentSuggestion is what the model is asked to reply with
+type enrichmentSuggestion struct {
+	Description string         `json:"description"`
+	Category    string         `json:"category"`
+	Attributes  map[string]any `json:"attributes"`
+}
+
+// runEnrichmentJob has the seller's configured chat model write suggested
+// content for the products in the job's payload. Each suggestion is stored
+// as pending and replaces the product's previous pending one; products
+// themselves are never changed here. A retried job skips the products it
+// already wrote suggestions for, and a suggestion that can't be saved fails
+// only its product.
+func runEnrichmentJob(ctx context.Context, job *model.Job, r *service.JobReporter) error {
+	var payload productEnrichmentPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid job payload: %w", err)
+	}
+	if job.SellerID == nil {
+		return fmt.Errorf("product enrichment job has no seller")
+	}
+	if len(payload.Fields) == 0 {
+		payload.Fields = model.EnrichFields
+	}
+	config, err := getConfigurationForUser(ctx, *job.SellerID)
+	if err != nil {
+		return fmt.Errorf("failed to get seller configuration: %w", err)
+	}
+	if config.OpenAIAPIKey == "" {
+		return fmt.Errorf("OpenAI API key not set")
+	}
+	var categories []string
+	if slices.Contains(payload.Fields, model.EnrichCategory) {
+		if categories, err = categoryPaths(ctx, *job.SellerID); err != nil {
+			return err
+		}
+	}
+	if err := r.SetTotal(ctx, len(payload.ProductIDs)); err != nil {
+		return err
+	}
+
+	productService := service.NewProductService()
+	done, err := productService.JobSuggestions(ctx, job.ID)
+	if err != nil {
+		return err
+	}
+	suggested := 0
+	for _, id := range payload.ProductIDs {
+		itemRef := fmt.Sprintf("product %d", id)
+		if slices.Contains(done, id) {
+			suggested++
+			r.Succeeded(1)
+			continue
+		}
+		p, err := productService.GetProduct(ctx, id)
+		if err == nil && p.SellerID != *job.SellerID {
+			err = service.ErrProductNotFound
+		}
+		if err != nil {
+			r.Failed(ctx, itemRef, err)
+			continue
+		}
+
+		content, err := suggestProductContent(ctx, config, p, payload.Fields, categories)
+		if err != nil {
+			r.Failed(ctx, itemRef, fmt.Errorf("enrichment error: %w", err))
+			continue
+		}
+		sg := &model.ProductSuggestion{
+			ProductID: p.ID,
+			SellerID:  p.SellerID,
+			JobID:     &job.ID,
+			Model:     config.OpenAIModel,
+			Suggested: content,
+			Original:  p.Content(),
+		}
+		if err := productService.SaveSuggestion(ctx, sg); err != nil {
+			r.Failed(ctx, itemRef, err)
+			continue
+		}
+		suggested++
+		r.Succeeded(1)
+		if err := r.Checkpoint(ctx); err != nil {
+			return err
+		}
+	}
+	return r.SetResult(ctx, gin.H{"suggested": suggested})
+}
+
+// categoryPaths lists a seller's categories as "Parent > Child" paths, which
+// is also how a suggested category is matched against the tree
+func categoryPaths(ctx context.Context, sellerID int64) ([]string, error) {
+	categories, err := service.NewCategoryService().ListCategories(ctx, sellerID)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]*model.Category, len(categories))
+	for _, c := range categories {
+		byID[c.ID] = c
+	}
+	paths := []string{}
+	for _, c := range categories {
+		names := []string{c.Name}
+		for parent := c.ParentID; parent != nil && byID[*parent] != nil && len(names) < 10; parent = byID[*parent].ParentID {
+			names = append([]string{byID[*parent].Name}, names...)
+		}
+		paths = append(paths, strings.Join(names, " > "))
+		if len(paths) == maxEnrichCategories {
+			break
+		}
+	}
+	return paths, nil
+}
+
+// suggestProductContent asks the seller's chat model to write the given
+// fields of a product. Only the fields asked for are set on the result.
+func suggestProductContent(ctx context.Context, config *model.UserConfiguration, p *model.Product, fields, categories []string) (model.ProductContent, error) {
+	var prompt strings.Builder
+	prompt.WriteString(fmt.Sprintf("Product: %s\nPrice: Rp %.0f\n", p.Name, p.Price))
+	if p.Category != "" {
+		prompt.WriteString(fmt.Sprintf("Category: %s\n", p.Category))
+	}
+	if p.Description != "" {
+		prompt.WriteString(fmt.Sprintf("Description: %s\n", p.Description))
+	}
+	if len(p.Attributes) > 0 {
+		attributes, _ := json.Marshal(p.Attributes)
+		prompt.WriteString(fmt.Sprintf("Attributes: %s\n", attributes))
+	}
+	for _, v := range p.Variants {
+		prompt.WriteString(fmt.Sprintf("Variant: %s, Rp %.0f\n", v.Name, v.Price))
+	}
+
+	prompt.WriteString("\nImprove this catalog entry for an Indonesian online shop. Only use facts that are given or obvious from the product name; never invent sizes, ingredients or claims.\n")
+	reply := map[string]string{}
+	if slices.Contains(fields, model.EnrichDescription) {
+		prompt.WriteString("- description: two to four sentences in Indonesian that help a customer choose the product.\n")
+		reply["description"] = `"..."`
+	}
+	if slices.Contains(fields, model.EnrichCategory) {
+		prompt.WriteString("- category: the best fitting category as a \"Parent > Child\" path.")
+		if len(categories) > 0 {
+			prompt.WriteString(" Prefer one of the shop's categories: " + strings.Join(categories, "; ") + ".")
+		}
+		prompt.WriteString("\n")
+		reply["category"] = `"..."`
+	}
+	if slices.Contains(fields, model.EnrichAttributes) {
+		prompt.WriteString("- attributes: the complete set of structured attributes (e.g. size, flavour, material) as a flat JSON object with short lowercase keys. Keep the existing attributes and their values.\n")
+		reply["attributes"] = `{...}`
+	}
+	var shape []string
+	for _, field := range model.EnrichFields {
+		if value, ok := reply[field]; ok {
+			shape = append(shape, fmt.Sprintf("%q: %s", field, value))
+		}
+	}
+	prompt.WriteString("Reply with JSON only: {" + strings.Join(shape, ", ") + "}")
+
+	body, err := json.Marshal(OpenAIRequest{
+		Model: config.OpenAIModel,
+		Messages: []Message{
+			{Role: "system", Content: "You write accurate, concise product catalog content for a shop."},
+			{Role: "user", Content: prompt.String()},
+		},
+		MaxTokens: 800,
+	})
+	if err != nil {
+		return model.ProductContent{}, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(body))
+	if err != nil {
+		return model.ProductContent{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+config.OpenAIAPIKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return model.ProductContent{}, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var rawBody bytes.Buffer
+		rawBody.ReadFrom(resp.Body)
+		return model.ProductContent{}, fmt.Errorf("OpenAI API error: %s", rawBody.String())
+	}
+
+	var result OpenAIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return model.ProductContent{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(result.Choices) == 0 {
+		return model.ProductContent{}, fmt.Errorf("no response generated")
+	}
+
+	// Models often wrap JSON in a markdown code fence
+	text := strings.TrimSpace(result.Choices[0].Message.Content)
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.Trim(text, "`\n ")
+
+	var parsed enrichmentSuggestion
+	if err := json.Unmarshal([]byte(text), &parsed); err != nil {
+		return model.ProductContent{}, fmt.Errorf("failed to parse suggestion: %w", err)
+	}
+	var content model.ProductContent
+	if description := strings.TrimSpace(parsed.Description); description != "" && slices.Contains(fields, model.EnrichDescription) {
+		content.Description = &description
+	}
+	if category := strings.TrimSpace(parsed.Category); category != "" && slices.Contains(fields, model.EnrichCategory) {
+		content.Category = &category
+	}
+	if len(parsed.Attributes) > 0 && slices.Contains(fields, model.EnrichAttributes) {
+		content.Attributes = parsed.Attributes
+	}
+	if content.Description == nil && content.Category == nil && content.Attributes == nil {
+		return model.ProductContent{}, fmt.Errorf("model suggested nothing")
+	}
+	return content, nil
+}
+
+// enrichFields checks the fields of an enrichment or review request
+func enrichFields(fields []string) error {
+	for _, field := range fields {
+		if !slices.Contains(model.EnrichFields, field) {
+			return fmt.Errorf("fields must be description, category or attributes")
+		}
+	}
+	return nil
+}
+
+// loadSuggestion parses the :id parameter and returns the suggestion if it
+// belongs to the caller's catalog or the caller is a super admin. Other
+// sellers' suggestions are reported as not found. It writes the error
+// response itself.
+func (h *ProductHandler) loadSuggestion(c *gin.Context) (*model.ProductSuggestion, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		productValidationError(c, "suggestion ID must be a number")
+		return nil, false
+	}
+	sg, err := h.productService.GetSuggestion(c.Request.Context(), id)
+	if err == nil && model.Role(c.GetString("user_role")) != model.RoleSuperAdmin &&
+		sg.SellerID != c.MustGet("user_id").(int64) {
+		err = service.ErrSuggestionNotFound
+	}
+	if err != nil {
+		productError(c, err, "Failed to get suggestion")
+		return nil, false
+	}
+	return sg, true
+}
+
+// EnrichProducts queues a product_enrichment job that has the seller's
+// configured chat model suggest descriptions, categories and attributes for
+// up to 100 of its products. Nothing changes until the seller approves the
+// suggestions. Enrichment is opt-in: it needs an OpenAI API key in the
+// seller's configuration.
+func (h *ProductHandler) EnrichProducts(c *gin.Context) {
+	var req EnrichProductsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		productValidationError(c, err.Error())
+		return
+	}
+	if err := enrichFields(req.Fields); err != nil {
+		productValidationError(c, err.Error())
+		return
+	}
+	sellerID, ok := productSeller(c, req.SellerID)
+	if !ok {
+		return
+	}
+
+	config, err := getConfigurationForUser(c.Request.Context(), sellerID)
+	if err != nil || config.OpenAIAPIKey == "" {
+		productValidationError(c, "set an OpenAI API key in the configuration to use enrichment")
+		return
+	}
+	if err := h.productService.CheckSellerProducts(c.Request.Context(), sellerID, req.ProductIDs); err != nil {
+		productError(c, err, "Failed to check products")
+		return
+	}
+
+	payload, _ := json.Marshal(productEnrichmentPayload{ProductIDs: req.ProductIDs, Fields: req.Fields})
+	job := &model.Job{
+		Type:      model.JobTypeProductEnrichment,
+		SellerID:  &sellerID,
+		Payload:   payload,
+		CreatedBy: c.MustGet("user_id").(int64),
+	}
+	if err := h.jobService.Enqueue(c.Request.Context(), job); err != nil {
+		productError(c, err, "Failed to queue enrichment")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, APIResponse{
+		Success: true,
+		Message: "Enrichment accepted for processing",
+		Data:    gin.H{"job_id": job.ID, "job": job},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// ListSuggestions lists the caller's product suggestions (or, for super
+// admins, the ?seller_id= seller's), newest first. Optional: status
+// (pending, approved or rejected; default pending), limit and offset.
+func (h *ProductHandler) ListSuggestions(c *gin.Context) {
+	status := c.DefaultQuery("status", model.SuggestionPending)
+	switch status {
+	case model.SuggestionPending, model.SuggestionApproved, model.SuggestionRejected:
+	default:
+		productValidationError(c, "status must be pending, approved or rejected")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	requested, _ := strconv.ParseInt(c.Query("seller_id"), 10, 64)
+	sellerID, ok := productSeller(c, requested)
+	if !ok {
+		return
+	}
+
+	suggestions, total, err := h.productService.ListSuggestions(c.Request.Context(), sellerID, status, limit, offset)
+	if err != nil {
+		productError(c, err, "Failed to list suggestions")
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Suggestions retrieved successfully",
+		Data: gin.H{
+			"suggestions": suggestions,
+			"total":       total,
+			"limit":       limit,
+			"offset":      offset,
+		},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// ApproveSuggestion replaces the product's content with a pending
+// suggestion's, for the body's fields or every suggested field, and returns
+// the updated product. Fields edited since the suggestion was written are
+// refused with 409.
+func (h *ProductHandler) ApproveSuggestion(c *gin.Context) {
+	var req ReviewSuggestionRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		productValidationError(c, err.Error())
+		return
+	}
+	if err := enrichFields(req.Fields); err != nil {
+		productValidationError(c, err.Error())
+		return
+	}
+	sg, ok := h.loadSuggestion(c)
+	if !ok {
+		return
+	}
+
+	product, err := h.productService.ApproveSuggestion(c.Request.Context(), sg.ID, req.Fields, c.MustGet("user_id").(int64))
+	if err != nil {
+		productError(c, err, "Failed to approve suggestion")
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Suggestion approved",
+		Data:    product,
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// RejectSuggestion discards a pending suggestion
+func (h *ProductHandler) RejectSuggestion(c *gin.Context) {
+	sg, ok := h.loadSuggestion(c)
+	if !ok {
+		return
+	}
+	if err := h.productService.RejectSuggestion(c.Request.Context(), sg.ID, c.MustGet("user_id").(int64)); err != nil {
+		productError(c, err, "Failed to reject suggestion")
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Suggestion rejected",
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
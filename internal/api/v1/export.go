@@ -0,0 +1,75 @@
+package v1
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/divinecoid/oneagent/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+var exportContentTypes = map[string]string{
+	service.FormatXLSX: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	service.FormatCSV:  "text/csv; charset=utf-8",
+	service.FormatJSON: "application/json; charset=utf-8",
+}
+
+// ExportProducts streams the caller's catalog (or, for super admins, the
+// ?seller_id= seller's) as ?format=xlsx, csv or json. The columns match
+// what ImportProducts reads, so the file can be edited and imported again.
+func (h *ProductHandler) ExportProducts(c *gin.Context) {
+	format := c.DefaultQuery("format", service.FormatXLSX)
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		productValidationError(c, "format must be xlsx, csv or json")
+		return
+	}
+	requested, _ := strconv.ParseInt(c.Query("seller_id"), 10, 64)
+	sellerID, ok := productSeller(c, requested)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="products-%d.%s"`, sellerID, format))
+	c.Status(http.StatusOK)
+	if err := h.productService.ExportProducts(c.Request.Context(), c.Writer, format, sellerID); err != nil {
+		if !c.Writer.Written() {
+			c.Header("Content-Type", "")
+			c.Header("Content-Disposition", "")
+			productError(c, err, "Failed to export products")
+			return
+		}
+		// The response is already streaming; all that's left is to cut it short
+		fmt.Printf("Product export for seller %d failed: %v\n", sellerID, err)
+		c.Abort()
+	}
+}
+
+// ImportTemplate returns an empty catalog file (?format=xlsx or csv) to fill
+// in and upload to ImportProducts. The XLSX template offers the caller's
+// categories (or, for super admins, the ?seller_id= seller's) as a dropdown
+// and explains every column on a sheet in Indonesian.
+func (h *ProductHandler) ImportTemplate(c *gin.Context) {
+	format := c.DefaultQuery("format", service.FormatXLSX)
+	contentType, ok := exportContentTypes[format]
+	if !ok || format == service.FormatJSON {
+		productValidationError(c, "format must be xlsx or csv")
+		return
+	}
+	requested, _ := strconv.ParseInt(c.Query("seller_id"), 10, 64)
+	sellerID, ok := productSeller(c, requested)
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := h.productService.WriteImportTemplate(c.Request.Context(), &buf, format, sellerID); err != nil {
+		productError(c, err, "Failed to generate import template")
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="template-impor-produk.%s"`, format))
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
@@ -2,18 +2,14 @@ package v1
 
 import (
     "context"
+    "errors"
     "fmt"
     "net/http"
-    "os"
-    "strconv"
     "strings"
     "encoding/json"
     "bytes"
     "github.com/gin-gonic/gin"
-    "github.com/xuri/excelize/v2"
     "github.com/divinecoid/oneagent/internal/model"
-    "github.com/divinecoid/oneagent/internal/db"
-    "github.com/jackc/pgx/v5/pgxpool"
     "time"
     "github.com/divinecoid/oneagent/internal/service"
 )
@@ -35,184 +31,50 @@ func GetUsers(c *gin.Context) {
     })
 }
 
-func UploadProductExcel(c *gin.Context) {
-    // Parse seller_id from multipart form
-    sellerIDStr := c.PostForm("seller_id")
-    if sellerIDStr == "" {
-        c.JSON(http.StatusBadRequest, APIResponse{
-            Success: false,
-            Message: "seller_id is required as a form field",
-            Data:    nil,
-            Errors:  gin.H{"upload_error": "seller_id is required as a form field"},
-            Meta: MetaData{
-                RequestID: c.GetHeader("X-Request-ID"),
-                Timestamp: time.Now().UTC().Format(time.RFC3339),
-            },
-        })
-        return
-    }
-    sellerID, err := strconv.ParseInt(sellerIDStr, 10, 64)
-    if err != nil {
-        c.JSON(http.StatusBadRequest, APIResponse{
-            Success: false,
-            Message: "seller_id must be a valid integer",
-            Data:    nil,
-            Errors:  gin.H{"upload_error": "seller_id must be a valid integer"},
-            Meta: MetaData{
-                RequestID: c.GetHeader("X-Request-ID"),
-                Timestamp: time.Now().UTC().Format(time.RFC3339),
-            },
-        })
-        return
-    }
-
-    // Validate seller_id exists in users table with role 'seller'
-    var exists bool
-    err = db.DB.QueryRow(
-        context.Background(),
-        "SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND role = 'seller')",
-        sellerID,
-    ).Scan(&exists)
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, APIResponse{
-            Success: false,
-            Message: "Failed to validate seller_id",
-            Data:    nil,
-            Errors:  gin.H{"upload_error": "Failed to validate seller_id", "details": err.Error()},
-            Meta: MetaData{
-                RequestID: c.GetHeader("X-Request-ID"),
-                Timestamp: time.Now().UTC().Format(time.RFC3339),
-            },
-        })
-        return
-    }
-    if !exists {
-        c.JSON(http.StatusBadRequest, APIResponse{
-            Success: false,
-            Message: "seller_id does not exist in users table with role 'seller'",
-            Data:    nil,
-            Errors:  gin.H{"upload_error": "seller_id does not exist in users table with role 'seller'"},
-            Meta: MetaData{
-                RequestID: c.GetHeader("X-Request-ID"),
-                Timestamp: time.Now().UTC().Format(time.RFC3339),
-            },
-        })
-        return
-    }
+type ProductHandler struct {
+    jobService       *service.JobService
+    productService   *service.ProductService
+    inventoryService *service.InventoryService
+    imageService     *service.ImageService
+}
 
-    file, err := c.FormFile("file")
-    if err != nil {
-        c.JSON(http.StatusBadRequest, APIResponse{
-            Success: false,
-            Message: "File is required",
-            Data:    nil,
-            Errors:  gin.H{"upload_error": "File is required"},
-            Meta: MetaData{
-                RequestID: c.GetHeader("X-Request-ID"),
-                Timestamp: time.Now().UTC().Format(time.RFC3339),
-            },
-        })
-        return
-    }
+func NewProductHandler(jobService *service.JobService, productService *service.ProductService, inventoryService *service.InventoryService, imageService *service.ImageService) *ProductHandler {
+    return &ProductHandler{jobService: jobService, productService: productService, inventoryService: inventoryService, imageService: imageService}
+}
 
-    f, err := file.Open()
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, APIResponse{
-            Success: false,
-            Message: "Failed to open file",
-            Data:    nil,
-            Errors:  gin.H{"file_error": "Failed to open uploaded file", "details": err.Error()},
-            Meta: MetaData{
-                RequestID: c.GetHeader("X-Request-ID"),
-                Timestamp: time.Now().UTC().Format(time.RFC3339),
-            },
-        })
-        return
-    }
-    defer f.Close()
+// productImportPayload is stored with a product_import job
+type productImportPayload struct {
+    FileName string `json:"file_name"`
+    Format   string `json:"format"` // xlsx when empty
+    Sheet    string `json:"sheet"`
+    Mode     string `json:"mode"` // append when empty
+}
 
-    xlsx, err := excelize.OpenReader(f)
-    if err != nil {
-        c.JSON(http.StatusBadRequest, APIResponse{
-            Success: false,
-            Message: "Invalid Excel file",
-            Data:    nil,
-            Errors:  gin.H{"file_error": "Invalid Excel file", "details": err.Error()},
-            Meta: MetaData{
-                RequestID: c.GetHeader("X-Request-ID"),
-                Timestamp: time.Now().UTC().Format(time.RFC3339),
-            },
-        })
-        return
-    }
+// UploadProductExcel is the older spreadsheet upload endpoint. It imports
+// exactly like ImportProducts, except that the format defaults to xlsx.
+func (h *ProductHandler) UploadProductExcel(c *gin.Context) {
+    h.importCatalog(c, service.FormatXLSX)
+}
 
-    rows, err := xlsx.GetRows("Products")
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, APIResponse{
-            Success: false,
-            Message: "Failed to read sheet",
-            Data:    nil,
-            Errors:  gin.H{"sheet_error": "Failed to read Excel sheet", "details": err.Error()},
-            Meta: MetaData{
-                RequestID: c.GetHeader("X-Request-ID"),
-                Timestamp: time.Now().UTC().Format(time.RFC3339),
-            },
-        })
-        return
+// UpdateProductEmbeddings queues an embedding_update job. Sellers only embed
+// their own products; super admins embed every seller's.
+func (h *ProductHandler) UpdateProductEmbeddings(c *gin.Context) {
+    userID := c.MustGet("user_id").(int64)
+    job := &model.Job{
+        Type:      model.JobTypeEmbeddingUpdate,
+        CreatedBy: userID,
     }
-
-    for i, row := range rows {
-        if i == 0 {
-            continue // skip header
-        }
-
-        if len(row) < 4 {
-            continue
-        }
-
-        price, err := strconv.ParseFloat(row[2], 64)
-        if err != nil {
-            continue
-        }
-
-        product := model.Product{
-            Name:        row[0],
-            Category:    row[1],
-            Price:       price,
-            Description: row[3],
-        }
-
-        _, err = db.DB.Exec(context.Background(), `
-            INSERT INTO products (name, category, price, description, seller_id)
-            VALUES ($1, $2, $3, $4, $5)
-        `, product.Name, product.Category, product.Price, product.Description, sellerID)
-
-        if err != nil {
-            fmt.Printf("DB error on row %d: %v\n", i, err)
-        }
+    if model.Role(c.GetString("user_role")) != model.RoleSuperAdmin {
+        job.SellerID = &userID
     }
 
-    c.JSON(http.StatusOK, APIResponse{
-        Success: true,
-        Message: "Upload successful",
-        Data:    nil,
-        Errors:  nil,
-        Meta: MetaData{
-            RequestID: c.GetHeader("X-Request-ID"),
-            Timestamp: time.Now().UTC().Format(time.RFC3339),
-        },
-    })
-}
-
-// UpdateProductEmbeddings updates embeddings for all products that don't have embeddings yet
-func UpdateProductEmbeddings(c *gin.Context) {
-    if err := updateEmbeddings(); err != nil {
+    if err := h.jobService.Enqueue(c.Request.Context(), job); err != nil {
         c.JSON(http.StatusInternalServerError, APIResponse{
             Success: false,
             Message: "Failed to update embeddings",
             Data:    nil,
             Errors: gin.H{
-                "update_error": "Failed to update product embeddings",
+                "update_error": "Failed to queue product embedding update",
                 "details":     err.Error(),
             },
             Meta: MetaData{
@@ -223,10 +85,10 @@ func UpdateProductEmbeddings(c *gin.Context) {
         return
     }
 
-    c.JSON(http.StatusOK, APIResponse{
+    c.JSON(http.StatusAccepted, APIResponse{
         Success: true,
-        Message: "Successfully updated product embeddings",
-        Data:    nil,
+        Message: "Embedding update accepted for processing",
+        Data:    gin.H{"job_id": job.ID, "job": job},
         Errors:  nil,
         Meta: MetaData{
             RequestID: c.GetHeader("X-Request-ID"),
@@ -236,9 +98,38 @@ func UpdateProductEmbeddings(c *gin.Context) {
 }
 
 
+// SearchFilters narrows a search; every filter is optional
+type SearchFilters struct {
+    // Categories match category names, slugs or aliases, including subcategories
+    Categories []string          `json:"categories,omitempty"`
+    // CategoryID matches a category of the seller's tree and everything below it
+    CategoryID *int64            `json:"category_id,omitempty"`
+    MinPrice   *float64          `json:"min_price,omitempty" binding:"omitempty,min=0"`
+    MaxPrice   *float64          `json:"max_price,omitempty" binding:"omitempty,min=0"`
+    SellerID   *int64            `json:"seller_id,omitempty"`
+    Available  *bool             `json:"available,omitempty"`
+    // InStock matches products that can be ordered now: available, and with
+    // unreserved stock left in the product or one of its variants
+    InStock    *bool             `json:"in_stock,omitempty"`
+    // AvailableAt matches products on the menu at that moment, by their
+    // own or their category's availability schedule
+    AvailableAt *time.Time       `json:"available_at,omitempty"`
+    Attributes map[string]string `json:"attributes,omitempty"`
+    // Variant matches products with a variant having these options, e.g. {"size": "large"}
+    Variant map[string]string `json:"variant,omitempty"`
+}
+
+// SearchRequest searches by query, or browses the catalog by filters when
+// the query is empty
 type SearchRequest struct {
-    Query string `json:"query" binding:"required"`
-    Limit int    `json:"limit,omitempty"`
+    Query   string        `json:"query"`
+    Limit   int           `json:"limit,omitempty" binding:"omitempty,max=100"`
+    Mode    string        `json:"mode,omitempty" binding:"omitempty,oneof=vector text hybrid"`
+    Filters SearchFilters `json:"filters"`
+    Sort    string        `json:"sort,omitempty" binding:"omitempty,oneof=relevance price_asc price_desc newest"`
+    Cursor  string        `json:"cursor,omitempty"`
+    // Marketplace searches every seller's catalog; super admins only
+    Marketplace bool `json:"marketplace,omitempty"`
 }
 
 type SearchResult struct {
@@ -246,11 +137,23 @@ type SearchResult struct {
     Name        string  `json:"name"`
     Category    string  `json:"category"`
     Price       float64 `json:"price"`
+    EffectivePrice *float64 `json:"effective_price,omitempty"` // After the best running promotion, when one lowers the price
     Description string  `json:"description"`
+    Available   bool    `json:"available"`
+    InStock     bool    `json:"in_stock"`
+    Stock       *int    `json:"stock,omitempty"` // Unreserved stock; nil when not tracked
+    AvailableNow bool   `json:"available_now"` // On the menu now, or at the previewed time, by its schedule
+    Schedule    []model.AvailabilityWindow `json:"schedule,omitempty"` // Windows it is served in; empty is always
+    Variants    []model.ProductVariant `json:"variants,omitempty"`
+    Images      []model.ProductImage   `json:"images,omitempty"`
+    Promotions  []model.PromotionRule  `json:"promotions,omitempty"` // Promotions running now
     Similarity  float64 `json:"similarity"`
+    Score       float64 `json:"score"`
+    RerankScore *float64 `json:"rerank_score,omitempty"`
 }
 
 func SearchProducts(c *gin.Context) {
+    started := time.Now()
     var req SearchRequest
     if err := c.ShouldBindJSON(&req); err != nil {
         c.JSON(http.StatusBadRequest, APIResponse{
@@ -268,19 +171,111 @@ func SearchProducts(c *gin.Context) {
         return
     }
 
+    if req.Cursor != "" {
+        if _, err := decodeSearchCursor(req.Cursor); err != nil {
+            c.JSON(http.StatusBadRequest, APIResponse{
+                Success: false,
+                Message: "Invalid request parameters",
+                Data:    nil,
+                Errors: gin.H{
+                    "validation_error": err.Error(),
+                },
+                Meta: MetaData{
+                    RequestID: c.GetHeader("X-Request-ID"),
+                    Timestamp: time.Now().UTC().Format(time.RFC3339),
+                },
+            })
+            return
+        }
+    }
+
+    // Only search the caller's seller, or the storefront they are on
+    sellerID, ok := searchScope(c, req.Filters.SellerID, req.Marketplace)
+    if !ok {
+        return
+    }
+    req.Filters.SellerID = nil
+
+    // Embed the query with the model the seller's catalog is searched with so it is
+    // comparable with the stored product vectors. Marketplace searches use the default
+    // model and only reach products embedded with it. The request mode overrides the configured one.
+    embeddingModel := defaultEmbeddingModel
+    mode := searchModeHybrid
+    limit := 5 // default limit
+    if sellerID != nil {
+        if config, err := getConfigurationForUser(c.Request.Context(), *sellerID); err == nil {
+            if config.SearchMode != "" {
+                mode = config.SearchMode
+            }
+            if config.TopK > 0 {
+                limit = config.TopK
+            }
+        }
+        var err error
+        embeddingModel, err = resolveSearchModel(c.Request.Context(), *sellerID)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, APIResponse{
+                Success: false,
+                Message: "Search query failed",
+                Data:    nil,
+                Errors: gin.H{
+                    "database_error": err.Error(),
+                },
+                Meta: MetaData{
+                    RequestID: c.GetHeader("X-Request-ID"),
+                    Timestamp: time.Now().UTC().Format(time.RFC3339),
+                },
+            })
+            return
+        }
+    }
     if req.Limit <= 0 {
-        req.Limit = 5 // default limit
+        req.Limit = limit
+    }
+    if req.Mode != "" {
+        mode = req.Mode
     }
 
-    // Get embedding for the search query
-    queryEmbedding, err := getEmbedding(req.Query, "text-embedding-3-small")
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, APIResponse{
+    // Get embedding for the search query; text-only search and browsing don't need one
+    var queryEmbedding []float32
+    if mode != searchModeText && req.Query != "" {
+        var err error
+        queryEmbedding, err = getEmbedding(req.Query, embeddingModel)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, APIResponse{
+                Success: false,
+                Message: "Failed to process search query",
+                Data:    nil,
+                Errors: gin.H{
+                    "embedding_error": fmt.Sprintf("Failed to generate embedding: %v", err),
+                },
+                Meta: MetaData{
+                    RequestID: c.GetHeader("X-Request-ID"),
+                    Timestamp: time.Now().UTC().Format(time.RFC3339),
+                },
+            })
+            return
+        }
+    }
+
+    page, err := searchProducts(c.Request.Context(), productQuery{
+        Mode:      mode,
+        Text:      req.Query,
+        Embedding: queryEmbedding,
+        Model:     embeddingModel,
+        SellerID:  sellerID,
+        Filters:   req.Filters,
+        Sort:      req.Sort,
+        Cursor:    req.Cursor,
+        Limit:     req.Limit,
+    })
+    if errors.Is(err, errInvalidCursor) {
+        c.JSON(http.StatusBadRequest, APIResponse{
             Success: false,
-            Message: "Failed to process search query",
+            Message: "Invalid request parameters",
             Data:    nil,
             Errors: gin.H{
-                "embedding_error": fmt.Sprintf("Failed to generate embedding: %v", err),
+                "validation_error": err.Error(),
             },
             Meta: MetaData{
                 RequestID: c.GetHeader("X-Request-ID"),
@@ -289,22 +284,13 @@ func SearchProducts(c *gin.Context) {
         })
         return
     }
-
-    // Convert the embedding to PostgreSQL vector format
-    vectorStr := fmt.Sprintf("[%s]", formatFloatSlice(queryEmbedding))
-
-    // Query the database using vector similarity
-    ctx := context.Background()
-    dbURL := os.Getenv("DATABASE_URL")
-    pool, err := pgxpool.New(ctx, dbURL)
     if err != nil {
         c.JSON(http.StatusInternalServerError, APIResponse{
             Success: false,
-            Message: "Database connection error",
+            Message: "Search query failed",
             Data:    nil,
             Errors: gin.H{
-                "database_error": "Failed to connect to database",
-                "details":       err.Error(),
+                "database_error": err.Error(),
             },
             Meta: MetaData{
                 RequestID: c.GetHeader("X-Request-ID"),
@@ -313,45 +299,27 @@ func SearchProducts(c *gin.Context) {
         })
         return
     }
-    defer pool.Close()
 
-    rows, err := pool.Query(ctx, `
-        SELECT id, name, category, price, description, 1 - (embedding <=> $1::vector) as similarity
-        FROM products
-        WHERE embedding IS NOT NULL
-        ORDER BY embedding <=> $1::vector
-        LIMIT $2
-    `, vectorStr, req.Limit)
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Search query failed: %v", err)})
-        return
-    }
-    defer rows.Close()
-
-    var results []SearchResult
-    for rows.Next() {
-        var result SearchResult
-        err := rows.Scan(&result.ID, &result.Name, &result.Category, &result.Price, &result.Description, &result.Similarity)
-        if err != nil {
-            c.JSON(http.StatusInternalServerError, APIResponse{
-                Success: false,
-                Message: "Error scanning search results",
-                Data:    nil,
-                Errors:  err.Error(),
-                Meta: MetaData{
-                    RequestID: c.GetHeader("X-Request-ID"),
-                    Timestamp: time.Now().UTC().Format(time.RFC3339),
-                },
-            })
-            return
-        }
-        results = append(results, result)
+    // Log new searches for analytics; browsing and later pages aren't searches
+    if req.Query != "" && req.Cursor == "" {
+        logSearch(model.SearchLog{
+            SellerID: sellerID,
+            UserID:   c.MustGet("user_id").(int64),
+            Source:   model.SearchSourceSearch,
+            Query:    req.Query,
+            Mode:     mode,
+        }, started, page.Results)
     }
 
     c.JSON(http.StatusOK, APIResponse{
         Success: true,
         Message: "Products retrieved successfully",
-        Data:    gin.H{"products": results},
+        Data: gin.H{
+            "products":    page.Results,
+            "mode":        mode,
+            "total":       page.Total,
+            "next_cursor": page.NextCursor,
+        },
         Errors:  nil,
         Meta: MetaData{
             RequestID: c.GetHeader("X-Request-ID"),
@@ -388,6 +356,7 @@ type OpenAIResponse struct {
 }
 
 func ChatWithProducts(c *gin.Context) {
+    started := time.Now()
     var req ChatRequest
     if err := c.ShouldBindJSON(&req); err != nil {
         c.JSON(http.StatusBadRequest, APIResponse{
@@ -404,10 +373,16 @@ func ChatWithProducts(c *gin.Context) {
         return
     }
 
-    // Get user configuration
+    // Chat about the caller's own catalog, or the storefront a customer is on
     userID := c.MustGet("user_id").(int64)
-    
-    config, err := getConfigurationForUser(c.Request.Context(), userID)
+    scope, ok := searchScope(c, nil, false)
+    if !ok {
+        return
+    }
+    sellerID := *scope
+
+    // Get the seller's configuration
+    config, err := getConfigurationForUser(c.Request.Context(), sellerID)
     if err != nil {
         c.JSON(http.StatusInternalServerError, APIResponse{
             Success: false,
@@ -423,14 +398,14 @@ func ChatWithProducts(c *gin.Context) {
         return
     }
 
-    // Get embedding for the question
-    queryEmbedding, err := getEmbedding(req.Question, config.OpenAIEmbeddingModel)
+    // Search with the model this seller's catalog is currently embedded with
+    embeddingModel, err := resolveSearchModel(c.Request.Context(), sellerID)
     if err != nil {
         c.JSON(http.StatusInternalServerError, APIResponse{
             Success: false,
-            Message: "Failed to process question",
+            Message: "Search query failed",
             Errors: gin.H{
-                "embedding_error": fmt.Sprintf("Failed to generate embedding: %v", err),
+                "database_error": err.Error(),
             },
             Meta: MetaData{
                 RequestID: c.GetHeader("X-Request-ID"),
@@ -440,46 +415,52 @@ func ChatWithProducts(c *gin.Context) {
         return
     }
 
-    // Convert the embedding to PostgreSQL vector format
-    vectorStr := fmt.Sprintf("[%s]", formatFloatSlice(queryEmbedding))
+    mode := config.SearchMode
+    if mode == "" {
+        mode = searchModeHybrid
+    }
 
-    // Query the database using vector similarity
-    ctx := context.Background()
-    dbURL := os.Getenv("DATABASE_URL")
-    pool, err := pgxpool.New(ctx, dbURL)
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, APIResponse{
-            Success: false,
-            Message: "Database connection error",
-            Errors: gin.H{
-                "database_error": "Failed to connect to database",
-                "details":       err.Error(),
-            },
-            Meta: MetaData{
-                RequestID: c.GetHeader("X-Request-ID"),
-                Timestamp: time.Now().UTC().Format(time.RFC3339),
-            },
-        })
-        return
+    // Get embedding for the question; text-only retrieval doesn't need one
+    var queryEmbedding []float32
+    if mode != searchModeText {
+        queryEmbedding, err = getEmbedding(req.Question, embeddingModel)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, APIResponse{
+                Success: false,
+                Message: "Failed to process question",
+                Errors: gin.H{
+                    "embedding_error": fmt.Sprintf("Failed to generate embedding: %v", err),
+                },
+                Meta: MetaData{
+                    RequestID: c.GetHeader("X-Request-ID"),
+                    Timestamp: time.Now().UTC().Format(time.RFC3339),
+                },
+            })
+            return
+        }
     }
-    defer pool.Close()
-
-    // Enhanced RAG: Get more relevant products with better similarity threshold, only for this seller
-    rows, err := pool.Query(ctx, `
-        SELECT id, name, category, price, description, 1 - (embedding <=> $1::vector) as similarity
-        FROM products
-        WHERE embedding IS NOT NULL
-        AND seller_id = $2
-        AND 1 - (embedding <=> $1::vector) > 0.3
-        ORDER BY embedding <=> $1::vector
-        LIMIT 5
-    `, vectorStr, userID)
+
+    // Enhanced RAG: Get the configured number of products above the similarity threshold, only for this seller.
+    // Constraints stated in the question ("di bawah 50 ribu") become filters.
+    // Extra candidates are fetched for the reranker to choose from.
+    limit := config.TopK
+    retrieval := productQuery{
+        Mode:          mode,
+        Text:          req.Question,
+        Embedding:     queryEmbedding,
+        Model:         embeddingModel,
+        SellerID:      &sellerID,
+        Filters:       extractQueryFilters(req.Question),
+        MinSimilarity: config.SimilarityThreshold,
+        Limit:         rerankCandidates(config, limit),
+    }
+    page, err := searchProducts(c.Request.Context(), retrieval)
     if err != nil {
         c.JSON(http.StatusInternalServerError, APIResponse{
             Success: false,
             Message: "Search query failed",
             Errors: gin.H{
-                "database_error": fmt.Sprintf("Search query failed: %v", err),
+                "database_error": err.Error(),
             },
             Meta: MetaData{
                 RequestID: c.GetHeader("X-Request-ID"),
@@ -488,52 +469,45 @@ func ChatWithProducts(c *gin.Context) {
         })
         return
     }
-    defer rows.Close()
 
-    var results []SearchResult
-    for rows.Next() {
-        var result SearchResult
-        err := rows.Scan(&result.ID, &result.Name, &result.Category, &result.Price, &result.Description, &result.Similarity)
-        if err != nil {
-            c.JSON(http.StatusInternalServerError, APIResponse{
-                Success: false,
-                Message: "Error processing search results",
-                Errors: gin.H{
-                    "database_error": fmt.Sprintf("Error scanning results: %v", err),
-                },
-                Meta: MetaData{
-                    RequestID: c.GetHeader("X-Request-ID"),
-                    Timestamp: time.Now().UTC().Format(time.RFC3339),
-                },
-            })
-            return
-        }
-        results = append(results, result)
-    }
-
-    // If no relevant products found, try a broader search (still only for this seller)
-    if len(results) == 0 {
-        rows, err := pool.Query(ctx, `
-            SELECT id, name, category, price, description, 1 - (embedding <=> $1::vector) as similarity
-            FROM products
-            WHERE embedding IS NOT NULL
-            AND seller_id = $2
-            ORDER BY embedding <=> $1::vector
-            LIMIT 3
-        `, vectorStr, userID)
+    results := page.Results
+
+    // If no relevant products found, try a broader search if configured (still only for this seller).
+    // The asked-for product is likely absent, so these are presented as the closest alternatives.
+    closestOnly := false
+    if len(results) == 0 && config.FallbackMode == "broader" {
+        limit = config.FallbackTopK
+        retrieval.MinSimilarity = 0
+        retrieval.Limit = rerankCandidates(config, limit)
+        broader, err := searchProducts(c.Request.Context(), retrieval)
         if err == nil {
-            defer rows.Close()
-            for rows.Next() {
-                var result SearchResult
-                if err := rows.Scan(&result.ID, &result.Name, &result.Category, &result.Price, &result.Description, &result.Similarity); err == nil {
-                    results = append(results, result)
-                }
-            }
+            results = broader.Results
+            closestOnly = len(results) > 0
         }
     }
 
+    // Rerank the candidates and keep the best ones for the prompt
+    results, rerankedBy := rerankResults(c.Request.Context(), config, req.Question, results, limit)
+
+    logSearch(model.SearchLog{
+        SellerID: &sellerID,
+        UserID:   userID,
+        Source:   model.SearchSourceChat,
+        Query:    req.Question,
+        Mode:     mode,
+    }, started, results)
+
+    // Suggest available substitutes for matches that are out of stock
+    found := chatProducts{
+        Results:     results,
+        Substitutes: findSubstitutes(c.Request.Context(), results),
+        ClosestOnly: closestOnly,
+    }
+    // The store's categories help answer "what do you sell" style questions
+    found.Categories, _ = service.NewCategoryService().CategoryTree(c.Request.Context(), sellerID)
+
     // Generate enhanced context for OpenAI with better formatting
-    context := generateEnhancedContext(req.Question, results)
+    context := generateEnhancedContext(req.Question, found, config)
 
     // Call OpenAI API for response generation
     answer, err := generateOpenAIResponse(context, config)
@@ -560,6 +534,8 @@ func ChatWithProducts(c *gin.Context) {
             "answer": answer,
             "relevant_products": results,
             "products_count": len(results),
+            "substitutes": found.Substitutes,
+            "reranker": rerankedBy,
         },
         Errors: nil,
         Meta: MetaData{
@@ -569,42 +545,206 @@ func ChatWithProducts(c *gin.Context) {
     })
 }
 
-func generateEnhancedContext(question string, products []SearchResult) string {
+// chatProducts is what the chat pipeline retrieved for a question
+type chatProducts struct {
+    Results     []SearchResult
+    Substitutes map[int64][]SearchResult // Available alternatives per out-of-stock result
+    ClosestOnly bool                     // Nothing matched; Results are the nearest alternatives
+    Categories  []*model.Category        // The seller's category tree
+}
+
+// maxCategoryOutline caps the category outline in the chat context, in characters
+const maxCategoryOutline = 500
+
+// categoryOutline lists a category tree compactly, e.g.
+// "Minuman (Kopi, Teh), Makanan". Categories past the length cap are
+// counted instead of listed.
+func categoryOutline(tree []*model.Category) string {
+    var outline strings.Builder
+    for i, c := range tree {
+        entry := c.Name
+        if len(c.Children) > 0 {
+            names := make([]string, len(c.Children))
+            for j, child := range c.Children {
+                names[j] = child.Name
+            }
+            entry += " (" + strings.Join(names, ", ") + ")"
+        }
+        if i > 0 {
+            entry = ", " + entry
+        }
+        if i > 0 && outline.Len()+len(entry) > maxCategoryOutline {
+            outline.WriteString(fmt.Sprintf(", and %d more", len(tree)-i))
+            break
+        }
+        outline.WriteString(entry)
+    }
+    return outline.String()
+}
+
+// lowStock reports whether a tracked stock level is running low but not out
+func lowStock(stock *int) bool {
+    return stock != nil && *stock > 0 && *stock <= model.LowStockThreshold
+}
+
+// promoPrice formats a price, showing the promotion price first when there is one
+func promoPrice(price float64, effective *float64) string {
+    if effective == nil {
+        return fmt.Sprintf("Rp %.2f", price)
+    }
+    return fmt.Sprintf("Rp %.2f (promo, normally Rp %.2f)", *effective, price)
+}
+
+// promotionSummary describes the promotions running on a product, e.g.
+// "Promo Weekend: Diskon 10% (until 2026-10-20 23:59)". Promotions whose
+// description contains a price are left out when prices aren't shown.
+func promotionSummary(rules []model.PromotionRule, includePrices bool) string {
+    var parts []string
+    for _, r := range rules {
+        if r.QuotesPrice() && !includePrices {
+            continue
+        }
+        part := r.Label()
+        if r.Name != "" && !strings.EqualFold(r.Name, part) {
+            part = r.Name + ": " + part
+        }
+        if r.EndsAt != nil {
+            part += " (until " + r.EndsAt.Format("2006-01-02 15:04") + ")"
+        }
+        parts = append(parts, part)
+    }
+    return strings.Join(parts, "; ")
+}
+
+// generateEnhancedContext builds the prompt context from the retrieved
+// products. Products are listed until the configured character budget runs
+// out; prices are left out when the configuration says so.
+func generateEnhancedContext(question string, found chatProducts, config *model.UserConfiguration) string {
+    products := found.Results
+    categories := ""
+    if len(found.Categories) > 0 {
+        categories = "Store categories: " + categoryOutline(found.Categories) + "\n\n"
+    }
     if len(products) == 0 {
         return fmt.Sprintf(`Question: %s
 
-Note: No specific products found in our database that match your query. I'll provide general assistance based on your question.`, question)
+%sNote: No specific products found in our database that match your query. I'll provide general assistance based on your question.`, question, categories)
     }
 
     var context strings.Builder
     context.WriteString(fmt.Sprintf("Question: %s\n\n", question))
-    context.WriteString("Relevant products from our database:\n")
+    context.WriteString(categories)
+    if found.ClosestOnly {
+        context.WriteString("No product in our database matches the question exactly. Closest alternatives:\n")
+    } else {
+        context.WriteString("Relevant products from our database:\n")
+    }
     context.WriteString(strings.Repeat("=", 50) + "\n")
     
+    budget := config.MaxContextChars
+    listed := 0
+    hasVariants, hasSoldOut, hasPromotions, hasOffMenu := false, false, false, false
     for i, p := range products {
-        context.WriteString(fmt.Sprintf(
-            "Product %d:\n"+
-            "- Name: %s\n"+
-            "- Category: %s\n"+
-            "- Price: Rp %.2f\n"+
-            "- Description: %s\n"+
-            "- Relevance Score: %.2f\n",
-            i+1,
-            p.Name,
-            p.Category,
-            p.Price,
-            p.Description,
-            p.Similarity,
-        ))
-        if i < len(products)-1 {
-            context.WriteString("\n")
+        var entry strings.Builder
+        if i > 0 {
+            entry.WriteString("\n")
+        }
+        entry.WriteString(fmt.Sprintf("Product %d:\n", i+1))
+        entry.WriteString(fmt.Sprintf("- Name: %s\n", p.Name))
+        entry.WriteString(fmt.Sprintf("- Category: %s\n", p.Category))
+        if config.IncludePrices {
+            entry.WriteString("- Price: " + promoPrice(p.Price, p.EffectivePrice) + "\n")
+        }
+        if promos := promotionSummary(p.Promotions, config.IncludePrices); promos != "" {
+            entry.WriteString("- Promo: " + promos + "\n")
+        }
+        if len(p.Variants) > 0 {
+            entry.WriteString("- Variants:\n")
+            for _, v := range p.Variants {
+                entry.WriteString("  - " + v.Name)
+                if config.IncludePrices {
+                    entry.WriteString(": " + promoPrice(v.Price, v.EffectivePrice))
+                }
+                switch {
+                case !v.InStock():
+                    entry.WriteString(" (habis)")
+                case lowStock(v.AvailableStock):
+                    entry.WriteString(fmt.Sprintf(" (tinggal %d)", *v.AvailableStock))
+                }
+                entry.WriteString("\n")
+            }
+        }
+        entry.WriteString(fmt.Sprintf("- Description: %s\n", p.Description))
+        entry.WriteString(fmt.Sprintf("- Relevance Score: %.2f\n", p.Similarity))
+        if lowStock(p.Stock) && p.InStock {
+            entry.WriteString(fmt.Sprintf("- Stock: tinggal %d\n", *p.Stock))
+        }
+        if !p.AvailableNow {
+            entry.WriteString(fmt.Sprintf("- Availability: Not served now; served %s (store time)\n", model.DescribeSchedule(p.Schedule)))
+        }
+        if !p.InStock {
+            entry.WriteString("- Availability: Habis (out of stock)\n")
+        }
+        if !p.InStock || !p.AvailableNow {
+            if subs := found.Substitutes[p.ID]; len(subs) > 0 {
+                names := make([]string, len(subs))
+                for j, sub := range subs {
+                    names[j] = sub.Name
+                    if config.IncludePrices {
+                        names[j] += " (" + promoPrice(sub.Price, sub.EffectivePrice) + ")"
+                    }
+                }
+                entry.WriteString(fmt.Sprintf("- Available substitutes: %s\n", strings.Join(names, ", ")))
+            }
+        }
+
+        // Always list the best match, even if it alone is over budget
+        if budget > 0 && listed > 0 && entry.Len() > budget {
+            break
+        }
+        context.WriteString(entry.String())
+        budget -= entry.Len()
+        listed++
+        hasVariants = hasVariants || len(p.Variants) > 0
+        hasPromotions = hasPromotions || promotionSummary(p.Promotions, config.IncludePrices) != ""
+        hasSoldOut = hasSoldOut || !p.InStock
+        hasOffMenu = hasOffMenu || !p.AvailableNow
+        for _, v := range p.Variants {
+            hasSoldOut = hasSoldOut || !v.InStock()
         }
     }
+    if listed < len(products) {
+        context.WriteString(fmt.Sprintf("\n(%d more matching products not shown)\n", len(products)-listed))
+    }
     
     context.WriteString("\n" + strings.Repeat("=", 50) + "\n")
     context.WriteString("Instructions: Based on the user's question and the relevant products above, provide a helpful and accurate response. ")
     context.WriteString("If the products don't match the user's needs, suggest alternatives or ask for clarification. ")
     context.WriteString("Always mention specific product names when making recommendations.")
+    if hasVariants && config.IncludePrices {
+        context.WriteString(" For products with variants, quote the price of the variant the customer asks about, and ask which variant they want if it's unclear.")
+    }
+    if hasSoldOut {
+        context.WriteString(" Never offer products or variants marked habis; they are sold out and cannot be ordered.")
+    }
+    if hasOffMenu {
+        context.WriteString(" Products marked not served now cannot be ordered at the moment; tell the customer when they are served instead.")
+    }
+    if len(found.Substitutes) > 0 {
+        context.WriteString(" Offer the available substitutes of sold-out or unserved products instead.")
+    }
+    if hasPromotions {
+        context.WriteString(" Mention running promos when they are relevant, and explain what quantity a bundle or buy-get-free promo needs.")
+        if config.IncludePrices {
+            context.WriteString(" Quote promo prices rather than normal prices.")
+        }
+    }
+    if found.ClosestOnly {
+        context.WriteString(" Tell the customer the exact product isn't available before suggesting the alternatives.")
+    }
+    if !config.IncludePrices {
+        context.WriteString(" Do not quote prices; ask the customer to check with the seller instead.")
+    }
     
     return context.String()
 }
@@ -714,8 +854,8 @@ func CORSMiddleware() gin.HandlerFunc {
     return func(c *gin.Context) {
         c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
         c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-        c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Request-ID, accept, origin, Cache-Control, X-Requested-With")
-        c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+        c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Request-ID, X-Seller-ID, accept, origin, Cache-Control, X-Requested-With")
+        c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
         c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length")
         c.Writer.Header().Set("Access-Control-Max-Age", "86400")
 
@@ -0,0 +1,155 @@
+package v1
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/divinecoid/oneagent/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// maxImagesPerUpload caps how many files one upload request may carry
+const maxImagesPerUpload = 10
+
+// imageError writes the response for a failed image operation
+func imageError(c *gin.Context, err error, message string) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrImageNotFound):
+		status, message = http.StatusNotFound, "Image not found"
+	case errors.Is(err, service.ErrInvalidImage):
+		status, message = http.StatusBadRequest, "Unsupported image"
+	case errors.Is(err, service.ErrImageTooLarge):
+		status, message = http.StatusRequestEntityTooLarge, "Image is too large"
+	}
+	c.JSON(status, APIResponse{
+		Success: false,
+		Message: message,
+		Errors:  gin.H{"error": err.Error()},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// UploadProductImages adds the images in the multipart "image" field (which
+// may be repeated) to a product. A thumbnail is generated for each; images
+// the product already has are returned without being stored again.
+func (h *ProductHandler) UploadProductImages(c *gin.Context) {
+	product, ok := h.loadProduct(c)
+	if !ok {
+		return
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["image"]) == 0 {
+		productValidationError(c, "image is required")
+		return
+	}
+	files := form.File["image"]
+	if len(files) > maxImagesPerUpload {
+		productValidationError(c, "at most "+strconv.Itoa(maxImagesPerUpload)+" images can be uploaded at once")
+		return
+	}
+
+	images := make([]model.ProductImage, 0, len(files))
+	added := 0
+	for _, file := range files {
+		if file.Size > service.MaxImageBytes {
+			imageError(c, service.ErrImageTooLarge, "Failed to upload image")
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			imageError(c, err, "Failed to open image")
+			return
+		}
+		data, err := io.ReadAll(io.LimitReader(f, service.MaxImageBytes+1))
+		f.Close()
+		if err != nil {
+			imageError(c, err, "Failed to read image")
+			return
+		}
+		image, isNew, err := h.imageService.AddImage(c.Request.Context(), product.ID, data)
+		if err != nil {
+			imageError(c, err, "Failed to upload image "+file.Filename)
+			return
+		}
+		images = append(images, *image)
+		if isNew {
+			added++
+		}
+	}
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Images uploaded successfully",
+		Data:    gin.H{"product_id": product.ID, "added": added, "images": images},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// DeleteProductImage removes an image from a product along with its files
+func (h *ProductHandler) DeleteProductImage(c *gin.Context) {
+	imageID, err := strconv.ParseInt(c.Param("image_id"), 10, 64)
+	if err != nil {
+		productValidationError(c, "image ID must be a number")
+		return
+	}
+	product, ok := h.loadProduct(c)
+	if !ok {
+		return
+	}
+	if err := h.imageService.DeleteImage(c.Request.Context(), product.ID, imageID); err != nil {
+		imageError(c, err, "Failed to delete image")
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Image deleted successfully",
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// ServeFile serves a product image from the local blob store. It is public
+// so images can be shown in the widget and sent to WhatsApp; when the store
+// signs its URLs, the expires and signature parameters must be valid. Other
+// blobs, such as imported catalogs, are not served here.
+func ServeFile(c *gin.Context) {
+	store, ok := service.Blobs().(*service.LocalBlobStore)
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if !ok || !service.IsPublicBlob(key) || !store.Verify(key, c.Query("expires"), c.Query("signature")) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	f, err := store.Open(c.Request.Context(), key)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	// Keys are derived from file contents, so a key never changes what it serves
+	c.Header("Cache-Control", "public, max-age=86400, immutable")
+	c.DataFromReader(http.StatusOK, -1, contentType, f, nil)
+}
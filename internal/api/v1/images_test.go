@@ -0,0 +1,50 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/divinecoid/oneagent/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// TestServeFileOnlyServesProductImages checks that catalogs kept for imports
+// can't be fetched through the public file route
+func TestServeFileOnlyServesProductImages(t *testing.T) {
+	t.Setenv("BLOB_LOCAL_DIR", t.TempDir())
+	store, ok := service.Blobs().(*service.LocalBlobStore)
+	if !ok {
+		t.Skip("blob store isn't local")
+	}
+	if len(store.SigningKey) > 0 {
+		t.Skip("BLOB_SIGNING_KEY is set")
+	}
+	ctx := context.Background()
+	for _, key := range []string{"products/7/abc.jpg", "imports/7/def.csv"} {
+		if err := store.Put(ctx, key, []byte("data"), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/files/*key", ServeFile)
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/files/products/7/abc.jpg", http.StatusOK},
+		{"/files/imports/7/def.csv", http.StatusNotFound},
+		{"/files/products/../imports/7/def.csv", http.StatusNotFound},
+		{"/files/products/7/missing.jpg", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.want {
+			t.Errorf("GET %s = %d, want %d", tt.path, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,371 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/divinecoid/oneagent/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// runProductImportJob applies the rows of an uploaded catalog to the job's
+// seller's products, recorded as an import batch that can be rolled back.
+// Rows that can't be imported are logged on the job once the import has
+// committed, and the per-row report is stored as the job's result. An
+// attempt after the import committed only finishes the job from its batch,
+// so a retry never imports the file twice.
+func runProductImportJob(ctx context.Context, job *model.Job, r *service.JobReporter) error {
+	var payload productImportPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid job payload: %w", err)
+	}
+	if job.SellerID == nil {
+		return fmt.Errorf("product import job has no seller")
+	}
+	committed, err := service.NewImportService().GetJobBatch(ctx, job.ID)
+	switch {
+	case err == nil && committed.Report != nil:
+		// An earlier attempt committed the import but didn't finish the job
+		r.Succeeded(committed.Report.Accepted)
+		for range committed.Report.Rejected {
+			r.CountFailed()
+		}
+		return finishImportJob(ctx, r, committed.Report)
+	case err == nil:
+		return fmt.Errorf("import batch %d has no report", committed.ID)
+	case !errors.Is(err, service.ErrImportBatchNotFound):
+		return err
+	}
+
+	if payload.Format == "" {
+		payload.Format = service.FormatXLSX
+	}
+	if payload.Mode == "" {
+		payload.Mode = model.ImportModeAppend
+	}
+	file := catalogFile{Format: payload.Format, Sheet: payload.Sheet, Data: job.Input}
+	// The count includes blank rows; it is corrected once the file has been read
+	total, err := service.CountProductRows(file.Format, file.Data, file.Sheet)
+	if err != nil {
+		return err
+	}
+	if err := r.SetTotal(ctx, total); err != nil {
+		return err
+	}
+
+	batch := &model.ImportBatch{JobID: &job.ID, FileName: payload.FileName, Format: payload.Format, CreatedBy: job.CreatedBy}
+	report, err := applyImport(ctx, *job.SellerID, payload.Mode, job.CreatedBy, file, false, r, batch)
+	if err != nil {
+		return err
+	}
+	return finishImportJob(ctx, r, report)
+}
+
+// finishImportJob logs the rejected rows of a committed import on its job
+// and stores the report as the job's result
+func finishImportJob(ctx context.Context, r *service.JobReporter, report *model.ImportReport) error {
+	for _, row := range report.Rows {
+		if row.Status == model.ImportRowRejected {
+			r.LogError(ctx, fmt.Sprintf("row %d", row.Row), errors.New(strings.Join(row.Errors, "; ")))
+		}
+	}
+	// The import is committed, so a late cancellation no longer matters
+	if err := r.SetTotal(ctx, report.Accepted+report.Rejected); err != nil && !errors.Is(err, service.ErrJobCancelled) {
+		return err
+	}
+	return r.SetResult(ctx, report)
+}
+
+// catalogFile is an uploaded catalog to import
+type catalogFile struct {
+	Format string
+	Sheet  string // XLSX only; empty for the first sheet with a header
+	Data   []byte
+}
+
+// applyImport reads a catalog file and applies its accepted rows to a
+// seller's catalog in one transaction, returning what happened to every row.
+// Rows are read one at a time and applied in chunks, so a file never has to
+// fit in memory as a table. A dry run does the same work and rolls it back,
+// so its counts are exactly what a real import would do; only its report
+// keeps the parsed products. Stock changes are logged as made by userID. r
+// may be nil when there's no job to report progress to. Unless batch is nil,
+// the import is recorded as an import batch read from the file, and batch.ID
+// is set.
+func applyImport(ctx context.Context, sellerID int64, mode string, userID int64, file catalogFile, dryRun bool,
+	r *service.JobReporter, batch *model.ImportBatch) (*model.ImportReport, error) {
+	scan, err := service.ScanProductFile(file.Format, file.Data, file.Sheet)
+	if err != nil {
+		return nil, err
+	}
+	defer scan.Close()
+	report := scan.Report()
+	report.Mode, report.DryRun = mode, dryRun
+
+	imp, err := service.NewProductService().BeginImport(ctx, sellerID, mode, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer imp.Rollback(ctx)
+	if batch != nil && !dryRun {
+		if err := imp.RecordBatch(ctx, batch, file.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	chunk := make([]*model.ImportRow, 0, service.ImportChunkSize)
+	for more := true; more; {
+		if more = scan.Next(); more {
+			chunk = append(chunk, scan.Row())
+		}
+		if len(chunk) == service.ImportChunkSize || (!more && len(chunk) > 0) {
+			if err := applyImportChunk(ctx, imp, report, chunk, r); err != nil {
+				return nil, err
+			}
+			chunk = chunk[:0]
+		}
+	}
+	if err := scan.Err(); err != nil {
+		return nil, err
+	}
+
+	if report.Deactivated, err = imp.DeactivateMissing(ctx); err != nil {
+		return nil, err
+	}
+	report.CategoriesCreated = imp.CategoriesCreated()
+	if dryRun {
+		return report, nil
+	}
+	if err := imp.FinishBatch(ctx, report); err != nil {
+		return nil, err
+	}
+	return report, imp.Commit(ctx)
+}
+
+// applyImportChunk applies the accepted rows among a chunk of a catalog,
+// then adds every row of the chunk to the report
+func applyImportChunk(ctx context.Context, imp *service.ProductImport, report *model.ImportReport, chunk []*model.ImportRow,
+	r *service.JobReporter) error {
+	var accepted []*model.ImportRow
+	wasAccepted := make([]bool, len(chunk))
+	for i, row := range chunk {
+		if row.Status == model.ImportRowAccepted {
+			accepted = append(accepted, row)
+			wasAccepted[i] = true
+		} else {
+			imp.Keep(row.SKU)
+		}
+	}
+	if err := imp.ApplyRows(ctx, accepted); err != nil {
+		return err
+	}
+
+	for i, row := range chunk {
+		if row.Status == model.ImportRowRejected {
+			if wasAccepted[i] {
+				report.Accepted--
+				report.Rejected++
+			}
+			if r != nil {
+				r.CountFailed() // Logged on the job once the import commits
+			}
+			report.Rows = append(report.Rows, *row)
+			continue
+		}
+
+		switch row.Action {
+		case model.ImportActionCreated:
+			report.Created++
+		case model.ImportActionUpdated:
+			report.Updated++
+		case model.ImportActionUnchanged:
+			report.Unchanged++
+		}
+		// Dry runs only count the pictures, so nothing is written to the blob store
+		if len(row.Images) > 0 && !report.DryRun {
+			added, err := imp.AttachImages(ctx, row.Product.ID, row.Images)
+			if err != nil {
+				row.Warnings = append(row.Warnings, fmt.Sprintf("images not saved: %v", err))
+			}
+			report.ImagesAdded += added
+		}
+		row.Images = nil
+		if !report.DryRun {
+			row.Product = nil // The import batch keeps the products it saved
+		}
+		report.Rows = append(report.Rows, *row)
+		if r != nil {
+			r.Succeeded(1)
+		}
+	}
+	if r != nil {
+		return r.Checkpoint(ctx)
+	}
+	return nil
+}
+
+// catalogFormat returns the requested catalog format, or guesses it from the
+// file name when none is given
+func catalogFormat(requested, fileName string) (string, error) {
+	format := strings.ToLower(strings.TrimSpace(requested))
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(fileName)) {
+		case ".xlsx", ".xlsm":
+			format = service.FormatXLSX
+		case ".csv", ".txt", ".tsv":
+			format = service.FormatCSV
+		case ".json":
+			format = service.FormatJSON
+		default:
+			return "", fmt.Errorf("can't tell the format of %q; set format to xlsx, csv or json", fileName)
+		}
+	}
+	switch format {
+	case service.FormatXLSX, service.FormatCSV, service.FormatJSON:
+		return format, nil
+	}
+	return "", fmt.Errorf("format must be xlsx, csv or json")
+}
+
+// catalogUpload reads the uploaded catalog in the file form field, refusing
+// files over the import size limit before they are read. It writes the error
+// response itself.
+func catalogUpload(c *gin.Context) (*multipart.FileHeader, []byte, bool) {
+	maxBytes, _ := service.ImportLimits()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+1<<20) // Room for the other form fields
+	file, err := c.FormFile("file")
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		err = fmt.Errorf("%w: over %d MB", service.ErrImportTooLarge, maxBytes>>20)
+	case err != nil:
+		productValidationError(c, "file is required")
+		return nil, nil, false
+	default:
+		err = service.CheckImportSize(file.Size)
+	}
+	if err != nil {
+		productError(c, err, "Catalog file too large")
+		return nil, nil, false
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		productError(c, err, "Failed to open file")
+		return nil, nil, false
+	}
+	defer f.Close()
+	input, err := io.ReadAll(f)
+	if err != nil {
+		productError(c, err, "Failed to read file")
+		return nil, nil, false
+	}
+	return file, input, true
+}
+
+// ImportProducts imports a catalog file into the caller's catalog (or, for
+// super admins, the seller_id form field's). The file is validated with the
+// same pipeline for every format and the row report is returned for a
+// dry_run; otherwise the file is queued as a product_import job whose result
+// holds the report. Form fields: file, format (xlsx, csv or json; guessed
+// from the file name when omitted), mode (append, upsert or replace), sheet,
+// dry_run and seller_id. Files over the size limit set by IMPORT_MAX_FILE_MB
+// are refused with 413.
+func (h *ProductHandler) ImportProducts(c *gin.Context) {
+	h.importCatalog(c, "")
+}
+
+// importCatalog serves ImportProducts and its aliases. defaultFormat is used
+// when the format field is empty; when it is empty too, the format is
+// guessed from the file name.
+func (h *ProductHandler) importCatalog(c *gin.Context, defaultFormat string) {
+	// Read first, so the size limit applies before the form is parsed
+	file, input, ok := catalogUpload(c)
+	if !ok {
+		return
+	}
+	requested, _ := strconv.ParseInt(c.PostForm("seller_id"), 10, 64)
+	sellerID, ok := productSeller(c, requested)
+	if !ok {
+		return
+	}
+	format, err := catalogFormat(c.DefaultPostForm("format", defaultFormat), file.Filename)
+	if err != nil {
+		productValidationError(c, err.Error())
+		return
+	}
+	mode := c.DefaultPostForm("mode", model.ImportModeAppend)
+	if !service.ValidImportMode(mode) {
+		productValidationError(c, "mode must be append, upsert or replace")
+		return
+	}
+
+	// Only the header is checked here; the rows are read by the job
+	scan, err := service.ScanProductFile(format, input, c.PostForm("sheet"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "Unrecognized catalog file",
+			Errors:  gin.H{"file_error": err.Error()},
+			Meta: MetaData{
+				RequestID: c.GetHeader("X-Request-ID"),
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+			},
+		})
+		return
+	}
+	sheet := scan.Report().Sheet
+	scan.Close()
+
+	if dryRun, _ := strconv.ParseBool(c.PostForm("dry_run")); dryRun {
+		catalog := catalogFile{Format: format, Sheet: sheet, Data: input}
+		report, err := applyImport(c.Request.Context(), sellerID, mode, c.MustGet("user_id").(int64), catalog, true, nil, nil)
+		if err != nil {
+			productError(c, err, "Failed to check import")
+			return
+		}
+		c.JSON(http.StatusOK, APIResponse{
+			Success: true,
+			Message: "Dry run completed, nothing was imported",
+			Data:    report,
+			Meta: MetaData{
+				RequestID: c.GetHeader("X-Request-ID"),
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+			},
+		})
+		return
+	}
+
+	payload, _ := json.Marshal(productImportPayload{FileName: file.Filename, Format: format, Sheet: sheet, Mode: mode})
+	job := &model.Job{
+		Type:      model.JobTypeProductImport,
+		SellerID:  &sellerID,
+		Payload:   payload,
+		Input:     input,
+		CreatedBy: c.MustGet("user_id").(int64),
+	}
+	if err := h.jobService.Enqueue(c.Request.Context(), job); err != nil {
+		productError(c, err, "Failed to queue import")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, APIResponse{
+		Success: true,
+		Message: "Import accepted for processing",
+		Data:    gin.H{"job_id": job.ID, "job": job},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
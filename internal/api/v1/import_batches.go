@@ -0,0 +1,208 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/divinecoid/oneagent/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type ImportHandler struct {
+	importService *service.ImportService
+}
+
+func NewImportHandler(importService *service.ImportService) *ImportHandler {
+	return &ImportHandler{importService: importService}
+}
+
+// importError writes the response for a failed import batch operation
+func importError(c *gin.Context, err error, message string) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrImportBatchNotFound):
+		status, message = http.StatusNotFound, "Import not found"
+	case errors.Is(err, service.ErrImportRolledBack):
+		status, message = http.StatusConflict, "Import already rolled back"
+	case errors.Is(err, service.ErrBlobNotFound):
+		status, message = http.StatusNotFound, "Import file not found"
+	case errors.Is(err, service.ErrVariantInCarts):
+		status, message = http.StatusConflict, "Customers' carts hold a variant the rollback would remove"
+	}
+	c.JSON(status, APIResponse{
+		Success: false,
+		Message: message,
+		Errors:  gin.H{"error": err.Error()},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// loadImportBatch parses the :id parameter and returns the import batch if
+// the caller imported into its own catalog or is a super admin. Other
+// sellers' imports are reported as not found. It writes the error response
+// itself.
+func (h *ImportHandler) loadImportBatch(c *gin.Context) (*model.ImportBatch, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		productValidationError(c, "import ID must be a number")
+		return nil, false
+	}
+	batch, err := h.importService.GetBatch(c.Request.Context(), id)
+	if err == nil && model.Role(c.GetString("user_role")) != model.RoleSuperAdmin &&
+		batch.SellerID != c.MustGet("user_id").(int64) {
+		err = service.ErrImportBatchNotFound
+	}
+	if err != nil {
+		importError(c, err, "Failed to get import")
+		return nil, false
+	}
+	return batch, true
+}
+
+// ListImports lists the caller's most recent imports, newest first. Super
+// admins name the seller with ?seller_id=. Optional: limit (default 20).
+func (h *ImportHandler) ListImports(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	requested, _ := strconv.ParseInt(c.Query("seller_id"), 10, 64)
+	sellerID, ok := productSeller(c, requested)
+	if !ok {
+		return
+	}
+
+	batches, err := h.importService.ListBatches(c.Request.Context(), sellerID, limit)
+	if err != nil {
+		importError(c, err, "Failed to list imports")
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Imports retrieved successfully",
+		Data:    gin.H{"seller_id": sellerID, "imports": batches},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// GetImport returns an import with the outcome of every row
+func (h *ImportHandler) GetImport(c *gin.Context) {
+	batch, ok := h.loadImportBatch(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Import retrieved successfully",
+		Data:    batch,
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// ListImportChanges lists the products an import changed, each with its
+// state before and after the import and the fields that differ. Optional:
+// limit (default 50) and offset.
+func (h *ImportHandler) ListImportChanges(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	batch, ok := h.loadImportBatch(c)
+	if !ok {
+		return
+	}
+	items, total, err := h.importService.ListItems(c.Request.Context(), batch.ID, limit, offset)
+	if err != nil {
+		importError(c, err, "Failed to list import changes")
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Import changes retrieved successfully",
+		Data:    gin.H{"import_id": batch.ID, "changes": items, "total": total, "limit": limit, "offset": offset},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// DownloadImportFile returns the file an import was read from
+func (h *ImportHandler) DownloadImportFile(c *gin.Context) {
+	batch, ok := h.loadImportBatch(c)
+	if !ok {
+		return
+	}
+	f, err := h.importService.OpenSource(c.Request.Context(), batch)
+	if err != nil {
+		importError(c, err, "Failed to open import file")
+		return
+	}
+	defer f.Close()
+
+	contentType, ok := exportContentTypes[batch.Format]
+	if !ok {
+		contentType = "application/octet-stream"
+	}
+	c.DataFromReader(http.StatusOK, int64(batch.SourceSize), contentType, f, map[string]string{
+		"Content-Disposition": fmt.Sprintf("attachment; filename=%q", batch.FileName),
+	})
+}
+
+// RollbackImport puts the products an import changed back to their state
+// before it, including their vectors. When some of them changed since, the
+// conflicts are returned with 409 and nothing is rolled back, unless
+// ?force=true is given.
+func (h *ImportHandler) RollbackImport(c *gin.Context) {
+	force, _ := strconv.ParseBool(c.Query("force"))
+	batch, ok := h.loadImportBatch(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.importService.RollbackBatch(c.Request.Context(), batch.ID, c.MustGet("user_id").(int64), force)
+	if errors.Is(err, service.ErrImportConflict) {
+		c.JSON(http.StatusConflict, APIResponse{
+			Success: false,
+			Message: "Products changed since the import; retry with force=true to roll back anyway",
+			Data:    result,
+			Errors:  gin.H{"error": err.Error()},
+			Meta: MetaData{
+				RequestID: c.GetHeader("X-Request-ID"),
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+			},
+		})
+		return
+	}
+	if err != nil {
+		importError(c, err, "Failed to roll back import")
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Import rolled back successfully",
+		Data:    result,
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
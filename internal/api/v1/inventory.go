@@ -0,0 +1,77 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StockAdjustmentRequest is the body of POST /products/:id/stock
+type StockAdjustmentRequest struct {
+	// VariantID picks the variant; products with variants track stock per variant
+	VariantID *int64 `json:"variant_id"`
+	Change    int    `json:"change" binding:"required,ne=0"`
+	Note      string `json:"note" binding:"max=500"`
+}
+
+// AdjustStock adds to or takes from a product's stock, e.g. after a delivery
+// or a stock count, and logs it as a manual adjustment
+func (h *ProductHandler) AdjustStock(c *gin.Context) {
+	var req StockAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		productValidationError(c, err.Error())
+		return
+	}
+
+	product, ok := h.loadProduct(c)
+	if !ok {
+		return
+	}
+	movement, err := h.inventoryService.AdjustStock(c.Request.Context(), product.ID, req.VariantID, req.Change, req.Note,
+		c.MustGet("user_id").(int64))
+	if err != nil {
+		productError(c, err, "Failed to adjust stock")
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Stock adjusted successfully",
+		Data:    movement,
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// ListStockMovements lists the stock movements of a product and its
+// variants, newest first
+func (h *ProductHandler) ListStockMovements(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	product, ok := h.loadProduct(c)
+	if !ok {
+		return
+	}
+	movements, err := h.inventoryService.ListMovements(c.Request.Context(), product.ID, limit)
+	if err != nil {
+		productError(c, err, "Failed to list stock movements")
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Stock movements retrieved successfully",
+		Data:    gin.H{"product_id": product.ID, "stock": product.Stock, "movements": movements},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
@@ -0,0 +1,205 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/divinecoid/oneagent/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type JobHandler struct {
+	jobService *service.JobService
+}
+
+func NewJobHandler(jobService *service.JobService) *JobHandler {
+	return &JobHandler{jobService: jobService}
+}
+
+// loadJob parses the :id parameter and returns the job if the caller created
+// it or is a super admin. It writes the error response itself.
+func (h *JobHandler) loadJob(c *gin.Context) (*model.Job, bool) {
+	jobID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "Invalid job ID",
+			Errors:  gin.H{"error": "job ID must be a number"},
+			Meta: MetaData{
+				RequestID: c.GetHeader("X-Request-ID"),
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+			},
+		})
+		return nil, false
+	}
+
+	job, err := h.jobService.GetJob(c.Request.Context(), jobID)
+	if err == nil && !canAccessJob(c, job) {
+		err = service.ErrJobNotFound
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrJobNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, APIResponse{
+			Success: false,
+			Message: "Job not found",
+			Errors:  gin.H{"error": err.Error()},
+			Meta: MetaData{
+				RequestID: c.GetHeader("X-Request-ID"),
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+			},
+		})
+		return nil, false
+	}
+	return job, true
+}
+
+func canAccessJob(c *gin.Context, job *model.Job) bool {
+	if model.Role(c.GetString("user_role")) == model.RoleSuperAdmin {
+		return true
+	}
+	return job.CreatedBy == c.MustGet("user_id").(int64)
+}
+
+func (h *JobHandler) ListJobs(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	// Super admins see every job, everyone else only their own
+	var userID int64
+	if model.Role(c.GetString("user_role")) != model.RoleSuperAdmin {
+		userID = c.MustGet("user_id").(int64)
+	}
+
+	jobs, err := h.jobService.ListJobs(c.Request.Context(), userID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Message: "Failed to list jobs",
+			Errors:  gin.H{"error": err.Error()},
+			Meta: MetaData{
+				RequestID: c.GetHeader("X-Request-ID"),
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Jobs retrieved successfully",
+		Data:    gin.H{"jobs": jobs},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+func (h *JobHandler) GetJob(c *gin.Context) {
+	job, ok := h.loadJob(c)
+	if !ok {
+		return
+	}
+
+	jobErrors, err := h.jobService.GetJobErrors(c.Request.Context(), job.ID, 100)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Message: "Failed to get job errors",
+			Errors:  gin.H{"error": err.Error()},
+			Meta: MetaData{
+				RequestID: c.GetHeader("X-Request-ID"),
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Job retrieved successfully",
+		Data:    gin.H{"job": job, "errors": jobErrors},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+func (h *JobHandler) CancelJob(c *gin.Context) {
+	job, ok := h.loadJob(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.jobService.CancelJob(c.Request.Context(), job.ID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrJobFinished) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, APIResponse{
+			Success: false,
+			Message: "Failed to cancel job",
+			Errors:  gin.H{"error": err.Error()},
+			Meta: MetaData{
+				RequestID: c.GetHeader("X-Request-ID"),
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Job cancellation requested",
+		Data:    gin.H{"job": job},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+func (h *JobHandler) RetryJob(c *gin.Context) {
+	job, ok := h.loadJob(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.jobService.RetryJob(c.Request.Context(), job.ID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrJobNotRetryable) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, APIResponse{
+			Success: false,
+			Message: "Failed to retry job",
+			Errors:  gin.H{"error": err.Error()},
+			Meta: MetaData{
+				RequestID: c.GetHeader("X-Request-ID"),
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, APIResponse{
+		Success: true,
+		Message: "Job queued for retry",
+		Data:    gin.H{"job_id": job.ID, "job": job},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
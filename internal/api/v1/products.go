@@ -0,0 +1,439 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/divinecoid/oneagent/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ProductRequest is the body of POST /products and PUT /products/:id
+type ProductRequest struct {
+	// SellerID picks the owner when a super admin creates a product; sellers
+	// always own the products they create
+	SellerID    int64          `json:"seller_id,omitempty"`
+	SKU         string         `json:"sku" binding:"max=100"`
+	Name        string         `json:"name" binding:"required,max=255"`
+	Category    string         `json:"category" binding:"max=100"`
+	CategoryID  *int64         `json:"category_id"` // Without it, category is matched against the tree
+	Price       *float64       `json:"price" binding:"required,min=0,max=99999999.99"`
+	Description string         `json:"description"`
+	Attributes  map[string]any `json:"attributes"`
+	IsAvailable *bool          `json:"is_available"`
+	// Stock sets the stock level; nil leaves it as is. Products with variants
+	// track stock per variant instead.
+	Stock *int `json:"stock" binding:"omitempty,min=0"`
+	// Variants replaces the product's variants; existing ones are matched by name
+	Variants []VariantRequest `json:"variants" binding:"omitempty,dive"`
+}
+
+// VariantRequest is one variant in a product body
+type VariantRequest struct {
+	Name        string            `json:"name" binding:"required,max=100"`
+	Options     map[string]string `json:"options"`
+	SKU         string            `json:"sku" binding:"max=100"`
+	Price       *float64          `json:"price" binding:"required,min=0,max=99999999.99"`
+	Stock       *int              `json:"stock" binding:"omitempty,min=0"`
+	IsAvailable *bool             `json:"is_available"`
+}
+
+// productVariants converts and validates request variants. nil stays nil.
+func productVariants(reqs []VariantRequest) ([]model.ProductVariant, error) {
+	if reqs == nil {
+		return nil, nil
+	}
+	variants := make([]model.ProductVariant, len(reqs))
+	for i, req := range reqs {
+		variants[i] = model.ProductVariant{
+			Name:        strings.TrimSpace(req.Name),
+			Options:     req.Options,
+			SKU:         strings.TrimSpace(req.SKU),
+			Price:       *req.Price,
+			Stock:       req.Stock,
+			IsAvailable: valueOr(req.IsAvailable, true),
+		}
+	}
+	return variants, service.ValidateVariants(variants)
+}
+
+// PatchProductRequest is the body of PATCH /products/:id; only the fields
+// present are changed
+type PatchProductRequest struct {
+	SKU         *string          `json:"sku" binding:"omitempty,max=100"`
+	Name        *string          `json:"name" binding:"omitempty,max=255"`
+	Category    *string          `json:"category" binding:"omitempty,max=100"`
+	CategoryID  *int64           `json:"category_id"`
+	Price       *float64         `json:"price" binding:"omitempty,min=0,max=99999999.99"`
+	Description *string          `json:"description"`
+	Attributes  map[string]any   `json:"attributes"`
+	IsAvailable *bool            `json:"is_available"`
+	Stock       *int             `json:"stock" binding:"omitempty,min=0"`
+	Variants    []VariantRequest `json:"variants" binding:"omitempty,dive"`
+}
+
+func canManageProduct(c *gin.Context, product *model.Product) bool {
+	if model.Role(c.GetString("user_role")) == model.RoleSuperAdmin {
+		return true
+	}
+	return product.SellerID == c.MustGet("user_id").(int64)
+}
+
+// loadProduct parses the :id parameter and returns the product if the caller
+// owns it or is a super admin. Other sellers' products are reported as not
+// found. It writes the error response itself.
+func (h *ProductHandler) loadProduct(c *gin.Context) (*model.Product, bool) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "Invalid product ID",
+			Errors:  gin.H{"error": "product ID must be a number"},
+			Meta: MetaData{
+				RequestID: c.GetHeader("X-Request-ID"),
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+			},
+		})
+		return nil, false
+	}
+
+	product, err := h.productService.GetProduct(c.Request.Context(), productID)
+	if err == nil && !canManageProduct(c, product) {
+		err = service.ErrProductNotFound
+	}
+	if err != nil {
+		productError(c, err, "Failed to get product")
+		return nil, false
+	}
+	return product, true
+}
+
+// productSeller returns the seller whose catalog the caller works on. Sellers
+// always work on their own; super admins must name one. It writes the error
+// response itself.
+func productSeller(c *gin.Context, requested int64) (int64, bool) {
+	userID := c.MustGet("user_id").(int64)
+	if model.Role(c.GetString("user_role")) == model.RoleSuperAdmin {
+		if requested <= 0 {
+			productValidationError(c, "seller_id is required")
+			return 0, false
+		}
+		return requested, true
+	}
+	if requested != 0 && requested != userID {
+		c.JSON(http.StatusForbidden, APIResponse{
+			Success: false,
+			Message: "Access denied",
+			Errors:  gin.H{"error": "sellers can only manage their own products"},
+			Meta: MetaData{
+				RequestID: c.GetHeader("X-Request-ID"),
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+			},
+		})
+		return 0, false
+	}
+	return userID, true
+}
+
+// productError writes the response for a failed product service call
+func productError(c *gin.Context, err error, message string) {
+	status := http.StatusInternalServerError
+	switch {
+	// Checked first, since it wraps the stock and variant error that caused it
+	case errors.Is(err, service.ErrCartItemsNotMovable):
+		status, message = http.StatusConflict, "Customers' carts hold items the surviving product can't take"
+	case errors.Is(err, service.ErrProductNotFound):
+		status, message = http.StatusNotFound, "Product not found"
+	case errors.Is(err, service.ErrDuplicateSKU):
+		status, message = http.StatusConflict, "SKU already in use"
+	case errors.Is(err, service.ErrCategoryNotFound):
+		status, message = http.StatusBadRequest, "Unknown category"
+	case errors.Is(err, service.ErrVariantInCarts):
+		status, message = http.StatusConflict, "Customers' carts hold a variant this would remove"
+	case errors.Is(err, service.ErrVariantNotFound):
+		status, message = http.StatusNotFound, "Variant not found"
+	case errors.Is(err, service.ErrVariantRequired), errors.Is(err, service.ErrStockNotTrackedHere):
+		status, message = http.StatusBadRequest, "Choose a variant"
+	case errors.Is(err, service.ErrInsufficientStock):
+		status, message = http.StatusConflict, "Not enough stock"
+	case errors.Is(err, service.ErrSameProduct):
+		status, message = http.StatusBadRequest, "Invalid request parameters"
+	case errors.Is(err, service.ErrSuggestionNotFound):
+		status, message = http.StatusNotFound, "Suggestion not found"
+	case errors.Is(err, service.ErrSuggestionReviewed):
+		status, message = http.StatusConflict, "Suggestion already reviewed"
+	case errors.Is(err, service.ErrSuggestionStale):
+		status, message = http.StatusConflict, "Product changed since the suggestion; run enrichment again"
+	case errors.Is(err, service.ErrNothingSuggested):
+		status, message = http.StatusBadRequest, "Invalid request parameters"
+	case errors.Is(err, service.ErrImportTooLarge):
+		status, message = http.StatusRequestEntityTooLarge, "Catalog file too large"
+	}
+	c.JSON(status, APIResponse{
+		Success: false,
+		Message: message,
+		Errors:  gin.H{"error": err.Error()},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// productValidationError writes a 400 response for an invalid product body
+func productValidationError(c *gin.Context, message string) {
+	c.JSON(http.StatusBadRequest, APIResponse{
+		Success: false,
+		Message: "Invalid request parameters",
+		Errors:  gin.H{"validation_error": message},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// ListProducts lists the caller's products, newest first. Super admins list
+// every seller's products, or one seller's with ?seller_id=.
+func (h *ProductHandler) ListProducts(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	sellerID := c.MustGet("user_id").(int64)
+	if model.Role(c.GetString("user_role")) == model.RoleSuperAdmin {
+		sellerID, _ = strconv.ParseInt(c.Query("seller_id"), 10, 64)
+	}
+
+	products, total, err := h.productService.ListProducts(c.Request.Context(), sellerID, limit, offset)
+	if err != nil {
+		productError(c, err, "Failed to list products")
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Products retrieved successfully",
+		Data: gin.H{
+			"products": products,
+			"total":    total,
+			"limit":    limit,
+			"offset":   offset,
+		},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+func (h *ProductHandler) GetProduct(c *gin.Context) {
+	product, ok := h.loadProduct(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Product retrieved successfully",
+		Data:    product,
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+func (h *ProductHandler) CreateProduct(c *gin.Context) {
+	var req ProductRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		productValidationError(c, err.Error())
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		productValidationError(c, "name must not be blank")
+		return
+	}
+
+	variants, err := productVariants(req.Variants)
+	if err != nil {
+		productValidationError(c, err.Error())
+		return
+	}
+
+	sellerID, ok := productSeller(c, req.SellerID)
+	if !ok {
+		return
+	}
+
+	product := &model.Product{
+		SellerID:    sellerID,
+		SKU:         strings.TrimSpace(req.SKU),
+		Name:        strings.TrimSpace(req.Name),
+		Category:    strings.TrimSpace(req.Category),
+		CategoryID:  req.CategoryID,
+		Price:       *req.Price,
+		Description: req.Description,
+		Attributes:  req.Attributes,
+		IsAvailable: valueOr(req.IsAvailable, true),
+		Stock:       req.Stock,
+		Variants:    variants,
+	}
+	if err := h.productService.CreateProduct(c.Request.Context(), product, c.MustGet("user_id").(int64)); err != nil {
+		productError(c, err, "Failed to create product")
+		return
+	}
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Product created successfully",
+		Data:    product,
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// UpdateProduct replaces every editable field of a product. The owner can't
+// be changed.
+func (h *ProductHandler) UpdateProduct(c *gin.Context) {
+	var req ProductRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		productValidationError(c, err.Error())
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		productValidationError(c, "name must not be blank")
+		return
+	}
+	variants, err := productVariants(req.Variants)
+	if err != nil {
+		productValidationError(c, err.Error())
+		return
+	}
+
+	product, ok := h.loadProduct(c)
+	if !ok {
+		return
+	}
+	product.SKU = strings.TrimSpace(req.SKU)
+	product.Name = strings.TrimSpace(req.Name)
+	product.Category = strings.TrimSpace(req.Category)
+	product.CategoryID = req.CategoryID
+	product.Price = *req.Price
+	product.Description = req.Description
+	product.Attributes = req.Attributes
+	product.IsAvailable = valueOr(req.IsAvailable, true)
+	if req.Stock != nil {
+		product.Stock = req.Stock
+	}
+	product.Variants = variants
+
+	h.saveProduct(c, product)
+}
+
+// PatchProduct changes only the fields present in the body
+func (h *ProductHandler) PatchProduct(c *gin.Context) {
+	var req PatchProductRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		productValidationError(c, err.Error())
+		return
+	}
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		productValidationError(c, "name must not be blank")
+		return
+	}
+	variants, err := productVariants(req.Variants)
+	if err != nil {
+		productValidationError(c, err.Error())
+		return
+	}
+
+	product, ok := h.loadProduct(c)
+	if !ok {
+		return
+	}
+	if req.SKU != nil {
+		product.SKU = strings.TrimSpace(*req.SKU)
+	}
+	if req.Name != nil {
+		product.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Category != nil {
+		product.Category = strings.TrimSpace(*req.Category)
+		product.CategoryID = nil // Matched against the tree again
+	}
+	if req.CategoryID != nil {
+		product.CategoryID = req.CategoryID
+	}
+	if req.Price != nil {
+		product.Price = *req.Price
+	}
+	if req.Description != nil {
+		product.Description = *req.Description
+	}
+	if req.Attributes != nil {
+		product.Attributes = req.Attributes
+	}
+	if req.IsAvailable != nil {
+		product.IsAvailable = *req.IsAvailable
+	}
+	if req.Stock != nil {
+		product.Stock = req.Stock
+	}
+	if variants != nil {
+		product.Variants = variants
+	}
+
+	h.saveProduct(c, product)
+}
+
+func (h *ProductHandler) saveProduct(c *gin.Context, product *model.Product) {
+	if err := h.productService.UpdateProduct(c.Request.Context(), product, c.MustGet("user_id").(int64)); err != nil {
+		productError(c, err, "Failed to update product")
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Product updated successfully",
+		Data:    product,
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// DeleteProduct soft-deletes a product. It disappears from listings, search
+// and chat, but stays referenced by carts and analytics.
+func (h *ProductHandler) DeleteProduct(c *gin.Context) {
+	product, ok := h.loadProduct(c)
+	if !ok {
+		return
+	}
+
+	if err := h.productService.DeleteProduct(c.Request.Context(), product.ID); err != nil {
+		productError(c, err, "Failed to delete product")
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Product deleted successfully",
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
@@ -0,0 +1,248 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/divinecoid/oneagent/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type PromotionHandler struct {
+	promotionService *service.PromotionService
+}
+
+func NewPromotionHandler(promotionService *service.PromotionService) *PromotionHandler {
+	return &PromotionHandler{promotionService: promotionService}
+}
+
+// PromotionRequest is the body of POST /promotions and PUT /promotions/:id.
+// Value is the percentage, the amount off or the bundle price depending on
+// the kind. Leaving product_ids and category_ids empty covers the whole store.
+type PromotionRequest struct {
+	SellerID    int64      `json:"seller_id"` // Super admins only; sellers manage their own
+	Name        string     `json:"name" binding:"required,max=100"`
+	Kind        string     `json:"kind" binding:"required,oneof=percentage fixed bundle buy_x_get_y"`
+	Value       float64    `json:"value"`
+	BuyQty      int        `json:"buy_qty"`
+	GetQty      int        `json:"get_qty"`
+	ProductIDs  []int64    `json:"product_ids"`
+	CategoryIDs []int64    `json:"category_ids"`
+	DaysOfWeek  []int      `json:"days_of_week"` // 1 (Monday) to 7 (Sunday); empty is every day
+	Timezone    string     `json:"timezone"`     // Defaults to Asia/Jakarta
+	StartsAt    *time.Time `json:"starts_at"`
+	EndsAt      *time.Time `json:"ends_at"`
+	IsActive    *bool      `json:"is_active"` // Defaults to true
+}
+
+func (r PromotionRequest) promotion(sellerID int64) *model.Promotion {
+	return &model.Promotion{
+		PromotionRule: model.PromotionRule{
+			Name:   r.Name,
+			Kind:   r.Kind,
+			Value:  r.Value,
+			BuyQty: r.BuyQty,
+			GetQty: r.GetQty,
+			EndsAt: r.EndsAt,
+		},
+		SellerID:    sellerID,
+		ProductIDs:  r.ProductIDs,
+		CategoryIDs: r.CategoryIDs,
+		DaysOfWeek:  r.DaysOfWeek,
+		Timezone:    r.Timezone,
+		StartsAt:    r.StartsAt,
+		IsActive:    r.IsActive == nil || *r.IsActive,
+	}
+}
+
+// promotionError writes the response for a failed promotion operation
+func promotionError(c *gin.Context, err error, message string) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrPromotionNotFound):
+		status, message = http.StatusNotFound, "Promotion not found"
+	case errors.Is(err, service.ErrInvalidPromotion):
+		status, message = http.StatusBadRequest, "Invalid promotion"
+	}
+	c.JSON(status, APIResponse{
+		Success: false,
+		Message: message,
+		Errors:  gin.H{"error": err.Error()},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// loadPromotion parses the :id parameter and returns the promotion if the
+// caller owns it or is a super admin. Other sellers' promotions are reported
+// as not found. It writes the error response itself.
+func (h *PromotionHandler) loadPromotion(c *gin.Context) (*model.Promotion, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		productValidationError(c, "promotion ID must be a number")
+		return nil, false
+	}
+	promotion, err := h.promotionService.GetPromotion(c.Request.Context(), id)
+	if err == nil && model.Role(c.GetString("user_role")) != model.RoleSuperAdmin &&
+		promotion.SellerID != c.MustGet("user_id").(int64) {
+		err = service.ErrPromotionNotFound
+	}
+	if err != nil {
+		promotionError(c, err, "Failed to get promotion")
+		return nil, false
+	}
+	return promotion, true
+}
+
+// ListPromotions lists the caller's promotions, running or not. Super admins
+// name the seller with ?seller_id=.
+func (h *PromotionHandler) ListPromotions(c *gin.Context) {
+	requested, _ := strconv.ParseInt(c.Query("seller_id"), 10, 64)
+	sellerID, ok := productSeller(c, requested)
+	if !ok {
+		return
+	}
+	promotions, err := h.promotionService.ListPromotions(c.Request.Context(), sellerID)
+	if err != nil {
+		promotionError(c, err, "Failed to list promotions")
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Promotions retrieved successfully",
+		Data:    gin.H{"seller_id": sellerID, "promotions": promotions},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// GetPromotion returns one of the caller's promotions
+func (h *PromotionHandler) GetPromotion(c *gin.Context) {
+	promotion, ok := h.loadPromotion(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Promotion retrieved successfully",
+		Data:    promotion,
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// CreatePromotion starts a promotion for the caller's store, or seller_id's
+// for super admins
+func (h *PromotionHandler) CreatePromotion(c *gin.Context) {
+	var req PromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		productValidationError(c, err.Error())
+		return
+	}
+	sellerID, ok := productSeller(c, req.SellerID)
+	if !ok {
+		return
+	}
+
+	promotion := req.promotion(sellerID)
+	if err := h.promotionService.CreatePromotion(c.Request.Context(), promotion); err != nil {
+		promotionError(c, err, "Failed to create promotion")
+		return
+	}
+	c.JSON(http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Promotion created successfully",
+		Data:    promotion,
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// UpdatePromotion replaces every field of a promotion
+func (h *PromotionHandler) UpdatePromotion(c *gin.Context) {
+	var req PromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		productValidationError(c, err.Error())
+		return
+	}
+	existing, ok := h.loadPromotion(c)
+	if !ok {
+		return
+	}
+
+	promotion := req.promotion(existing.SellerID)
+	promotion.ID, promotion.CreatedAt = existing.ID, existing.CreatedAt
+	if err := h.promotionService.UpdatePromotion(c.Request.Context(), promotion); err != nil {
+		promotionError(c, err, "Failed to update promotion")
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Promotion updated successfully",
+		Data:    promotion,
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// DeletePromotion ends and removes a promotion
+func (h *PromotionHandler) DeletePromotion(c *gin.Context) {
+	promotion, ok := h.loadPromotion(c)
+	if !ok {
+		return
+	}
+	if err := h.promotionService.DeletePromotion(c.Request.Context(), promotion.ID); err != nil {
+		promotionError(c, err, "Failed to delete promotion")
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Promotion deleted successfully",
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// ListPriceHistory lists the price changes of a product and its variants,
+// newest first
+func (h *ProductHandler) ListPriceHistory(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	product, ok := h.loadProduct(c)
+	if !ok {
+		return
+	}
+	changes, err := h.productService.ListPriceHistory(c.Request.Context(), product.ID, limit)
+	if err != nil {
+		productError(c, err, "Failed to list price history")
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Price history retrieved successfully",
+		Data:    gin.H{"product_id": product.ID, "price": product.Price, "changes": changes},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
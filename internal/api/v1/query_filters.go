@@ -0,0 +1,81 @@
+package v1
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/divinecoid/oneagent/internal/service"
+)
+
+// amountPattern matches a rupiah amount such as "Rp 25.000", "50 ribu", "50rb",
+// "50k" or "1,5 juta". It captures the rp prefix, the number and the unit.
+const amountPattern = `(rp\.?\s*)?(\d+(?:[.,]\d+)*)\s*(ribu|rb|k|juta|jt)?\b`
+
+// Keywords must start a word, so "admin 5" or "terminal 3" isn't a price
+var (
+	priceRangePattern = regexp.MustCompile(`(?i)\b(?:antara|between|harga)\s+` + amountPattern + `\s*(?:-|sampai|s/d|hingga|to|dan|and)\s*` + amountPattern)
+	maxPricePattern   = regexp.MustCompile(`(?i)(?:\b(?:di\s*bawah|kurang\s+dari|tidak\s+lebih\s+dari|maksimal|maks|max|under|below|budget)|<=?)\s*` + amountPattern)
+	minPricePattern   = regexp.MustCompile(`(?i)(?:\b(?:di\s*atas|lebih\s+dari|minimal|min|over|above)|>=?)\s*` + amountPattern)
+)
+
+// extractQueryFilters pulls structured constraints out of a free-text
+// question, e.g. "mie ayam di bawah 50 ribu" gives a maximum price of 50000.
+// A number is only taken as a price when it looks like one, so "kurang dari
+// 10 menit" gives no filter.
+func extractQueryFilters(question string) SearchFilters {
+	var filters SearchFilters
+
+	if m := priceRangePattern.FindStringSubmatch(question); m != nil {
+		// "antara 20 - 50 ribu": the low end shares the high end's unit
+		lowUnit := m[3]
+		if lowUnit == "" && m[1] == "" {
+			lowUnit = m[6]
+		}
+		low, okLow := parseRupiahAmount(m[1], m[2], lowUnit)
+		high, okHigh := parseRupiahAmount(m[4], m[5], m[6])
+		if okLow && okHigh {
+			if low > high {
+				low, high = high, low
+			}
+			filters.MinPrice, filters.MaxPrice = &low, &high
+			return filters
+		}
+	}
+	if m := maxPricePattern.FindStringSubmatch(question); m != nil {
+		if amount, ok := parseRupiahAmount(m[1], m[2], m[3]); ok {
+			filters.MaxPrice = &amount
+		}
+	}
+	if m := minPricePattern.FindStringSubmatch(question); m != nil {
+		if amount, ok := parseRupiahAmount(m[1], m[2], m[3]); ok {
+			filters.MinPrice = &amount
+		}
+	}
+	return filters
+}
+
+// parseRupiahAmount applies the unit to a number. It reports false unless
+// the amount has a unit, an rp prefix or is at least 1000; amounts under
+// 1000 with an rp prefix are read as thousands, since "di bawah rp 50"
+// means 50 ribu when talking prices.
+func parseRupiahAmount(prefix, number, unit string) (float64, bool) {
+	value, err := service.ParseIndonesianNumber(number)
+	if err != nil {
+		return 0, false
+	}
+	switch strings.ToLower(unit) {
+	case "ribu", "rb", "k":
+		value *= 1000
+	case "juta", "jt":
+		value *= 1000000
+	default:
+		if value >= 1000 {
+			break
+		}
+		if prefix == "" {
+			return 0, false
+		}
+		value *= 1000
+	}
+	return value, true
+}
@@ -0,0 +1,58 @@
+package v1
+
+import "testing"
+
+func float64Ptr(v float64) *float64 { return &v }
+
+func TestExtractQueryFilters(t *testing.T) {
+	tests := []struct {
+		question string
+		min, max *float64
+	}{
+		{"mie ayam di bawah 50 ribu", nil, float64Ptr(50000)},
+		{"nasi goreng dibawah 25rb", nil, float64Ptr(25000)},
+		{"kopi max 30k", nil, float64Ptr(30000)},
+		{"sepatu kurang dari Rp 250.000", nil, float64Ptr(250000)},
+		{"laptop maksimal 1,5 juta", nil, float64Ptr(1500000)},
+		{"martabak di bawah rp 40", nil, float64Ptr(40000)},
+		{"tas di atas 100.000", float64Ptr(100000), nil},
+		{"jam tangan minimal 2jt", float64Ptr(2000000), nil},
+		{"baju > 75rb", float64Ptr(75000), nil},
+		{"bakso antara 15 ribu sampai 30 ribu", float64Ptr(15000), float64Ptr(30000)},
+		{"bakso antara 20 - 50 ribu", float64Ptr(20000), float64Ptr(50000)},
+		{"harga 50rb - 20rb", float64Ptr(20000), float64Ptr(50000)},
+		{"di atas 20rb di bawah 60rb", float64Ptr(20000), float64Ptr(60000)},
+
+		// Numbers that aren't prices
+		{"yang siap kurang dari 10 menit", nil, nil},
+		{"paket untuk lebih dari 4 orang", nil, nil},
+		{"minuman 500 ml", nil, nil},
+		{"admin 5 hari kerja", nil, nil},
+		{"terminal 3 bandara", nil, nil},
+		{"beras di bawah 5 kg", nil, nil},
+		{"kaos antara 2 dan 3 warna", nil, nil},
+		{"mie ayam enak", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.question, func(t *testing.T) {
+			got := extractQueryFilters(tt.question)
+			if !samePrice(got.MinPrice, tt.min) {
+				t.Errorf("min price = %v, want %v", deref(got.MinPrice), deref(tt.min))
+			}
+			if !samePrice(got.MaxPrice, tt.max) {
+				t.Errorf("max price = %v, want %v", deref(got.MaxPrice), deref(tt.max))
+			}
+		})
+	}
+}
+
+func samePrice(a, b *float64) bool {
+	return (a == nil) == (b == nil) && (a == nil || *a == *b)
+}
+
+func deref(v *float64) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
@@ -0,0 +1,277 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/divinecoid/oneagent/internal/model"
+)
+
+// Rerankers, selectable per configuration
+const (
+	rerankerNone    = "none"
+	rerankerLexical = "lexical"
+	rerankerLLM     = "llm"
+)
+
+// defaultRerankCandidates is how many candidates are over-fetched for
+// reranking when the configuration doesn't say
+const defaultRerankCandidates = 20
+
+// reranker reorders retrieved candidates for a query, setting RerankScore on
+// each. Candidates come in retrieval order and are returned best first,
+// along with the name of the reranker that ordered them.
+type reranker interface {
+	Rerank(ctx context.Context, query string, candidates []SearchResult) ([]SearchResult, string, error)
+}
+
+// newReranker returns the reranker configured for a seller, or nil when
+// reranking is turned off
+func newReranker(config *model.UserConfiguration) reranker {
+	switch config.Reranker {
+	case rerankerNone:
+		return nil
+	case rerankerLLM:
+		return &llmReranker{
+			apiKey:   config.OpenAIAPIKey,
+			model:    config.OpenAIModel,
+			fallback: lexicalReranker{},
+		}
+	default:
+		return lexicalReranker{}
+	}
+}
+
+// rerankCandidates returns how many candidates to retrieve so the reranker
+// has something to choose from
+func rerankCandidates(config *model.UserConfiguration, limit int) int {
+	if newReranker(config) == nil {
+		return limit
+	}
+	candidates := config.RerankCandidates
+	if candidates <= 0 {
+		candidates = defaultRerankCandidates
+	}
+	if candidates < limit {
+		return limit
+	}
+	return candidates
+}
+
+// rerankResults applies the seller's reranker and keeps the best limit
+// results. It returns the name of the reranker that produced the order.
+func rerankResults(ctx context.Context, config *model.UserConfiguration, query string, results []SearchResult, limit int) ([]SearchResult, string) {
+	r := newReranker(config)
+	name := rerankerNone
+	if r != nil && len(results) > 1 {
+		reranked, rerankedBy, err := r.Rerank(ctx, query, results)
+		if err == nil {
+			results, name = reranked, rerankedBy
+		}
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, name
+}
+
+// lexicalReranker scores candidates locally from term overlap with the name,
+// category and description, plus the normalized retrieval score. It is cheap
+// and deterministic, and catches vector matches that share no query terms.
+type lexicalReranker struct{}
+
+func (lexicalReranker) Rerank(ctx context.Context, query string, candidates []SearchResult) ([]SearchResult, string, error) {
+	terms := rerankTerms(query)
+
+	maxScore := 0.0
+	for _, c := range candidates {
+		if c.Score > maxScore {
+			maxScore = c.Score
+		}
+	}
+
+	results := make([]SearchResult, len(candidates))
+	copy(results, candidates)
+	for i := range results {
+		p := &results[i]
+		retrieval := 0.0
+		if maxScore > 0 {
+			retrieval = p.Score / maxScore
+		}
+		score := 0.4*retrieval +
+			0.35*termCoverage(terms, p.Name) +
+			0.1*termCoverage(terms, p.Category) +
+			0.15*termCoverage(terms, p.Description)
+		// Bonus when the whole query appears in the name, e.g. "mie ayam"
+		if phrase := strings.Join(terms, " "); phrase != "" && strings.Contains(strings.Join(rerankTerms(p.Name), " "), phrase) {
+			score += 0.2
+		}
+		p.RerankScore = &score
+	}
+	sortByRerankScore(results)
+	return results, rerankerLexical, nil
+}
+
+// rerankStopwords are dropped from queries before matching; mostly
+// Indonesian chat filler that would otherwise match every description
+var rerankStopwords = map[string]bool{
+	"yang": true, "dan": true, "di": true, "ke": true, "dari": true, "ada": true,
+	"apa": true, "saya": true, "aku": true, "mau": true, "ingin": true, "beli": true,
+	"cari": true, "untuk": true, "dengan": true, "ini": true, "itu": true, "ya": true,
+	"kak": true, "min": true, "dong": true, "nya": true, "berapa": true, "harga": true,
+	"the": true, "a": true, "an": true, "and": true, "for": true, "with": true, "of": true,
+}
+
+// rerankTerms lowercases s and splits it into words, dropping stopwords
+func rerankTerms(s string) []string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := words[:0]
+	for _, w := range words {
+		if !rerankStopwords[w] {
+			terms = append(terms, w)
+		}
+	}
+	return terms
+}
+
+// termCoverage is the fraction of terms found in text, counting a prefix
+// match ("ayam" in "ayamnya") as a hit
+func termCoverage(terms []string, text string) float64 {
+	if len(terms) == 0 {
+		return 0
+	}
+	words := rerankTerms(text)
+	hits := 0
+	for _, term := range terms {
+		for _, w := range words {
+			if strings.HasPrefix(w, term) {
+				hits++
+				break
+			}
+		}
+	}
+	return float64(hits) / float64(len(terms))
+}
+
+func sortByRerankScore(results []SearchResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		return *results[i].RerankScore > *results[j].RerankScore
+	})
+}
+
+// llmReranker asks the seller's chat model to grade each candidate's
+// relevance. Any failure falls back to the lexical reranker so chat keeps
+// working when the model is slow or returns garbage.
+type llmReranker struct {
+	apiKey   string
+	model    string
+	fallback reranker
+}
+
+type llmRerankScores struct {
+	Scores []struct {
+		ID    int64   `json:"id"`
+		Score float64 `json:"score"`
+	} `json:"scores"`
+}
+
+func (r *llmReranker) Rerank(ctx context.Context, query string, candidates []SearchResult) ([]SearchResult, string, error) {
+	scores, err := r.score(ctx, query, candidates)
+	if err != nil {
+		return r.fallback.Rerank(ctx, query, candidates)
+	}
+
+	results := make([]SearchResult, len(candidates))
+	copy(results, candidates)
+	for i := range results {
+		// Candidates the model skipped rank last
+		score := 0.0
+		if s, ok := scores[results[i].ID]; ok {
+			score = s / 10
+		}
+		results[i].RerankScore = &score
+	}
+	sortByRerankScore(results)
+	return results, rerankerLLM, nil
+}
+
+// score returns the model's 0-10 relevance grade per product ID
+func (r *llmReranker) score(ctx context.Context, query string, candidates []SearchResult) (map[int64]float64, error) {
+	if r.apiKey == "" {
+		return nil, fmt.Errorf("OpenAI API key not set")
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString(fmt.Sprintf("Customer question: %s\n\nProducts:\n", query))
+	for _, p := range candidates {
+		description := p.Description
+		if runes := []rune(description); len(runes) > 200 {
+			description = string(runes[:200])
+		}
+		prompt.WriteString(fmt.Sprintf("- id %d: %s (%s), Rp %.0f. %s\n", p.ID, p.Name, p.Category, p.Price, description))
+	}
+	prompt.WriteString("\nGrade how well each product answers the question from 0 (irrelevant) to 10 (exactly what was asked). ")
+	prompt.WriteString(`Reply with JSON only: {"scores": [{"id": <id>, "score": <0-10>}, ...]}`)
+
+	body, err := json.Marshal(OpenAIRequest{
+		Model: r.model,
+		Messages: []Message{
+			{Role: "system", Content: "You rank products by relevance to a customer question for a shop's search engine."},
+			{Role: "user", Content: prompt.String()},
+		},
+		MaxTokens: 20 * len(candidates),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+r.apiKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var rawBody bytes.Buffer
+		rawBody.ReadFrom(resp.Body)
+		return nil, fmt.Errorf("OpenAI API error: %s", rawBody.String())
+	}
+
+	var result OpenAIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(result.Choices) == 0 {
+		return nil, fmt.Errorf("no response generated")
+	}
+
+	// Models often wrap JSON in a markdown code fence
+	content := strings.TrimSpace(result.Choices[0].Message.Content)
+	content = strings.TrimPrefix(content, "```json")
+	content = strings.Trim(content, "`\n ")
+
+	var parsed llmRerankScores
+	if err := json.Unmarshal([]byte(content), &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse rerank scores: %w", err)
+	}
+	scores := make(map[int64]float64, len(parsed.Scores))
+	for _, s := range parsed.Scores {
+		scores[s.ID] = s.Score
+	}
+	return scores, nil
+}
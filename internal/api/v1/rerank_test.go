@@ -0,0 +1,46 @@
+package v1
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/divinecoid/oneagent/internal/model"
+)
+
+// TestRerankResultsReportsRerankerThatRan checks that a search is reported
+// as reranked by the reranker that actually ordered it, including when the
+// LLM reranker falls back to the lexical one
+func TestRerankResultsReportsRerankerThatRan(t *testing.T) {
+	candidates := []SearchResult{
+		{ID: 1, Name: "Es Teh Manis", Score: 0.9},
+		{ID: 2, Name: "Mie Ayam Bakso", Score: 0.5},
+	}
+	tests := []struct {
+		name     string
+		config   model.UserConfiguration
+		results  []SearchResult
+		wantName string
+		wantIDs  []int64
+	}{
+		{"off", model.UserConfiguration{Reranker: rerankerNone}, candidates, rerankerNone, []int64{1, 2}},
+		{"lexical", model.UserConfiguration{Reranker: rerankerLexical}, candidates, rerankerLexical, []int64{2, 1}},
+		{"llm without a key falls back", model.UserConfiguration{Reranker: rerankerLLM}, candidates, rerankerLexical, []int64{2, 1}},
+		{"one result isn't reranked", model.UserConfiguration{Reranker: rerankerLexical}, candidates[:1], rerankerNone, []int64{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, name := rerankResults(context.Background(), &tt.config, "mie ayam", tt.results, 10)
+			if name != tt.wantName {
+				t.Errorf("reranked by %q, want %q", name, tt.wantName)
+			}
+			var ids []int64
+			for _, r := range results {
+				ids = append(ids, r.ID)
+			}
+			if !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("got IDs %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
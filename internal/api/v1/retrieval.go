@@ -0,0 +1,441 @@
+package v1
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/divinecoid/oneagent/internal/db"
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/divinecoid/oneagent/internal/service"
+)
+
+// Retrieval modes, selectable per request and per configuration
+const (
+	searchModeVector = "vector"
+	searchModeText   = "text"
+	searchModeHybrid = "hybrid"
+)
+
+// Sort orders for search results
+const (
+	sortRelevance = "relevance"
+	sortPriceAsc  = "price_asc"
+	sortPriceDesc = "price_desc"
+	sortNewest    = "newest"
+)
+
+// rrfK dampens the weight of top ranks in reciprocal rank fusion
+const rrfK = 60
+
+// searchPoolSize is how many candidates each retriever contributes to a
+// query-driven search. Sorting and paging happen within this pool, so it
+// also bounds the reported total.
+const searchPoolSize = 200
+
+// productQuery describes a product retrieval. Vector and hybrid modes need
+// Embedding and Model; text and hybrid modes need Text. Without either the
+// catalog is browsed using only the filters.
+type productQuery struct {
+	Mode          string
+	Text          string
+	Embedding     []float32
+	Model         string
+	SellerID      *int64 // nil searches every seller
+	Filters       SearchFilters
+	MinSimilarity float64 // 0 disables the threshold
+	Sort          string
+	Cursor        string
+	Limit         int
+	At            *time.Time // When AvailableNow is evaluated; nil is now
+}
+
+// searchPage is one page of search results
+type searchPage struct {
+	Results    []SearchResult
+	Total      int
+	NextCursor string
+}
+
+// errInvalidCursor is returned for a cursor that can't be decoded, or that
+// was issued for a different sort order than the one requested
+var errInvalidCursor = errors.New("invalid cursor")
+
+// searchCursor marks the last row of a page by its sort key and ID, and
+// records the sort order the key belongs to
+type searchCursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	ID   int64  `json:"id"`
+}
+
+func encodeSearchCursor(cursor searchCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSearchCursor(encoded string) (*searchCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var cursor searchCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, errInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// checkCursorKey makes sure a cursor key can be cast the way its sort key
+// is, so a tampered cursor is rejected rather than failing the query
+func checkCursorKey(key, cast string) error {
+	var err error
+	switch cast {
+	case "FLOAT8", "NUMERIC":
+		_, err = strconv.ParseFloat(key, 64)
+	case "TIMESTAMP":
+		_, err = time.Parse("2006-01-02 15:04:05.999999", key)
+	}
+	if err != nil {
+		return errInvalidCursor
+	}
+	return nil
+}
+
+// sqlArgs collects positional query arguments while SQL is being built
+type sqlArgs []any
+
+func (a *sqlArgs) add(v any) string {
+	*a = append(*a, v)
+	return fmt.Sprintf("$%d", len(*a))
+}
+
+// embeddingDistance returns the cosine distance expression between column and
+// param for vectors of the given dimension. The casts match the per-dimension
+// partial indexes on product_embeddings; HNSW only indexes up to 2000 vector
+// dimensions, so larger embeddings are compared as halfvec.
+func embeddingDistance(column, param string, dims int) string {
+	typ := "vector"
+	if dims > 2000 {
+		typ = "halfvec"
+	}
+	return fmt.Sprintf("(%s::%s(%d) <=> %s::%s(%d))", column, typ, dims, param, typ, dims)
+}
+
+// embeddingDimensions returns the condition that column, a dimensions
+// column, is dims. It is written as a literal rather than bound as a
+// parameter: the planner can only use a partial index whose predicate it can
+// prove, which a generic plan for a parameter never lets it do.
+func embeddingDimensions(column string, dims int) string {
+	return fmt.Sprintf("%s = %d", column, dims)
+}
+
+// productFilters returns the WHERE conditions shared by every retriever, for
+// products aliased as p. Filters are applied inside each retriever so they
+// narrow the candidates rather than the final page. Deleted products never match.
+func productFilters(q productQuery, args *sqlArgs) string {
+	conds := []string{"p.deleted_at IS NULL"}
+	if q.SellerID != nil {
+		conds = append(conds, "p.seller_id = "+args.add(*q.SellerID))
+	}
+	f := q.Filters
+	if f.SellerID != nil {
+		conds = append(conds, "p.seller_id = "+args.add(*f.SellerID))
+	}
+	// Category names also match the seller's tree, so aliases and
+	// subcategories are included
+	if len(f.Categories) > 0 {
+		categories := make([]string, len(f.Categories))
+		slugs := make([]string, len(f.Categories))
+		for i, category := range f.Categories {
+			categories[i] = strings.ToLower(strings.Join(strings.Fields(category), " "))
+			slugs[i] = service.Slugify(category)
+		}
+		names := args.add(categories)
+		conds = append(conds, fmt.Sprintf(`(lower(p.category) = ANY(%[1]s) OR p.category_id IN (
+			SELECT category_subtree(c.id) FROM categories c
+			WHERE c.seller_id = p.seller_id AND (lower(c.name) = ANY(%[1]s) OR c.slug = ANY(%[2]s) OR c.aliases && %[1]s)))`,
+			names, args.add(slugs)))
+	}
+	if f.CategoryID != nil {
+		conds = append(conds, "p.category_id IN (SELECT category_subtree("+args.add(*f.CategoryID)+"))")
+	}
+	// A product is in a price range when its own price or any variant's is
+	if f.MinPrice != nil || f.MaxPrice != nil {
+		priceIn := func(table string) string {
+			var bounds []string
+			if f.MinPrice != nil {
+				bounds = append(bounds, table+".price >= "+args.add(*f.MinPrice))
+			}
+			if f.MaxPrice != nil {
+				bounds = append(bounds, table+".price <= "+args.add(*f.MaxPrice))
+			}
+			return strings.Join(bounds, " AND ")
+		}
+		conds = append(conds, "(("+priceIn("p")+") OR EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = p.id AND "+priceIn("v")+"))")
+	}
+	if f.Available != nil {
+		conds = append(conds, "p.is_available = "+args.add(*f.Available))
+	}
+	if f.InStock != nil {
+		conds = append(conds, "product_in_stock(p.id) = "+args.add(*f.InStock))
+	}
+	if f.AvailableAt != nil {
+		conds = append(conds, "product_available_at(p.id, "+args.add(*f.AvailableAt)+")")
+	}
+	if len(f.Attributes) > 0 {
+		attributes, _ := json.Marshal(f.Attributes)
+		conds = append(conds, "p.attributes @> "+args.add(string(attributes))+"::JSONB")
+	}
+	if len(f.Variant) > 0 {
+		options, _ := json.Marshal(f.Variant)
+		conds = append(conds, "EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = p.id AND v.options @> "+args.add(string(options))+"::JSONB)")
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// vectorCandidates returns a CTE body ranking the nearest products by cosine
+// similarity. The inner LIMIT keeps the ANN index usable.
+func vectorCandidates(q productQuery, args *sqlArgs, candidates int) (string, error) {
+	dims := len(q.Embedding)
+	if dims == 0 {
+		return "", fmt.Errorf("empty query embedding")
+	}
+	distance := embeddingDistance("e.embedding", args.add(fmt.Sprintf("[%s]", formatFloatSlice(q.Embedding))), dims)
+	threshold := "TRUE"
+	if q.MinSimilarity != 0 {
+		threshold = fmt.Sprintf("1 - %s > %s", distance, args.add(q.MinSimilarity))
+	}
+	return fmt.Sprintf(`
+		SELECT v.id, v.similarity, ROW_NUMBER() OVER (ORDER BY v.similarity DESC) AS rnk
+		FROM (
+			SELECT p.id, 1 - %[1]s AS similarity
+			FROM product_embeddings e
+			JOIN products p ON p.id = e.product_id
+			WHERE e.model = %[2]s
+			AND %[3]s
+			AND %[4]s
+			AND %[5]s
+			ORDER BY %[1]s
+			LIMIT %[6]s
+		) v`,
+		distance, args.add(q.Model), embeddingDimensions("e.dimensions", dims), productFilters(q, args), threshold, args.add(candidates),
+	), nil
+}
+
+// textCandidates returns a CTE body ranking products by full-text rank plus
+// trigram similarity of the name, so exact terms, SKUs and near-miss
+// spellings are found even when their embeddings are not close.
+func textCandidates(q productQuery, args *sqlArgs, candidates int) string {
+	text := args.add(q.Text)
+	rank := fmt.Sprintf(
+		"(ts_rank_cd(p.search_vector, websearch_to_tsquery('oneagent_id', %[1]s), 32) + similarity(lower(p.name), lower(%[1]s)))",
+		text,
+	)
+	return fmt.Sprintf(`
+		SELECT t.id, t.text_rank, ROW_NUMBER() OVER (ORDER BY t.text_rank DESC) AS rnk
+		FROM (
+			SELECT p.id, %[1]s AS text_rank
+			FROM products p
+			WHERE (p.search_vector @@ websearch_to_tsquery('oneagent_id', %[2]s) OR lower(p.name) %% lower(%[2]s))
+			AND %[3]s
+			ORDER BY text_rank DESC
+			LIMIT %[4]s
+		) t`,
+		rank, text, productFilters(q, args), args.add(candidates),
+	)
+}
+
+// sortKeys maps each sort order to its key expression over the matches m and
+// products p, its direction, and the cast that turns a cursor key back into
+// a comparable value.
+var sortKeys = map[string]struct {
+	expr, cast string
+	desc       bool
+}{
+	sortRelevance: {"m.score", "FLOAT8", true},
+	sortPriceAsc:  {"p.price", "NUMERIC", false},
+	sortPriceDesc: {"p.price", "NUMERIC", true},
+	sortNewest:    {"p.created_at", "TIMESTAMP", true},
+}
+
+// searchProducts runs the retrieval described by q and returns one page of
+// results. Hybrid mode merges the vector and text rankings with reciprocal
+// rank fusion.
+func searchProducts(ctx context.Context, q productQuery) (*searchPage, error) {
+	if q.Mode == "" {
+		q.Mode = searchModeHybrid
+	}
+	if q.Text == "" && len(q.Embedding) == 0 {
+		q.Mode = "" // Browse by filters only
+	}
+	if q.Sort == "" {
+		q.Sort = sortRelevance
+	}
+	if q.Mode == "" && q.Sort == sortRelevance {
+		q.Sort = sortNewest // Nothing to be relevant to
+	}
+	sortKey, ok := sortKeys[q.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort %q", q.Sort)
+	}
+
+	args := &sqlArgs{}
+	// Retrievers always fill the whole pool, so fusion, re-sorting and
+	// later pages all see the same candidates
+	candidates := searchPoolSize
+
+	var ctes []string
+	var source, idExpr, simExpr, scoreExpr string
+	switch q.Mode {
+	case "":
+		ctes = append(ctes, "browse AS (SELECT p.id FROM products p WHERE "+productFilters(q, args)+")")
+		source, idExpr, simExpr, scoreExpr = "browse", "browse.id", "0::FLOAT8", "0"
+	case searchModeVector:
+		vec, err := vectorCandidates(q, args, candidates)
+		if err != nil {
+			return nil, err
+		}
+		ctes = append(ctes, "vec AS ("+vec+")")
+		source, idExpr, simExpr, scoreExpr = "vec", "vec.id", "vec.similarity", "vec.similarity"
+	case searchModeText:
+		ctes = append(ctes, "txt AS ("+textCandidates(q, args, candidates)+")")
+		source, idExpr, simExpr, scoreExpr = "txt", "txt.id", "0::FLOAT8", "txt.text_rank"
+	case searchModeHybrid:
+		vec, err := vectorCandidates(q, args, candidates)
+		if err != nil {
+			return nil, err
+		}
+		ctes = append(ctes, "vec AS ("+vec+")", "txt AS ("+textCandidates(q, args, candidates)+")")
+		source = "vec FULL OUTER JOIN txt ON txt.id = vec.id"
+		idExpr = "COALESCE(vec.id, txt.id)"
+		simExpr = "COALESCE(vec.similarity, 0)"
+		scoreExpr = fmt.Sprintf("COALESCE(1.0 / (%[1]d + vec.rnk), 0) + COALESCE(1.0 / (%[1]d + txt.rnk), 0)", rrfK)
+	default:
+		return nil, fmt.Errorf("unknown search mode %q", q.Mode)
+	}
+	ctes = append(ctes, fmt.Sprintf(
+		"matches AS (SELECT %s AS id, %s AS similarity, (%s)::FLOAT8 AS score FROM %s)",
+		idExpr, simExpr, scoreExpr, source,
+	))
+
+	direction, comparison := "ASC", ">"
+	if sortKey.desc {
+		direction, comparison = "DESC", "<"
+	}
+	after := "TRUE"
+	if q.Cursor != "" {
+		cursor, err := decodeSearchCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		// Keys of one sort order mean nothing in another
+		if cursor.Sort != q.Sort {
+			return nil, fmt.Errorf("%w: it belongs to sort %q, not %q", errInvalidCursor, cursor.Sort, q.Sort)
+		}
+		if err := checkCursorKey(cursor.Key, sortKey.cast); err != nil {
+			return nil, err
+		}
+		after = fmt.Sprintf("(%s, p.id) %s (%s::%s, %s)",
+			sortKey.expr, comparison, args.add(cursor.Key), sortKey.cast, args.add(cursor.ID))
+	}
+
+	query := fmt.Sprintf(`
+		WITH %s
+		SELECT p.id, p.name, COALESCE(p.category, ''), p.price, COALESCE(p.description, ''), p.is_available,
+			product_in_stock(p.id), available_stock(p.id, NULL), product_available_at(p.id, %s), %s, %s, %s, %s,
+			m.similarity, m.score, %s::TEXT AS sort_key,
+			(SELECT COUNT(*) FROM matches) AS total
+		FROM matches m
+		JOIN products p ON p.id = m.id
+		WHERE %s
+		ORDER BY %s %s, p.id %s
+		LIMIT %s`,
+		strings.Join(ctes, ",\n"), availabilityTime(q.At, args), service.SchedulesJSON("p.id"),
+		service.VariantsJSON("p.id"), service.ImagesJSON("p.id"), service.PromotionsJSON("p.id"), sortKey.expr, after, sortKey.expr, direction, direction, args.add(q.Limit+1),
+	)
+
+	rows, err := db.Pool.Query(ctx, query, *args...)
+	if err != nil {
+		return nil, fmt.Errorf("search query failed: %w", err)
+	}
+	defer rows.Close()
+
+	page := &searchPage{Results: []SearchResult{}}
+	var lastKey string
+	for rows.Next() {
+		var result SearchResult
+		var sortValue string
+		if err := rows.Scan(&result.ID, &result.Name, &result.Category, &result.Price, &result.Description, &result.Available,
+			&result.InStock, &result.Stock, &result.AvailableNow, &result.Schedule, &result.Variants, &result.Images,
+			&result.Promotions, &result.Similarity, &result.Score,
+			&sortValue, &page.Total); err != nil {
+			return nil, fmt.Errorf("error scanning results: %w", err)
+		}
+		service.ResolveImageURLs(result.Images)
+		applyPromotionPrices(&result)
+		if len(page.Results) == q.Limit {
+			// The extra row only tells us there is another page
+			last := page.Results[len(page.Results)-1]
+			page.NextCursor = encodeSearchCursor(searchCursor{Sort: q.Sort, Key: lastKey, ID: last.ID})
+			break
+		}
+		page.Results = append(page.Results, result)
+		lastKey = sortValue
+	}
+	return page, rows.Err()
+}
+
+// availabilityTime returns the SQL for the moment availability is
+// evaluated at: at when given, otherwise now
+func availabilityTime(at *time.Time, args *sqlArgs) string {
+	if at == nil {
+		return "NOW()"
+	}
+	return args.add(*at)
+}
+
+// applyPromotionPrices sets the effective prices of a result and its
+// variants from the promotions running on it. Prices that need a quantity,
+// like bundles, are left to the cart.
+func applyPromotionPrices(r *SearchResult) {
+	r.EffectivePrice = model.EffectivePrice(r.Promotions, r.Price)
+	for i := range r.Variants {
+		r.Variants[i].EffectivePrice = model.EffectivePrice(r.Promotions, r.Variants[i].Price)
+	}
+}
+
+// resolveSearchModel picks the embedding model to search a seller's catalog
+// with. The configured model is used once every product has a vector for it;
+// until then the model with the most vectors keeps serving, so switching
+// models doesn't blank out search while re-embedding runs.
+func resolveSearchModel(ctx context.Context, sellerID int64) (string, error) {
+	var model string
+	err := db.Pool.QueryRow(ctx, `
+		WITH configured AS (
+			SELECT seller_embedding_model($1) AS model
+		), counts AS (
+			SELECT e.model, COUNT(DISTINCT e.product_id) AS n
+			FROM product_embeddings e
+			JOIN products p ON p.id = e.product_id
+			WHERE p.seller_id = $1 AND p.deleted_at IS NULL
+			GROUP BY e.model
+		), total AS (
+			SELECT COUNT(*) AS n FROM products WHERE seller_id = $1 AND deleted_at IS NULL
+		)
+		SELECT COALESCE(
+			(SELECT c.model FROM counts c, configured, total WHERE c.model = configured.model AND c.n >= total.n),
+			(SELECT c.model FROM counts c ORDER BY c.n DESC LIMIT 1),
+			(SELECT model FROM configured)
+		)
+	`, sellerID).Scan(&model)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve search model: %w", err)
+	}
+	return model, nil
+}
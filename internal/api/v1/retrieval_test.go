@@ -0,0 +1,84 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSearchCursorRoundTrip(t *testing.T) {
+	want := searchCursor{Sort: sortPriceAsc, Key: "25000.00", ID: 42}
+	got, err := decodeSearchCursor(encodeSearchCursor(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != want {
+		t.Fatalf("cursor = %+v, want %+v", *got, want)
+	}
+}
+
+// TestSearchRejectsBadCursors checks that cursors are rejected before the
+// query runs, with an error the handlers report as a bad request
+func TestSearchRejectsBadCursors(t *testing.T) {
+	tests := []struct {
+		name   string
+		sort   string
+		cursor string
+	}{
+		{"not base64", sortNewest, "%%%"},
+		{"not json", sortNewest, "bm90IGpzb24"},
+		{"other sort", sortPriceAsc, encodeSearchCursor(searchCursor{Sort: sortPriceDesc, Key: "25000.00", ID: 1})},
+		{"relevance cursor on price sort", sortPriceAsc, encodeSearchCursor(searchCursor{Sort: sortRelevance, Key: "0.5", ID: 1})},
+		{"cursor without a sort", sortNewest, encodeSearchCursor(searchCursor{Key: "2025-01-02 03:04:05", ID: 1})},
+		{"price key is not a number", sortPriceAsc, encodeSearchCursor(searchCursor{Sort: sortPriceAsc, Key: "abc", ID: 1})},
+		{"newest key is not a time", sortNewest, encodeSearchCursor(searchCursor{Sort: sortNewest, Key: "25000.00", ID: 1})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := searchProducts(context.Background(), productQuery{Sort: tt.sort, Cursor: tt.cursor, Limit: 10})
+			if !errors.Is(err, errInvalidCursor) {
+				t.Fatalf("error = %v, want errInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestCheckCursorKey(t *testing.T) {
+	tests := []struct {
+		key, cast string
+		ok        bool
+	}{
+		{"0.0327868852459016", "FLOAT8", true},
+		{"25000.00", "NUMERIC", true},
+		{"2025-01-02 03:04:05.123456", "TIMESTAMP", true},
+		{"2025-01-02 03:04:05", "TIMESTAMP", true},
+		{"1; DROP TABLE products", "NUMERIC", false},
+		{"", "FLOAT8", false},
+		{"yesterday", "TIMESTAMP", false},
+	}
+	for _, tt := range tests {
+		if err := checkCursorKey(tt.key, tt.cast); (err == nil) != tt.ok {
+			t.Errorf("checkCursorKey(%q, %s) = %v, want ok %v", tt.key, tt.cast, err, tt.ok)
+		}
+	}
+}
+
+// TestVectorCandidatesInlinesDimensions checks that the embedding dimension
+// is part of the SQL rather than a parameter, so the planner can pick the
+// partial index for that dimension
+func TestVectorCandidatesInlinesDimensions(t *testing.T) {
+	args := &sqlArgs{}
+	sql, err := vectorCandidates(productQuery{Embedding: make([]float32, 1536), Model: "text-embedding-3-small"}, args, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sql, "e.dimensions = 1536") {
+		t.Errorf("dimension isn't inlined:\n%s", sql)
+	}
+	for _, arg := range *args {
+		if arg == 1536 {
+			t.Errorf("dimension is still bound as a parameter: %v", *args)
+		}
+	}
+}
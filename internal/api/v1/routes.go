@@ -1,12 +1,15 @@
 package v1
 
 import (
+	"context"
+	"time"
 	"github.com/gin-gonic/gin"
+	"github.com/divinecoid/oneagent/internal/model"
 	"github.com/divinecoid/oneagent/internal/service"
 	"github.com/divinecoid/oneagent/pkg/middleware"
 )
 
-func RegisterRoutes(rg *gin.RouterGroup) {
+func RegisterRoutes(rg *gin.RouterGroup, jobService *service.JobService) {
 	// Initialize services
 	authService := service.NewAuthService()
 	configService, err := service.NewConfigService()
@@ -17,6 +20,24 @@ func RegisterRoutes(rg *gin.RouterGroup) {
 	// Initialize handlers
 	authHandler := NewAuthHandler(authService)
 	configHandler := NewConfigHandler(configService)
+	inventoryService := service.NewInventoryService()
+	productHandler := NewProductHandler(jobService, service.NewProductService(), inventoryService, service.NewImageService(service.Blobs()))
+	cartHandler := NewCartHandler(inventoryService)
+	categoryHandler := NewCategoryHandler(service.NewCategoryService())
+	promotionHandler := NewPromotionHandler(service.NewPromotionService())
+	importHandler := NewImportHandler(service.NewImportService())
+	availabilityHandler := NewAvailabilityHandler(service.NewAvailabilityService(), productHandler, categoryHandler)
+	jobHandler := NewJobHandler(jobService)
+	analyticsHandler := NewAnalyticsHandler(service.NewSearchAnalyticsService())
+
+	// Register background job handlers
+	jobService.RegisterHandler(model.JobTypeProductImport, runProductImportJob)
+	jobService.RegisterHandler(model.JobTypeEmbeddingUpdate, runEmbeddingJob)
+	jobService.RegisterHandler(model.JobTypeProductEnrichment, runEnrichmentJob)
+	jobService.Every("stale-embeddings", time.Minute, func(ctx context.Context) error {
+		return enqueueStaleEmbeddingJobs(ctx, jobService)
+	})
+	jobService.Every("expired-reservations", time.Minute, inventoryService.ExpireReservations)
 	
 	// Initialize middleware
 	authMiddleware := middleware.NewAuthMiddleware(authService)
@@ -31,6 +52,9 @@ func RegisterRoutes(rg *gin.RouterGroup) {
 		auth.POST("/password-reset", authHandler.ResetPassword)
 	}
 
+	// Product images; public, optionally signed URLs
+	rg.GET("/files/*key", ServeFile)
+
 	// Protected routes
 	api := rg.Group("/")
 	api.Use(authMiddleware.SessionAuth())
@@ -41,10 +65,99 @@ func RegisterRoutes(rg *gin.RouterGroup) {
 		// Product routes
 		products := api.Group("/products")
 		{
-			products.POST("/upload", authMiddleware.RequireRole("super_admin", "seller"), UploadProductExcel)
-			products.POST("/update-embeddings", authMiddleware.RequireRole("super_admin", "seller"), UpdateProductEmbeddings)
+			products.GET("", authMiddleware.RequireRole("super_admin", "seller"), productHandler.ListProducts)
+			products.POST("", authMiddleware.RequireRole("super_admin", "seller"), productHandler.CreateProduct)
+			products.GET("/:id", authMiddleware.RequireRole("super_admin", "seller"), productHandler.GetProduct)
+			products.PUT("/:id", authMiddleware.RequireRole("super_admin", "seller"), productHandler.UpdateProduct)
+			products.PATCH("/:id", authMiddleware.RequireRole("super_admin", "seller"), productHandler.PatchProduct)
+			products.DELETE("/:id", authMiddleware.RequireRole("super_admin", "seller"), productHandler.DeleteProduct)
+			products.POST("/upload", authMiddleware.RequireRole("super_admin", "seller"), productHandler.UploadProductExcel)
+			products.POST("/import", authMiddleware.RequireRole("super_admin", "seller"), productHandler.ImportProducts)
+			products.GET("/export", authMiddleware.RequireRole("super_admin", "seller"), productHandler.ExportProducts)
+			products.GET("/import-template", authMiddleware.RequireRole("super_admin", "seller"), productHandler.ImportTemplate)
+			products.GET("/duplicates", authMiddleware.RequireRole("super_admin", "seller"), productHandler.ListDuplicates)
+			products.POST("/duplicates/merge", authMiddleware.RequireRole("super_admin", "seller"), productHandler.MergeDuplicate)
+			products.POST("/duplicates/keep", authMiddleware.RequireRole("super_admin", "seller"), productHandler.KeepDuplicate)
+			products.POST("/enrich", authMiddleware.RequireRole("super_admin", "seller"), productHandler.EnrichProducts)
+			products.GET("/suggestions", authMiddleware.RequireRole("super_admin", "seller"), productHandler.ListSuggestions)
+			products.POST("/suggestions/:id/approve", authMiddleware.RequireRole("super_admin", "seller"), productHandler.ApproveSuggestion)
+			products.POST("/suggestions/:id/reject", authMiddleware.RequireRole("super_admin", "seller"), productHandler.RejectSuggestion)
+			products.POST("/update-embeddings", authMiddleware.RequireRole("super_admin", "seller"), productHandler.UpdateProductEmbeddings)
 			products.POST("/search", SearchProducts)
 			products.POST("/chat", ChatWithProducts)
+			products.GET("/:id/similar", SimilarProducts)
+			products.POST("/:id/stock", authMiddleware.RequireRole("super_admin", "seller"), productHandler.AdjustStock)
+			products.GET("/:id/stock/movements", authMiddleware.RequireRole("super_admin", "seller"), productHandler.ListStockMovements)
+			products.GET("/:id/price-history", authMiddleware.RequireRole("super_admin", "seller"), productHandler.ListPriceHistory)
+			products.POST("/:id/images", authMiddleware.RequireRole("super_admin", "seller"), productHandler.UploadProductImages)
+			products.DELETE("/:id/images/:image_id", authMiddleware.RequireRole("super_admin", "seller"), productHandler.DeleteProductImage)
+			products.GET("/:id/schedule", authMiddleware.RequireRole("super_admin", "seller"), availabilityHandler.GetProductSchedule)
+			products.PUT("/:id/schedule", authMiddleware.RequireRole("super_admin", "seller"), availabilityHandler.SetProductSchedule)
+		}
+
+		// Import batch routes; every applied import can be rolled back
+		imports := api.Group("/imports")
+		imports.Use(authMiddleware.RequireRole("super_admin", "seller"))
+		{
+			imports.GET("", importHandler.ListImports)
+			imports.GET("/:id", importHandler.GetImport)
+			imports.GET("/:id/changes", importHandler.ListImportChanges)
+			imports.GET("/:id/file", importHandler.DownloadImportFile)
+			imports.POST("/:id/rollback", importHandler.RollbackImport)
+		}
+
+		// Category routes; each seller has its own tree
+		categories := api.Group("/categories")
+		{
+			categories.GET("", categoryHandler.ListCategories)
+			categories.GET("/:id/products", categoryHandler.CategoryProducts)
+			categories.POST("", authMiddleware.RequireRole("super_admin", "seller"), categoryHandler.CreateCategory)
+			categories.PATCH("/:id", authMiddleware.RequireRole("super_admin", "seller"), categoryHandler.UpdateCategory)
+			categories.DELETE("/:id", authMiddleware.RequireRole("super_admin", "seller"), categoryHandler.DeleteCategory)
+			categories.GET("/:id/schedule", authMiddleware.RequireRole("super_admin", "seller"), availabilityHandler.GetCategorySchedule)
+			categories.PUT("/:id/schedule", authMiddleware.RequireRole("super_admin", "seller"), availabilityHandler.SetCategorySchedule)
+		}
+
+		// Menu preview; products on the menu at a given time by their schedules
+		api.GET("/menu", availabilityHandler.PreviewMenu)
+
+		// Promotion routes; prices after promotions are computed on read
+		promotions := api.Group("/promotions")
+		promotions.Use(authMiddleware.RequireRole("super_admin", "seller"))
+		{
+			promotions.GET("", promotionHandler.ListPromotions)
+			promotions.POST("", promotionHandler.CreatePromotion)
+			promotions.GET("/:id", promotionHandler.GetPromotion)
+			promotions.PUT("/:id", promotionHandler.UpdatePromotion)
+			promotions.DELETE("/:id", promotionHandler.DeletePromotion)
+		}
+
+		// Cart routes; items hold their stock while in the cart
+		cart := api.Group("/cart")
+		{
+			cart.GET("", cartHandler.GetCart)
+			cart.POST("/items", cartHandler.AddCartItem)
+			cart.PATCH("/items/:id", cartHandler.UpdateCartItem)
+			cart.DELETE("/items/:id", cartHandler.RemoveCartItem)
+			cart.POST("/checkout", cartHandler.Checkout)
+		}
+
+		// Background job routes
+		jobs := api.Group("/jobs")
+		{
+			jobs.GET("", jobHandler.ListJobs)
+			jobs.GET("/:id", jobHandler.GetJob)
+			jobs.POST("/:id/cancel", jobHandler.CancelJob)
+			jobs.POST("/:id/retry", jobHandler.RetryJob)
+		}
+
+		// Search analytics reports, per seller
+		analytics := api.Group("/analytics/search")
+		analytics.Use(authMiddleware.RequireRole("super_admin", "seller"))
+		{
+			analytics.GET("/top-queries", analyticsHandler.TopQueries)
+			analytics.GET("/low-result-queries", analyticsHandler.PoorResultQueries)
+			analytics.GET("/unretrieved-products", analyticsHandler.UnretrievedProducts)
 		}
 
 		// Configuration routes
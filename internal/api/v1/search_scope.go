@@ -0,0 +1,89 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// errScopeForbidden is returned when the caller asks for a catalog it may not search
+	errScopeForbidden = errors.New("not allowed to search this seller's products")
+	// errScopeRequired is returned when there is no seller to scope the search to
+	errScopeRequired = errors.New("seller_id is required")
+)
+
+// resolveSearchScope decides which seller's catalog a search may see.
+// Sellers only ever see their own products. Customers see the seller whose
+// storefront (widget or channel) they are on, passed as requested.
+// Super admins must pick a seller, or set marketplace to search across all
+// sellers, in which case the returned seller is nil.
+func resolveSearchScope(role model.Role, userID int64, requested *int64, marketplace bool) (*int64, error) {
+	if marketplace {
+		if role != model.RoleSuperAdmin {
+			return nil, errScopeForbidden
+		}
+		return nil, nil
+	}
+
+	switch role {
+	case model.RoleSeller:
+		if requested != nil && *requested != userID {
+			return nil, errScopeForbidden
+		}
+		return &userID, nil
+	default:
+		if requested == nil {
+			return nil, errScopeRequired
+		}
+		sellerID := *requested
+		return &sellerID, nil
+	}
+}
+
+// requestedSeller returns the storefront seller sent by the widget or channel
+// in the X-Seller-ID header, falling back to the given body value
+func requestedSeller(c *gin.Context, fallback *int64) (*int64, error) {
+	raw := c.GetHeader("X-Seller-ID")
+	if raw == "" {
+		return fallback, nil
+	}
+	sellerID, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("X-Seller-ID must be a number")
+	}
+	return &sellerID, nil
+}
+
+// searchScope resolves the seller a request may search, writing the error
+// response itself when the scope is missing or not allowed
+func searchScope(c *gin.Context, fallback *int64, marketplace bool) (*int64, bool) {
+	requested, err := requestedSeller(c, fallback)
+	if err == nil {
+		var sellerID *int64
+		sellerID, err = resolveSearchScope(model.Role(c.GetString("user_role")), c.MustGet("user_id").(int64), requested, marketplace)
+		if err == nil {
+			return sellerID, true
+		}
+	}
+
+	status, message := http.StatusBadRequest, "Invalid request parameters"
+	if errors.Is(err, errScopeForbidden) {
+		status, message = http.StatusForbidden, "Access denied"
+	}
+	c.JSON(status, APIResponse{
+		Success: false,
+		Message: message,
+		Errors:  gin.H{"scope_error": err.Error()},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+	return nil, false
+}
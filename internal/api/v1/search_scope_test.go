@@ -0,0 +1,151 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+func int64Ptr(v int64) *int64 { return &v }
+
+func TestResolveSearchScope(t *testing.T) {
+	tests := []struct {
+		name        string
+		role        model.Role
+		userID      int64
+		requested   *int64
+		marketplace bool
+		want        *int64
+		wantErr     error
+	}{
+		{"seller defaults to own catalog", model.RoleSeller, 7, nil, false, int64Ptr(7), nil},
+		{"seller may name own catalog", model.RoleSeller, 7, int64Ptr(7), false, int64Ptr(7), nil},
+		{"seller cannot search a competitor", model.RoleSeller, 7, int64Ptr(8), false, nil, errScopeForbidden},
+		{"seller cannot use marketplace", model.RoleSeller, 7, nil, true, nil, errScopeForbidden},
+		{"customer searches the storefront seller", model.RoleCustomer, 20, int64Ptr(8), false, int64Ptr(8), nil},
+		{"customer needs a storefront seller", model.RoleCustomer, 20, nil, false, nil, errScopeRequired},
+		{"customer cannot use marketplace", model.RoleCustomer, 20, int64Ptr(8), true, nil, errScopeForbidden},
+		{"super admin picks a seller", model.RoleSuperAdmin, 1, int64Ptr(8), false, int64Ptr(8), nil},
+		{"super admin must pick a seller or marketplace", model.RoleSuperAdmin, 1, nil, false, nil, errScopeRequired},
+		{"super admin marketplace searches everyone", model.RoleSuperAdmin, 1, nil, true, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveSearchScope(tt.role, tt.userID, tt.requested, tt.marketplace)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Fatalf("seller = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSearchScope checks the seller a request is scoped to, or the response
+// it gets, for each role with the seller in the X-Seller-ID header, the body,
+// or both
+func TestSearchScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name        string
+		role        model.Role
+		userID      int64
+		header      string
+		body        *int64
+		marketplace bool
+		want        *int64
+		wantStatus  int // Response status when the scope is refused
+	}{
+		{"seller without a seller searches own catalog", model.RoleSeller, 7, "", nil, false, int64Ptr(7), 0},
+		{"seller naming itself in the header", model.RoleSeller, 7, "7", nil, false, int64Ptr(7), 0},
+		{"seller naming another seller in the header", model.RoleSeller, 7, "8", nil, false, nil, http.StatusForbidden},
+		{"seller naming another seller in the body", model.RoleSeller, 7, "", int64Ptr(8), false, nil, http.StatusForbidden},
+		{"header wins over the body", model.RoleSeller, 7, "8", int64Ptr(7), false, nil, http.StatusForbidden},
+		{"seller asking for the marketplace", model.RoleSeller, 7, "", nil, true, nil, http.StatusForbidden},
+		{"customer on a storefront header", model.RoleCustomer, 20, "8", nil, false, int64Ptr(8), 0},
+		{"customer with the seller in the body", model.RoleCustomer, 20, "", int64Ptr(8), false, int64Ptr(8), 0},
+		{"customer header wins over the body", model.RoleCustomer, 20, "8", int64Ptr(9), false, int64Ptr(8), 0},
+		{"customer without a seller", model.RoleCustomer, 20, "", nil, false, nil, http.StatusBadRequest},
+		{"customer with a malformed header", model.RoleCustomer, 20, "abc", int64Ptr(8), false, nil, http.StatusBadRequest},
+		{"customer asking for the marketplace", model.RoleCustomer, 20, "8", nil, true, nil, http.StatusForbidden},
+		{"super admin picking a seller", model.RoleSuperAdmin, 1, "", int64Ptr(8), false, int64Ptr(8), 0},
+		{"super admin without a seller", model.RoleSuperAdmin, 1, "", nil, false, nil, http.StatusBadRequest},
+		{"super admin marketplace", model.RoleSuperAdmin, 1, "", nil, true, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/search", nil)
+			if tt.header != "" {
+				c.Request.Header.Set("X-Seller-ID", tt.header)
+			}
+			c.Set("user_role", string(tt.role))
+			c.Set("user_id", tt.userID)
+
+			got, ok := searchScope(c, tt.body, tt.marketplace)
+			if ok != (tt.wantStatus == 0) {
+				t.Fatalf("ok = %v, want %v (status %d)", ok, tt.wantStatus == 0, w.Code)
+			}
+			if !ok {
+				if w.Code != tt.wantStatus {
+					t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+				}
+				return
+			}
+			if w.Body.Len() > 0 {
+				t.Errorf("a resolved scope wrote a response: %s", w.Body)
+			}
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("seller = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestScopedSearchFiltersBySeller checks that every retriever of a scoped
+// search restricts products to the seller, including browsing
+func TestScopedSearchFiltersBySeller(t *testing.T) {
+	q := productQuery{
+		Mode:      searchModeHybrid,
+		Text:      "mie ayam",
+		Embedding: []float32{0.1, 0.2, 0.3},
+		Model:     defaultEmbeddingModel,
+		SellerID:  int64Ptr(8),
+	}
+
+	args := &sqlArgs{}
+	vec, err := vectorCandidates(q, args, searchPoolSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txt := textCandidates(q, args, searchPoolSize)
+	browse := productFilters(productQuery{SellerID: int64Ptr(8)}, args)
+
+	for name, sql := range map[string]string{"vector": vec, "text": txt, "browse": browse} {
+		if !strings.Contains(sql, "p.seller_id = $") {
+			t.Errorf("%s retriever is not scoped to the seller:\n%s", name, sql)
+		}
+	}
+	sellerArgs := 0
+	for _, arg := range *args {
+		if v, ok := arg.(int64); ok && v == 8 {
+			sellerArgs++
+		}
+	}
+	if sellerArgs != 3 {
+		t.Errorf("seller ID bound %d times, want 3", sellerArgs)
+	}
+}
+
+func TestMarketplaceSearchIsUnscoped(t *testing.T) {
+	if got := productFilters(productQuery{}, &sqlArgs{}); strings.Contains(got, "seller_id") {
+		t.Errorf("marketplace filters = %q, want no seller condition", got)
+	}
+}
@@ -0,0 +1,304 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/divinecoid/oneagent/internal/db"
+	"github.com/divinecoid/oneagent/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// errProductNotFound is returned when a product doesn't exist
+var errProductNotFound = errors.New("product not found")
+
+// errNoEmbedding is returned when a product has no vector yet to compare with
+var errNoEmbedding = errors.New("product has no embedding yet")
+
+// similarQuery describes a nearest-neighbour lookup for one product. Results
+// always come from the product's own seller and exclude the product itself.
+type similarQuery struct {
+	ProductID    int64
+	SellerID     *int64  // the product must belong to this seller; nil allows any
+	PriceBand    float64 // e.g. 0.2 keeps prices within 20% of the product's; 0 disables
+	MinPrice     *float64
+	MaxPrice     *float64
+	SameCategory bool
+	Categories   []string
+	Available    *bool
+	InStock      *bool
+	AvailableAt  *time.Time // only products on the menu at that moment
+	ExcludeIDs   []int64
+	Limit        int
+}
+
+// similarProducts returns the products nearest to q.ProductID by its stored
+// embedding, using the model its seller's catalog is currently searched with.
+// Legacy products without a seller have no catalog and are reported missing.
+func similarProducts(ctx context.Context, q similarQuery) ([]SearchResult, error) {
+	var sellerID int64
+	var price float64
+	var category string
+	err := db.Pool.QueryRow(ctx, `
+		SELECT seller_id, price, COALESCE(category, '') FROM products
+		WHERE id = $1 AND seller_id IS NOT NULL AND deleted_at IS NULL
+	`, q.ProductID).Scan(&sellerID, &price, &category)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && q.SellerID != nil && *q.SellerID != sellerID) {
+		// Other sellers' products are reported as missing rather than forbidden
+		return nil, errProductNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product: %w", err)
+	}
+
+	model, err := resolveSearchModel(ctx, sellerID)
+	if err != nil {
+		return nil, err
+	}
+	var dims int
+	err = db.Pool.QueryRow(ctx, `
+		SELECT dimensions FROM product_embeddings WHERE product_id = $1 AND model = $2
+	`, q.ProductID, model).Scan(&dims)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, errNoEmbedding
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product embedding: %w", err)
+	}
+
+	filters := SearchFilters{
+		Categories:  q.Categories,
+		MinPrice:    q.MinPrice,
+		MaxPrice:    q.MaxPrice,
+		Available:   q.Available,
+		InStock:     q.InStock,
+		AvailableAt: q.AvailableAt,
+	}
+	if q.SameCategory && category != "" {
+		filters.Categories = []string{category}
+	}
+	if q.PriceBand > 0 {
+		low, high := price*(1-q.PriceBand), price*(1+q.PriceBand)
+		if filters.MinPrice == nil || *filters.MinPrice < low {
+			filters.MinPrice = &low
+		}
+		if filters.MaxPrice == nil || *filters.MaxPrice > high {
+			filters.MaxPrice = &high
+		}
+	}
+
+	args := &sqlArgs{}
+	productID, modelParam := args.add(q.ProductID), args.add(model)
+	// The source vector is a scalar subquery, so the ANN index is still used
+	source := fmt.Sprintf("(SELECT embedding FROM product_embeddings WHERE product_id = %s AND model = %s AND %s)",
+		productID, modelParam, embeddingDimensions("dimensions", dims))
+	distance := embeddingDistance("e.embedding", source, dims)
+	conds := productFilters(productQuery{SellerID: &sellerID, Filters: filters}, args)
+	if len(q.ExcludeIDs) > 0 {
+		conds += " AND NOT (p.id = ANY(" + args.add(q.ExcludeIDs) + "))"
+	}
+
+	rows, err := db.Pool.Query(ctx, fmt.Sprintf(`
+		SELECT p.id, p.name, COALESCE(p.category, ''), p.price, COALESCE(p.description, ''), p.is_available,
+			product_in_stock(p.id), available_stock(p.id, NULL), product_available_at(p.id, NOW()), %[7]s,
+			%[8]s, %[9]s, %[10]s, 1 - %[1]s AS similarity
+		FROM product_embeddings e
+		JOIN products p ON p.id = e.product_id
+		WHERE e.model = %[2]s
+		AND %[3]s
+		AND p.id <> %[4]s
+		AND %[5]s
+		ORDER BY %[1]s
+		LIMIT %[6]s`,
+		distance, modelParam, embeddingDimensions("e.dimensions", dims), productID, conds, args.add(q.Limit), service.SchedulesJSON("p.id"),
+		service.VariantsJSON("p.id"), service.ImagesJSON("p.id"), service.PromotionsJSON("p.id"),
+	), *args...)
+	if err != nil {
+		return nil, fmt.Errorf("similar products query failed: %w", err)
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var r SearchResult
+		if err := rows.Scan(&r.ID, &r.Name, &r.Category, &r.Price, &r.Description, &r.Available,
+			&r.InStock, &r.Stock, &r.AvailableNow, &r.Schedule, &r.Variants, &r.Images, &r.Promotions,
+			&r.Similarity); err != nil {
+			return nil, fmt.Errorf("error scanning results: %w", err)
+		}
+		service.ResolveImageURLs(r.Images)
+		applyPromotionPrices(&r)
+		r.Score = r.Similarity
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// findSubstitutes suggests in-stock alternatives, on the menu now, for
+// products in the chat results that can't be ordered. Only the first few such products are looked
+// up to keep chat latency bounded.
+func findSubstitutes(ctx context.Context, results []SearchResult) map[int64][]SearchResult {
+	const maxLookups, perProduct = 2, 3
+
+	exclude := make([]int64, len(results))
+	for i, r := range results {
+		exclude[i] = r.ID
+	}
+	inStock, now := true, time.Now()
+	substitutes := map[int64][]SearchResult{}
+	for _, r := range results {
+		if r.InStock && r.AvailableNow {
+			continue
+		}
+		if len(substitutes) == maxLookups {
+			break
+		}
+		found, err := similarProducts(ctx, similarQuery{
+			ProductID:   r.ID,
+			PriceBand:   0.3,
+			InStock:     &inStock,
+			AvailableAt: &now,
+			ExcludeIDs:  exclude,
+			Limit:       perProduct,
+		})
+		if err != nil || len(found) == 0 {
+			continue
+		}
+		substitutes[r.ID] = found
+		for _, s := range found {
+			exclude = append(exclude, s.ID)
+		}
+	}
+	return substitutes
+}
+
+// SimilarProducts returns the nearest neighbours of a product within its
+// seller's catalog. The product must be in the caller's search scope.
+// Optional query parameters: limit, price_band (fraction of the product's
+// price), min_price, max_price, same_category, category (comma separated),
+// available, in_stock, available_now, and marketplace for super admins.
+func SimilarProducts(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "Invalid product ID",
+			Errors:  gin.H{"error": "product ID must be a number"},
+			Meta: MetaData{
+				RequestID: c.GetHeader("X-Request-ID"),
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+			},
+		})
+		return
+	}
+
+	q := similarQuery{ProductID: productID, Limit: 5}
+	var paramErr error
+	if raw := c.Query("limit"); raw != "" {
+		if q.Limit, err = strconv.Atoi(raw); err != nil || q.Limit <= 0 || q.Limit > 50 {
+			paramErr = fmt.Errorf("limit must be between 1 and 50")
+		}
+	}
+	if raw := c.Query("price_band"); raw != "" {
+		if q.PriceBand, err = strconv.ParseFloat(raw, 64); err != nil || q.PriceBand < 0 || q.PriceBand > 1 {
+			paramErr = fmt.Errorf("price_band must be a fraction between 0 and 1")
+		}
+	}
+	for name, target := range map[string]**float64{"min_price": &q.MinPrice, "max_price": &q.MaxPrice} {
+		if raw := c.Query(name); raw != "" {
+			value, err := strconv.ParseFloat(raw, 64)
+			if err != nil || value < 0 {
+				paramErr = fmt.Errorf("%s must be a non-negative number", name)
+				continue
+			}
+			*target = &value
+		}
+	}
+	if raw := c.Query("same_category"); raw != "" {
+		if q.SameCategory, err = strconv.ParseBool(raw); err != nil {
+			paramErr = fmt.Errorf("same_category must be true or false")
+		}
+	}
+	if raw := c.Query("available"); raw != "" {
+		available, err := strconv.ParseBool(raw)
+		if err != nil {
+			paramErr = fmt.Errorf("available must be true or false")
+		}
+		q.Available = &available
+	}
+	if raw := c.Query("in_stock"); raw != "" {
+		inStock, err := strconv.ParseBool(raw)
+		if err != nil {
+			paramErr = fmt.Errorf("in_stock must be true or false")
+		}
+		q.InStock = &inStock
+	}
+	if raw := c.Query("available_now"); raw != "" {
+		availableNow, err := strconv.ParseBool(raw)
+		if err != nil {
+			paramErr = fmt.Errorf("available_now must be true or false")
+		} else if availableNow {
+			now := time.Now()
+			q.AvailableAt = &now
+		}
+	}
+	if raw := c.Query("category"); raw != "" {
+		q.Categories = strings.Split(raw, ",")
+	}
+	if paramErr != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Message: "Invalid request parameters",
+			Errors:  gin.H{"validation_error": paramErr.Error()},
+			Meta: MetaData{
+				RequestID: c.GetHeader("X-Request-ID"),
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+			},
+		})
+		return
+	}
+
+	marketplace, _ := strconv.ParseBool(c.Query("marketplace"))
+	sellerID, ok := searchScope(c, nil, marketplace)
+	if !ok {
+		return
+	}
+	q.SellerID = sellerID
+
+	results, err := similarProducts(c.Request.Context(), q)
+	if err != nil {
+		status, message := http.StatusInternalServerError, "Failed to find similar products"
+		switch {
+		case errors.Is(err, errProductNotFound):
+			status, message = http.StatusNotFound, "Product not found"
+		case errors.Is(err, errNoEmbedding):
+			status, message = http.StatusConflict, "Product has not been embedded yet"
+		}
+		c.JSON(status, APIResponse{
+			Success: false,
+			Message: message,
+			Errors:  gin.H{"error": err.Error()},
+			Meta: MetaData{
+				RequestID: c.GetHeader("X-Request-ID"),
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Similar products retrieved successfully",
+		Data:    gin.H{"product_id": productID, "products": results},
+		Meta: MetaData{
+			RequestID: c.GetHeader("X-Request-ID"),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
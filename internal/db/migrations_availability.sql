@@ -0,0 +1,87 @@
+-- Time-based availability: products or whole categories that are only on the
+-- menu at certain hours and weekdays, e.g. breakfast items or weekend specials.
+-- Schedules are in the seller's time zone, kept in its configuration.
+ALTER TABLE user_configurations
+    ADD COLUMN IF NOT EXISTS timezone VARCHAR(50) NOT NULL DEFAULT 'Asia/Jakarta';
+
+-- Time zone of a seller's most recent configuration
+CREATE OR REPLACE FUNCTION seller_timezone(p_seller_id BIGINT)
+RETURNS TEXT LANGUAGE sql STABLE AS $$
+    SELECT COALESCE((
+        SELECT timezone FROM user_configurations
+        WHERE user_id = p_seller_id
+        ORDER BY created_at DESC
+        LIMIT 1
+    ), 'Asia/Jakarta')
+$$;
+
+-- A window in which a product or category is on the menu. A window whose end
+-- is not after its start runs past midnight into the next day; days_of_week
+-- (ISO, 1 = Monday) are the days it starts on, and empty means every day.
+CREATE TABLE IF NOT EXISTS availability_schedules (
+    id BIGSERIAL PRIMARY KEY,
+    seller_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    product_id BIGINT REFERENCES products(id) ON DELETE CASCADE,
+    category_id BIGINT REFERENCES categories(id) ON DELETE CASCADE,
+    days_of_week SMALLINT[] NOT NULL DEFAULT '{}',
+    start_time TIME NOT NULL,
+    end_time TIME NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK ((product_id IS NULL) <> (category_id IS NULL)),
+    CHECK (days_of_week <@ '{1,2,3,4,5,6,7}'),
+    CHECK (start_time <> end_time)
+);
+
+CREATE INDEX IF NOT EXISTS idx_availability_schedules_product ON availability_schedules(product_id);
+CREATE INDEX IF NOT EXISTS idx_availability_schedules_category ON availability_schedules(category_id);
+
+-- Whether a window covers a local time. model.AvailabilityWindow.Covers is
+-- the same rule in Go, and a test checks that they agree; change both.
+CREATE OR REPLACE FUNCTION schedule_covers(p_days SMALLINT[], p_start TIME, p_end TIME, p_local TIMESTAMP)
+RETURNS BOOLEAN LANGUAGE sql IMMUTABLE AS $$
+    SELECT CASE WHEN p_start < p_end THEN
+        (cardinality(p_days) = 0 OR EXTRACT(ISODOW FROM p_local)::SMALLINT = ANY(p_days))
+        AND p_local::TIME >= p_start AND p_local::TIME < p_end
+    ELSE
+        ((cardinality(p_days) = 0 OR EXTRACT(ISODOW FROM p_local)::SMALLINT = ANY(p_days))
+            AND p_local::TIME >= p_start)
+        OR ((cardinality(p_days) = 0 OR EXTRACT(ISODOW FROM p_local - INTERVAL '1 day')::SMALLINT = ANY(p_days))
+            AND p_local::TIME < p_end)
+    END
+$$;
+
+-- The schedules that decide when a product is on the menu: its own, or else
+-- those of the nearest category above it that has any
+CREATE OR REPLACE FUNCTION product_schedules(p_product_id BIGINT)
+RETURNS SETOF availability_schedules LANGUAGE sql STABLE AS $$
+    WITH RECURSIVE ancestry AS (
+        SELECT p.category_id AS id, 1 AS depth
+        FROM products p
+        WHERE p.id = p_product_id AND p.category_id IS NOT NULL
+        UNION ALL
+        SELECT c.parent_id, a.depth + 1
+        FROM categories c
+        JOIN ancestry a ON c.id = a.id
+        WHERE c.parent_id IS NOT NULL
+    ),
+    applicable AS (
+        SELECT s.*, 0 AS depth FROM availability_schedules s WHERE s.product_id = p_product_id
+        UNION ALL
+        SELECT s.*, a.depth FROM availability_schedules s JOIN ancestry a ON s.category_id = a.id
+    )
+    SELECT id, seller_id, product_id, category_id, days_of_week, start_time, end_time, created_at
+    FROM applicable
+    WHERE depth = (SELECT MIN(depth) FROM applicable)
+$$;
+
+-- Whether a product is on the menu at a moment. Products without schedules
+-- always are.
+CREATE OR REPLACE FUNCTION product_available_at(p_product_id BIGINT, p_at TIMESTAMPTZ)
+RETURNS BOOLEAN LANGUAGE sql STABLE AS $$
+    SELECT NOT EXISTS (SELECT 1 FROM product_schedules(p_product_id))
+        OR EXISTS (
+            SELECT 1
+            FROM product_schedules(p_product_id) s
+            WHERE schedule_covers(s.days_of_week, s.start_time, s.end_time, p_at AT TIME ZONE seller_timezone(s.seller_id))
+        )
+$$;
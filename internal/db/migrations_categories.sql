@@ -0,0 +1,61 @@
+-- Per-seller category tree. products.category keeps the category's name as
+-- display text (and for embeddings); products.category_id links the product
+-- into the tree. Aliases are stored lowercased and map other spellings, e.g.
+-- "drinks" or "minum", onto a category.
+CREATE TABLE IF NOT EXISTS categories (
+    id BIGSERIAL PRIMARY KEY,
+    seller_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    parent_id BIGINT REFERENCES categories(id) ON DELETE RESTRICT,
+    name VARCHAR(100) NOT NULL,
+    slug VARCHAR(120) NOT NULL,
+    aliases TEXT[] NOT NULL DEFAULT '{}',
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (seller_id, slug)
+);
+
+CREATE INDEX IF NOT EXISTS idx_categories_parent ON categories(seller_id, parent_id, position);
+CREATE INDEX IF NOT EXISTS idx_categories_aliases ON categories USING gin (aliases);
+
+CREATE TRIGGER update_categories_updated_at
+    BEFORE UPDATE ON categories
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS category_id BIGINT REFERENCES categories(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_products_category_id ON products(category_id);
+
+-- IDs of a category and all categories below it
+CREATE OR REPLACE FUNCTION category_subtree(p_root BIGINT)
+RETURNS SETOF BIGINT LANGUAGE sql STABLE AS $$
+    WITH RECURSIVE tree AS (
+        SELECT id FROM categories WHERE id = p_root
+        UNION ALL
+        SELECT c.id FROM categories c JOIN tree t ON c.parent_id = t.id
+    )
+    SELECT id FROM tree
+$$;
+
+-- Seed each seller's tree with the distinct free-text categories already in
+-- use, merging spellings that only differ in case or punctuation. Product
+-- text is left as entered, so existing embeddings stay valid.
+WITH existing AS (
+    SELECT DISTINCT ON (seller_id, slug) seller_id, trim(category) AS name, slug
+    FROM (
+        SELECT seller_id, category,
+            trim(BOTH '-' FROM regexp_replace(lower(trim(category)), '[^a-z0-9]+', '-', 'g')) AS slug
+        FROM products
+        WHERE seller_id IS NOT NULL AND deleted_at IS NULL AND trim(COALESCE(category, '')) <> ''
+    ) c
+    WHERE slug <> ''
+    ORDER BY seller_id, slug, category
+)
+INSERT INTO categories (seller_id, name, slug)
+SELECT seller_id, name, slug FROM existing
+ON CONFLICT (seller_id, slug) DO NOTHING;
+
+UPDATE products p SET category_id = c.id
+FROM categories c
+WHERE p.category_id IS NULL AND c.seller_id = p.seller_id
+AND c.slug = trim(BOTH '-' FROM regexp_replace(lower(trim(p.category)), '[^a-z0-9]+', '-', 'g'));
@@ -0,0 +1,9 @@
+-- Reranking stage between retrieval and prompt building: which scorer to use
+-- (none, lexical or llm) and how many candidates to over-fetch for it
+ALTER TABLE user_configurations
+    ADD COLUMN IF NOT EXISTS reranker VARCHAR(10) NOT NULL DEFAULT 'lexical',
+    ADD COLUMN IF NOT EXISTS rerank_candidates INTEGER NOT NULL DEFAULT 20;
+
+ALTER TABLE user_configurations DROP CONSTRAINT IF EXISTS chk_user_configurations_rerank_candidates;
+ALTER TABLE user_configurations ADD CONSTRAINT chk_user_configurations_rerank_candidates
+    CHECK (rerank_candidates BETWEEN 1 AND 100);
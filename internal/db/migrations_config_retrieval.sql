@@ -0,0 +1,17 @@
+-- Retrieval tuning per configuration, replacing the literals in the chat pipeline
+ALTER TABLE user_configurations
+    ADD COLUMN IF NOT EXISTS similarity_threshold DOUBLE PRECISION NOT NULL DEFAULT 0.3,
+    ADD COLUMN IF NOT EXISTS top_k INTEGER NOT NULL DEFAULT 5,
+    ADD COLUMN IF NOT EXISTS fallback_mode VARCHAR(10) NOT NULL DEFAULT 'broader',
+    ADD COLUMN IF NOT EXISTS fallback_top_k INTEGER NOT NULL DEFAULT 3,
+    ADD COLUMN IF NOT EXISTS max_context_chars INTEGER NOT NULL DEFAULT 6000,
+    ADD COLUMN IF NOT EXISTS include_prices BOOLEAN NOT NULL DEFAULT TRUE;
+
+ALTER TABLE user_configurations DROP CONSTRAINT IF EXISTS chk_user_configurations_retrieval;
+ALTER TABLE user_configurations ADD CONSTRAINT chk_user_configurations_retrieval CHECK (
+    similarity_threshold BETWEEN 0 AND 1
+    AND top_k BETWEEN 1 AND 50
+    AND fallback_mode IN ('none', 'broader')
+    AND fallback_top_k BETWEEN 1 AND 50
+    AND max_context_chars BETWEEN 500 AND 100000
+);
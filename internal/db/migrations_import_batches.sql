@@ -0,0 +1,46 @@
+-- Import batches: every catalog import that was applied, with its source file,
+-- row outcomes and a snapshot of each product it changed, so a bad upload can
+-- be rolled back.
+CREATE TABLE IF NOT EXISTS import_batches (
+    id BIGSERIAL PRIMARY KEY,
+    seller_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    job_id BIGINT REFERENCES jobs(id) ON DELETE SET NULL,
+    file_name VARCHAR(255) NOT NULL,
+    format VARCHAR(10) NOT NULL,
+    mode VARCHAR(10) NOT NULL,
+    source_key VARCHAR(255) NOT NULL, -- Blob store key of the uploaded file
+    source_size INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'applied' CHECK (status IN ('applied', 'rolled_back')),
+    created_count INTEGER NOT NULL DEFAULT 0,
+    updated_count INTEGER NOT NULL DEFAULT 0,
+    deactivated_count INTEGER NOT NULL DEFAULT 0,
+    rejected_count INTEGER NOT NULL DEFAULT 0,
+    report JSONB,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    rolled_back_at TIMESTAMP,
+    rolled_back_by BIGINT REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_import_batches_seller ON import_batches(seller_id, created_at DESC);
+-- A job imports its file once; a retried attempt can't commit a second batch.
+-- Batches retries already committed twice stay, but only the first keeps its job.
+UPDATE import_batches b SET job_id = NULL
+WHERE EXISTS (SELECT 1 FROM import_batches o WHERE o.job_id = b.job_id AND o.id < b.id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_import_batches_job ON import_batches(job_id);
+
+-- A product an import batch changed. before and after are the product as the
+-- API returns it; before is NULL for products the batch created. embeddings
+-- holds the product's vectors before the import, which it may have dropped.
+CREATE TABLE IF NOT EXISTS import_batch_items (
+    batch_id BIGINT NOT NULL REFERENCES import_batches(id) ON DELETE CASCADE,
+    product_id BIGINT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    action VARCHAR(20) NOT NULL CHECK (action IN ('created', 'updated', 'unchanged', 'deactivated')),
+    before JSONB,
+    after JSONB NOT NULL,
+    embeddings JSONB NOT NULL DEFAULT '[]',
+    image_ids BIGINT[] NOT NULL DEFAULT '{}', -- Images the batch attached
+    PRIMARY KEY (batch_id, product_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_import_batch_items_product ON import_batch_items(product_id);
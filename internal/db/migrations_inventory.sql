@@ -0,0 +1,96 @@
+-- Inventory: stock levels per product and variant, an audit trail of stock
+-- movements, and reservations that hold stock for cart items until they expire.
+-- A NULL stock means stock isn't tracked and the item never sells out.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS stock INTEGER CHECK (stock >= 0);
+
+ALTER TABLE carts ADD COLUMN IF NOT EXISTS variant_id BIGINT REFERENCES product_variants(id);
+-- A variant in customers' carts can't be deleted, so saving a product without
+-- it is refused rather than emptying the carts. Deleting the product itself
+-- still deletes its cart lines.
+ALTER TABLE carts DROP CONSTRAINT IF EXISTS carts_variant_id_fkey;
+ALTER TABLE carts ADD CONSTRAINT carts_variant_id_fkey FOREIGN KEY (variant_id) REFERENCES product_variants(id);
+
+-- One cart line per user and item, so concurrent adds of the same item can't
+-- create two. Lines duplicated before the index existed are folded into the
+-- oldest one first.
+UPDATE carts c SET qty = d.qty
+FROM (
+    SELECT MIN(id) AS id, SUM(qty) AS qty FROM carts
+    GROUP BY user_id, product_id, COALESCE(variant_id, 0)
+    HAVING COUNT(*) > 1
+) d
+WHERE c.id = d.id;
+DELETE FROM carts c USING carts k
+WHERE k.user_id = c.user_id AND k.product_id = c.product_id
+AND COALESCE(k.variant_id, 0) = COALESCE(c.variant_id, 0) AND k.id < c.id;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_carts_user_item ON carts(user_id, product_id, COALESCE(variant_id, 0));
+
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id BIGSERIAL PRIMARY KEY,
+    product_id BIGINT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    variant_id BIGINT REFERENCES product_variants(id) ON DELETE SET NULL,
+    change INTEGER NOT NULL,
+    stock_after INTEGER NOT NULL,
+    reason VARCHAR(20) NOT NULL CHECK (reason IN ('import', 'sale', 'adjustment')),
+    note TEXT,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- The trail outlives deleted variants, which keep their name here
+ALTER TABLE stock_movements ADD COLUMN IF NOT EXISTS variant_name VARCHAR(100);
+UPDATE stock_movements m SET variant_name = v.name
+FROM product_variants v
+WHERE v.id = m.variant_id AND m.variant_name IS NULL;
+ALTER TABLE stock_movements DROP CONSTRAINT IF EXISTS stock_movements_variant_id_fkey;
+ALTER TABLE stock_movements ADD CONSTRAINT stock_movements_variant_id_fkey
+    FOREIGN KEY (variant_id) REFERENCES product_variants(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_stock_movements_product ON stock_movements(product_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS stock_reservations (
+    id BIGSERIAL PRIMARY KEY,
+    cart_id BIGINT NOT NULL UNIQUE REFERENCES carts(id) ON DELETE CASCADE,
+    product_id BIGINT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    variant_id BIGINT REFERENCES product_variants(id) ON DELETE CASCADE,
+    qty INTEGER NOT NULL CHECK (qty > 0),
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_reservations_item ON stock_reservations(product_id, variant_id, expires_at);
+
+-- Stock of a product (p_variant_id NULL) or variant minus its unexpired
+-- reservations; NULL when stock isn't tracked
+CREATE OR REPLACE FUNCTION available_stock(p_product_id BIGINT, p_variant_id BIGINT)
+RETURNS INTEGER LANGUAGE sql STABLE AS $$
+    SELECT GREATEST(s.stock - COALESCE((
+        SELECT SUM(r.qty)::INTEGER
+        FROM stock_reservations r
+        WHERE r.product_id = p_product_id
+        AND r.variant_id IS NOT DISTINCT FROM p_variant_id
+        AND r.expires_at > NOW()
+    ), 0), 0)
+    FROM (
+        SELECT CASE WHEN p_variant_id IS NULL
+            THEN (SELECT stock FROM products WHERE id = p_product_id)
+            ELSE (SELECT stock FROM product_variants WHERE id = p_variant_id)
+        END AS stock
+    ) s
+$$;
+
+-- Whether a product can be ordered: it is available and, if it has variants,
+-- at least one variant is available and in stock
+CREATE OR REPLACE FUNCTION product_in_stock(p_product_id BIGINT)
+RETURNS BOOLEAN LANGUAGE sql STABLE AS $$
+    SELECT p.is_available AND CASE
+        WHEN EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = p.id) THEN EXISTS (
+            SELECT 1 FROM product_variants v
+            WHERE v.product_id = p.id AND v.is_available
+            AND COALESCE(available_stock(p.id, v.id), 1) > 0
+        )
+        ELSE COALESCE(available_stock(p.id, NULL), 1) > 0
+    END
+    FROM products p
+    WHERE p.id = p_product_id
+$$;
@@ -0,0 +1,2 @@
+-- Structured outcome of a job, e.g. the per-row report of a product import
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS result JSONB;
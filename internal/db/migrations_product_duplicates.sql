@@ -0,0 +1,27 @@
+-- Duplicate products: pairs a seller marked as distinct, so the duplicate
+-- report stops suggesting them, and the merges that folded one product into
+-- another. Pairs are stored with the lower product ID first.
+CREATE TABLE IF NOT EXISTS product_duplicate_dismissals (
+    product_id BIGINT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    other_id BIGINT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (product_id, other_id),
+    CHECK (product_id < other_id)
+);
+
+-- The merged product is soft-deleted; carts, search logs and promotions that
+-- pointed at it were moved to the surviving product
+CREATE TABLE IF NOT EXISTS product_merges (
+    id BIGSERIAL PRIMARY KEY,
+    seller_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    survivor_id BIGINT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    merged_id BIGINT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    cart_items_moved INTEGER NOT NULL DEFAULT 0,
+    search_logs_updated INTEGER NOT NULL DEFAULT 0,
+    promotions_updated INTEGER NOT NULL DEFAULT 0,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_merges_seller ON product_merges(seller_id, created_at DESC);
@@ -0,0 +1,41 @@
+-- Store embeddings per (product, model, dimensions) so sellers can use models
+-- with different vector sizes, and a new model can be filled in alongside the
+-- old one before it takes over
+CREATE TABLE IF NOT EXISTS product_embeddings (
+    product_id BIGINT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    model VARCHAR(100) NOT NULL,
+    dimensions INTEGER NOT NULL,
+    embedding vector NOT NULL,
+    content_hash CHAR(32) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (product_id, model, dimensions),
+    CHECK (vector_dims(embedding) = dimensions)
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_embeddings_model ON product_embeddings(model, dimensions);
+
+-- Per-dimension ANN indexes; queries cast to the same type to use them.
+-- HNSW indexes at most 2000 vector dimensions, so larger ones use halfvec.
+CREATE INDEX IF NOT EXISTS idx_product_embeddings_768 ON product_embeddings
+    USING hnsw ((embedding::vector(768)) vector_cosine_ops) WHERE dimensions = 768;
+CREATE INDEX IF NOT EXISTS idx_product_embeddings_1024 ON product_embeddings
+    USING hnsw ((embedding::vector(1024)) vector_cosine_ops) WHERE dimensions = 1024;
+CREATE INDEX IF NOT EXISTS idx_product_embeddings_1536 ON product_embeddings
+    USING hnsw ((embedding::vector(1536)) vector_cosine_ops) WHERE dimensions = 1536;
+CREATE INDEX IF NOT EXISTS idx_product_embeddings_3072 ON product_embeddings
+    USING hnsw ((embedding::halfvec(3072)) halfvec_cosine_ops) WHERE dimensions = 3072;
+
+-- Move existing vectors out of products
+INSERT INTO product_embeddings (product_id, model, dimensions, embedding, content_hash)
+SELECT id, COALESCE(embedding_model, 'text-embedding-3-small'), vector_dims(embedding), embedding,
+       COALESCE(embedding_hash, content_hash)
+FROM products
+WHERE embedding IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+DROP INDEX IF EXISTS idx_products_embedding;
+DROP INDEX IF EXISTS idx_products_embedding_model;
+ALTER TABLE products DROP COLUMN IF EXISTS embedding;
+ALTER TABLE products DROP COLUMN IF EXISTS embedding_hash;
+ALTER TABLE products DROP COLUMN IF EXISTS embedding_model;
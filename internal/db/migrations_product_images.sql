@@ -0,0 +1,19 @@
+-- Product images. Files live in the blob store; rows keep their keys and the
+-- key of a generated thumbnail. The checksum makes uploading or importing the
+-- same picture twice a no-op.
+CREATE TABLE IF NOT EXISTS product_images (
+    id BIGSERIAL PRIMARY KEY,
+    product_id BIGINT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    blob_key VARCHAR(255) NOT NULL,
+    thumbnail_key VARCHAR(255) NOT NULL,
+    content_type VARCHAR(50) NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    size_bytes INTEGER NOT NULL,
+    checksum CHAR(64) NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (product_id, checksum)
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_images_product ON product_images(product_id, position);
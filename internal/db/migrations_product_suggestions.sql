@@ -0,0 +1,23 @@
+-- Product suggestions: descriptions, categories and attributes an enrichment
+-- job had the seller's model write. They change nothing until the seller
+-- approves them. suggested and original hold the same fields; original is the
+-- product's content when the suggestion was written.
+CREATE TABLE IF NOT EXISTS product_suggestions (
+    id BIGSERIAL PRIMARY KEY,
+    product_id BIGINT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    seller_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    job_id BIGINT REFERENCES jobs(id) ON DELETE SET NULL,
+    model VARCHAR(100) NOT NULL,
+    suggested JSONB NOT NULL,
+    original JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    applied_fields TEXT[] NOT NULL DEFAULT '{}',
+    reviewed_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- A new run replaces a product's pending suggestion rather than piling up
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_suggestions_pending ON product_suggestions(product_id)
+    WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_product_suggestions_seller ON product_suggestions(seller_id, status, created_at DESC);
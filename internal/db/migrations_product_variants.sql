@@ -0,0 +1,24 @@
+-- Purchasable options of a product, such as a size or spice level, each with
+-- its own price, SKU and stock. A product without variants is sold as is.
+CREATE TABLE IF NOT EXISTS product_variants (
+    id BIGSERIAL PRIMARY KEY,
+    product_id BIGINT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    options JSONB NOT NULL DEFAULT '{}', -- e.g. {"size": "large", "level": "3"}
+    sku VARCHAR(100),
+    price DECIMAL(10,2) NOT NULL CHECK (price >= 0),
+    stock INTEGER CHECK (stock >= 0), -- NULL when stock isn't tracked
+    is_available BOOLEAN NOT NULL DEFAULT TRUE,
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (product_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_variants_options ON product_variants USING gin (options jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS idx_product_variants_price ON product_variants(price);
+
+CREATE TRIGGER update_product_variants_updated_at
+    BEFORE UPDATE ON product_variants
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
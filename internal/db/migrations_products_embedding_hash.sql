@@ -0,0 +1,26 @@
+-- Track what each stored embedding was generated from, so edited products
+-- and model changes can be detected and re-embedded
+ALTER TABLE products ADD COLUMN IF NOT EXISTS content_hash CHAR(32)
+    GENERATED ALWAYS AS (md5(name || '|' || COALESCE(category, '') || '|' || COALESCE(description, ''))) STORED;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS embedding_hash CHAR(32);
+ALTER TABLE products ADD COLUMN IF NOT EXISTS embedding_model VARCHAR(50);
+
+-- Existing embeddings were generated from the current text with the default model
+UPDATE products
+SET embedding_hash = content_hash, embedding_model = 'text-embedding-3-small'
+WHERE embedding IS NOT NULL AND embedding_hash IS NULL;
+
+-- Embedding model from the seller's most recent configuration
+CREATE OR REPLACE FUNCTION seller_embedding_model(p_seller_id BIGINT)
+RETURNS VARCHAR LANGUAGE sql STABLE AS $$
+    SELECT COALESCE(
+        (SELECT NULLIF(openai_embedding_model, '')
+         FROM user_configurations
+         WHERE user_id = p_seller_id
+         ORDER BY created_at DESC
+         LIMIT 1),
+        'text-embedding-3-small'
+    )
+$$;
+
+CREATE INDEX IF NOT EXISTS idx_products_embedding_model ON products(embedding_model);
@@ -0,0 +1,7 @@
+-- Structured attributes and availability used by search filters
+ALTER TABLE products ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE products ADD COLUMN IF NOT EXISTS is_available BOOLEAN NOT NULL DEFAULT TRUE;
+
+CREATE INDEX IF NOT EXISTS idx_products_attributes ON products USING gin (attributes jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS idx_products_category_lower ON products(lower(category));
+CREATE INDEX IF NOT EXISTS idx_products_created_at ON products(created_at DESC, id DESC);
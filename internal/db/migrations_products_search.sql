@@ -0,0 +1,34 @@
+-- Full-text and fuzzy search on products, used alongside vector search
+CREATE EXTENSION IF NOT EXISTS unaccent;
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Indonesian stemming with accents stripped ("kopi susu" matches "Kopi Susú");
+-- falls back to the simple dictionary where the Indonesian stemmer is missing
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = 'oneagent_id') THEN
+        IF EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = 'indonesian') THEN
+            CREATE TEXT SEARCH CONFIGURATION oneagent_id (COPY = indonesian);
+            ALTER TEXT SEARCH CONFIGURATION oneagent_id
+                ALTER MAPPING FOR hword, hword_part, word WITH unaccent, indonesian_stem;
+        ELSE
+            CREATE TEXT SEARCH CONFIGURATION oneagent_id (COPY = simple);
+            ALTER TEXT SEARCH CONFIGURATION oneagent_id
+                ALTER MAPPING FOR hword, hword_part, word WITH unaccent, simple;
+        END IF;
+    END IF;
+END$$;
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('oneagent_id', COALESCE(name, '')), 'A') ||
+        setweight(to_tsvector('oneagent_id', COALESCE(category, '')), 'B') ||
+        setweight(to_tsvector('oneagent_id', COALESCE(description, '')), 'C')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_products_search_vector ON products USING gin (search_vector);
+CREATE INDEX IF NOT EXISTS idx_products_name_trgm ON products USING gin (lower(name) gin_trgm_ops);
+
+-- Retrieval mode per configuration: vector, text or hybrid
+ALTER TABLE user_configurations
+    ADD COLUMN IF NOT EXISTS search_mode VARCHAR(10) NOT NULL DEFAULT 'hybrid';
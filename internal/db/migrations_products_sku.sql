@@ -0,0 +1,6 @@
+-- Seller-assigned product code (SKU or POS item ID), used to match rows when a
+-- catalog is imported again
+ALTER TABLE products ADD COLUMN IF NOT EXISTS sku VARCHAR(100);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_seller_sku ON products(seller_id, sku)
+    WHERE sku IS NOT NULL AND deleted_at IS NULL;
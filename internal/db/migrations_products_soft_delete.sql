@@ -0,0 +1,4 @@
+-- Soft delete for products; deleted products are hidden from every read path
+ALTER TABLE products ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_products_seller_active ON products(seller_id, id) WHERE deleted_at IS NULL;
@@ -0,0 +1,91 @@
+-- Promotions: discount rules a seller runs for a time, over the whole store,
+-- some products or some categories (with their subcategories). Prices after
+-- promotions are computed when products are read, never stored.
+--   percentage:  value percent off each unit
+--   fixed:       value off each unit
+--   bundle:      every buy_qty units cost value together ("3 for 50k")
+--   buy_x_get_y: of every buy_qty + get_qty units, get_qty are free ("beli 2 gratis 1")
+CREATE TABLE IF NOT EXISTS promotions (
+    id BIGSERIAL PRIMARY KEY,
+    seller_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('percentage', 'fixed', 'bundle', 'buy_x_get_y')),
+    value DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (value >= 0),
+    buy_qty INTEGER NOT NULL DEFAULT 0 CHECK (buy_qty >= 0),
+    get_qty INTEGER NOT NULL DEFAULT 0 CHECK (get_qty >= 0),
+    -- Empty scopes apply the promotion to every product of the seller
+    product_ids BIGINT[] NOT NULL DEFAULT '{}',
+    category_ids BIGINT[] NOT NULL DEFAULT '{}',
+    -- ISO days (1 = Monday) the promotion runs on in its time zone; empty is every day
+    days_of_week SMALLINT[] NOT NULL DEFAULT '{}',
+    timezone VARCHAR(50) NOT NULL DEFAULT 'Asia/Jakarta',
+    starts_at TIMESTAMPTZ,
+    ends_at TIMESTAMPTZ,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK (ends_at IS NULL OR starts_at IS NULL OR ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_promotions_seller ON promotions(seller_id) WHERE is_active;
+
+DROP TRIGGER IF EXISTS update_promotions_updated_at ON promotions;
+CREATE TRIGGER update_promotions_updated_at
+    BEFORE UPDATE ON promotions
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Promotions of a product's seller that are running now and cover the product
+CREATE OR REPLACE FUNCTION product_promotions(p_product_id BIGINT)
+RETURNS SETOF promotions LANGUAGE sql STABLE AS $$
+    SELECT pr.*
+    FROM products p
+    JOIN promotions pr ON pr.seller_id = p.seller_id
+    WHERE p.id = p_product_id
+    AND pr.is_active
+    AND (pr.starts_at IS NULL OR pr.starts_at <= NOW())
+    AND (pr.ends_at IS NULL OR pr.ends_at > NOW())
+    AND (cardinality(pr.days_of_week) = 0
+        OR EXTRACT(ISODOW FROM NOW() AT TIME ZONE pr.timezone)::SMALLINT = ANY(pr.days_of_week))
+    AND (
+        (cardinality(pr.product_ids) = 0 AND cardinality(pr.category_ids) = 0)
+        OR p.id = ANY(pr.product_ids)
+        OR p.category_id IN (SELECT category_subtree(c) FROM unnest(pr.category_ids) c)
+    )
+$$;
+
+-- Every change to the price of a product or variant. old_price is NULL for
+-- the price an item was created with.
+CREATE TABLE IF NOT EXISTS price_history (
+    id BIGSERIAL PRIMARY KEY,
+    product_id BIGINT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    variant_id BIGINT REFERENCES product_variants(id) ON DELETE SET NULL,
+    old_price DECIMAL(10,2),
+    new_price DECIMAL(10,2) NOT NULL,
+    reason VARCHAR(20) NOT NULL CHECK (reason IN ('import', 'adjustment')),
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- The history outlives deleted variants, which keep their name here; rows
+-- without one are the product's own price
+ALTER TABLE price_history ADD COLUMN IF NOT EXISTS variant_name VARCHAR(100);
+UPDATE price_history h SET variant_name = v.name
+FROM product_variants v
+WHERE v.id = h.variant_id AND h.variant_name IS NULL;
+ALTER TABLE price_history DROP CONSTRAINT IF EXISTS price_history_variant_id_fkey;
+ALTER TABLE price_history ADD CONSTRAINT price_history_variant_id_fkey
+    FOREIGN KEY (variant_id) REFERENCES product_variants(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_price_history_product ON price_history(product_id, created_at DESC);
+
+-- Seed the history with current prices so later changes have a starting point
+INSERT INTO price_history (product_id, variant_id, new_price, reason)
+SELECT p.id, NULL, p.price, 'import'
+FROM products p
+WHERE NOT EXISTS (SELECT 1 FROM price_history h WHERE h.product_id = p.id AND h.variant_name IS NULL);
+
+INSERT INTO price_history (product_id, variant_id, variant_name, new_price, reason)
+SELECT v.product_id, v.id, v.name, v.price, 'import'
+FROM product_variants v
+WHERE NOT EXISTS (SELECT 1 FROM price_history h WHERE h.variant_id = v.id);
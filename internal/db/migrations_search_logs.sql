@@ -0,0 +1,19 @@
+-- One row per product search or chat retrieval, for seller search analytics
+CREATE TABLE IF NOT EXISTS search_logs (
+    id BIGSERIAL PRIMARY KEY,
+    seller_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    source VARCHAR(10) NOT NULL,            -- search or chat
+    query TEXT NOT NULL,
+    normalized_query TEXT NOT NULL,         -- lowercased, whitespace collapsed; used for grouping
+    mode VARCHAR(10),
+    result_ids BIGINT[] NOT NULL DEFAULT '{}',
+    result_count INTEGER NOT NULL DEFAULT 0,
+    top_similarity DOUBLE PRECISION,        -- NULL when no vector scores were computed
+    latency_ms INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_search_logs_seller_created ON search_logs (seller_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_search_logs_seller_query ON search_logs (seller_id, normalized_query);
+CREATE INDEX IF NOT EXISTS idx_search_logs_result_ids ON search_logs USING gin (result_ids);
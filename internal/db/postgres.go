@@ -6,10 +6,15 @@ import (
 	"os"
 	"path/filepath"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 var DB *pgx.Conn
 
+// Pool is shared by work that runs concurrently with request handling,
+// such as background job workers.
+var Pool *pgxpool.Pool
+
 func Connect() error {
 	connStr := os.Getenv("DATABASE_URL")
 	if connStr == "" {
@@ -22,6 +27,11 @@ func Connect() error {
 		return fmt.Errorf("unable to connect to database: %v", err)
 	}
 
+	Pool, err = pgxpool.New(context.Background(), connStr)
+	if err != nil {
+		return fmt.Errorf("unable to create connection pool: %v", err)
+	}
+
 	// Run migrations
 	if err := runMigrations(); err != nil {
 		return fmt.Errorf("failed to run migrations: %v", err)
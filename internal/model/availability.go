@@ -0,0 +1,90 @@
+package model
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AvailabilityWindow is a time range in which a product or category is on
+// the menu, in the seller's time zone. Start and End are "15:04"; a window
+// whose end isn't after its start runs past midnight. DaysOfWeek are the ISO
+// weekdays (1 is Monday) it starts on; empty means every day.
+type AvailabilityWindow struct {
+	ID         int64  `json:"id,omitempty"`
+	DaysOfWeek []int  `json:"days_of_week"`
+	Start      string `json:"start"`
+	End        string `json:"end"`
+}
+
+// Covers reports whether the window covers local, a time in the seller's
+// time zone. It is the rule schedule_covers applies in SQL: a window past
+// midnight covers the rest of each of its days and the early hours of the
+// day after.
+func (w AvailabilityWindow) Covers(local time.Time) bool {
+	start, errStart := time.Parse("15:04", w.Start)
+	end, errEnd := time.Parse("15:04", w.End)
+	if errStart != nil || errEnd != nil {
+		return false
+	}
+	clock := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute +
+		time.Duration(local.Second())*time.Second + time.Duration(local.Nanosecond())
+	from := time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute
+	to := time.Duration(end.Hour())*time.Hour + time.Duration(end.Minute())*time.Minute
+
+	if from < to {
+		return w.startsOn(local) && clock >= from && clock < to
+	}
+	return (w.startsOn(local) && clock >= from) || (w.startsOn(local.AddDate(0, 0, -1)) && clock < to)
+}
+
+// startsOn reports whether the window opens on day's weekday
+func (w AvailabilityWindow) startsOn(day time.Time) bool {
+	if len(w.DaysOfWeek) == 0 {
+		return true
+	}
+	iso := int(day.Weekday())
+	if iso == 0 {
+		iso = 7 // Sunday
+	}
+	return slices.Contains(w.DaysOfWeek, iso)
+}
+
+var weekdayNames = [...]string{1: "Mon", 2: "Tue", 3: "Wed", 4: "Thu", 5: "Fri", 6: "Sat", 7: "Sun"}
+
+// String describes the window compactly, e.g. "Mon-Fri 06:00-10:00"
+func (w AvailabilityWindow) String() string {
+	if len(w.DaysOfWeek) == 0 || len(w.DaysOfWeek) == 7 {
+		return "daily " + w.Start + "-" + w.End
+	}
+	days := append([]int(nil), w.DaysOfWeek...)
+	sort.Ints(days)
+	var runs []string
+	for i := 0; i < len(days); {
+		j := i
+		for j+1 < len(days) && days[j+1] == days[j]+1 {
+			j++
+		}
+		switch {
+		case j == i:
+			runs = append(runs, weekdayNames[days[i]])
+		case j == i+1:
+			runs = append(runs, weekdayNames[days[i]], weekdayNames[days[j]])
+		default:
+			runs = append(runs, weekdayNames[days[i]]+"-"+weekdayNames[days[j]])
+		}
+		i = j + 1
+	}
+	return strings.Join(runs, ", ") + " " + w.Start + "-" + w.End
+}
+
+// DescribeSchedule joins windows into one line, e.g.
+// "Mon-Fri 06:00-10:00; Sat, Sun 07:00-11:00"
+func DescribeSchedule(windows []AvailabilityWindow) string {
+	parts := make([]string, len(windows))
+	for i, w := range windows {
+		parts[i] = w.String()
+	}
+	return strings.Join(parts, "; ")
+}
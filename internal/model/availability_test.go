@@ -0,0 +1,101 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAvailabilityWindowCovers(t *testing.T) {
+	// at returns a time in the week starting on Monday 2025-06-02; day is ISO
+	at := func(day int, clock string) time.Time {
+		c, err := time.Parse("15:04", clock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return time.Date(2025, 6, 1+day, c.Hour(), c.Minute(), 0, 0, time.UTC)
+	}
+	breakfast := AvailabilityWindow{DaysOfWeek: []int{1, 2, 3, 4, 5}, Start: "06:00", End: "10:00"}
+	lateNight := AvailabilityWindow{DaysOfWeek: []int{5, 6}, Start: "22:00", End: "02:00"} // Fri and Sat nights
+	everyNight := AvailabilityWindow{Start: "20:00", End: "04:00"}
+	sundayNight := AvailabilityWindow{DaysOfWeek: []int{7}, Start: "23:00", End: "01:00"}
+
+	tests := []struct {
+		name   string
+		window AvailabilityWindow
+		local  time.Time
+		want   bool
+	}{
+		{"day window at its start", breakfast, at(1, "06:00"), true},
+		{"day window just before its end", breakfast, at(5, "09:59"), true},
+		{"day window at its end", breakfast, at(1, "10:00"), false},
+		{"day window before its start", breakfast, at(1, "05:59"), false},
+		{"day window on another day", breakfast, at(6, "07:00"), false},
+
+		{"overnight on its day before midnight", lateNight, at(5, "23:30"), true},
+		{"overnight after midnight, started the day before", lateNight, at(6, "01:30"), true},
+		{"overnight after midnight of its last day", lateNight, at(7, "01:59"), true},
+		{"overnight at its end", lateNight, at(7, "02:00"), false},
+		{"overnight after midnight, nothing started the day before", lateNight, at(5, "01:00"), false},
+		{"overnight in the daytime gap", lateNight, at(6, "12:00"), false},
+		{"overnight on a day it doesn't start", lateNight, at(4, "23:00"), false},
+		{"overnight at midnight", everyNight, at(3, "00:00"), true},
+		{"every night before midnight", everyNight, at(3, "20:00"), true},
+		{"every night in the morning", everyNight, at(3, "04:00"), false},
+		{"sunday night runs into monday", sundayNight, at(1, "00:30"), true},
+		{"sunday night doesn't cover saturday", sundayNight, at(7, "00:30"), false},
+
+		{"malformed window", AvailabilityWindow{Start: "6am", End: "10:00"}, at(1, "07:00"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.window.Covers(tt.local); got != tt.want {
+				t.Errorf("%s covers %s = %v, want %v", tt.window, tt.local.Format("Mon 15:04"), got, tt.want)
+			}
+		})
+	}
+}
+
+// TestAvailabilityWindowCoversInSellerZone checks that a moment is judged by
+// the seller's clock, including when that puts it on another day
+func TestAvailabilityWindowCoversInSellerZone(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta") // UTC+7
+	if err != nil {
+		t.Skip(err)
+	}
+	jayapura, err := time.LoadLocation("Asia/Jayapura") // UTC+9
+	if err != nil {
+		t.Skip(err)
+	}
+	breakfast := AvailabilityWindow{DaysOfWeek: []int{1}, Start: "06:00", End: "10:00"}
+	lateNight := AvailabilityWindow{DaysOfWeek: []int{5}, Start: "22:00", End: "02:00"}
+
+	tests := []struct {
+		name   string
+		window AvailabilityWindow
+		at     string // UTC
+		loc    *time.Location
+		want   bool
+	}{
+		// 23:30 UTC on Sunday is 06:30 Monday in Jakarta
+		{"monday breakfast from sunday UTC", breakfast, "2025-06-01 23:30", jakarta, true},
+		{"same moment in UTC is sunday night", breakfast, "2025-06-01 23:30", time.UTC, false},
+		// 01:30 UTC on Monday is 10:30 Monday in Jayapura, after breakfast
+		{"breakfast over further east", breakfast, "2025-06-02 01:30", jayapura, false},
+		{"still breakfast in jakarta", breakfast, "2025-06-02 01:30", jakarta, true},
+		// 17:30 UTC on Friday is 00:30 Saturday in Jakarta, Friday night's window
+		{"friday night past local midnight", lateNight, "2025-06-06 17:30", jakarta, true},
+		// 19:30 UTC on Friday is 04:30 Saturday in Jayapura, after it closed
+		{"friday night closed further east", lateNight, "2025-06-06 19:30", jayapura, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			utc, err := time.Parse("2006-01-02 15:04", tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.window.Covers(utc.In(tt.loc)); got != tt.want {
+				t.Errorf("%s covers %s UTC in %s = %v, want %v", tt.window, tt.at, tt.loc, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// Category is a node in a seller's category tree. Aliases are other
+// spellings that map onto the category, stored lowercased.
+type Category struct {
+	ID           int64       `json:"id"`
+	SellerID     int64       `json:"seller_id"`
+	ParentID     *int64      `json:"parent_id"`
+	Name         string      `json:"name"`
+	Slug         string      `json:"slug"`
+	Aliases      []string    `json:"aliases"`
+	Position     int         `json:"position"`
+	ProductCount int         `json:"product_count"` // Products directly in this category
+	Children     []*Category `json:"children,omitempty"`
+	CreatedAt    time.Time   `json:"created_at"`
+	UpdatedAt    time.Time   `json:"updated_at"`
+}
@@ -18,6 +18,16 @@ type UserConfiguration struct {
 	WhatsappTokenExpires  time.Time `json:"whatsapp_token_expires,omitempty"`
 	OpenAIModel           string    `json:"openai_model"`
 	OpenAIEmbeddingModel  string    `json:"openai_embedding_model"`
+	SearchMode            string    `json:"search_mode"` // vector, text or hybrid
+	Reranker              string    `json:"reranker"`    // none, lexical or llm
+	RerankCandidates      int       `json:"rerank_candidates"`
+	SimilarityThreshold   float64   `json:"similarity_threshold"` // 0 disables the cutoff
+	TopK                  int       `json:"top_k"`
+	FallbackMode          string    `json:"fallback_mode"` // none or broader
+	FallbackTopK          int       `json:"fallback_top_k"`
+	MaxContextChars       int       `json:"max_context_chars"`
+	IncludePrices         bool      `json:"include_prices"`
+	Timezone              string    `json:"timezone"` // IANA zone menu schedules are in, e.g. Asia/Jakarta
 	CreatedAt             time.Time `json:"created_at"`
 	UpdatedAt             time.Time `json:"updated_at"`
 	CreatedBy             int64     `json:"created_by"`
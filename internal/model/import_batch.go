@@ -0,0 +1,126 @@
+package model
+
+import (
+	"reflect"
+	"time"
+)
+
+// ImportActionDeactivated is what replace mode does to products missing from
+// the file. It only appears on import batch items, never on rows.
+const ImportActionDeactivated ImportAction = "deactivated"
+
+// Import batch statuses
+const (
+	ImportBatchApplied    = "applied"
+	ImportBatchRolledBack = "rolled_back"
+)
+
+// ImportBatch is a catalog import that was applied. The source file is kept
+// in the blob store and the products it touched are snapshotted, so the
+// batch can be rolled back.
+type ImportBatch struct {
+	ID           int64         `json:"id"`
+	SellerID     int64         `json:"seller_id"`
+	JobID        *int64        `json:"job_id,omitempty"`
+	FileName     string        `json:"file_name"`
+	Format       string        `json:"format"`
+	Mode         string        `json:"mode"`
+	SourceKey    string        `json:"-"`
+	SourceSize   int           `json:"source_size"`
+	Status       string        `json:"status"`
+	Created      int           `json:"created"`
+	Updated      int           `json:"updated"`
+	Deactivated  int           `json:"deactivated"`
+	Rejected     int           `json:"rejected"`
+	Report       *ImportReport `json:"report,omitempty"` // Row outcomes; only when a single batch is read
+	CreatedBy    int64         `json:"created_by"`
+	CreatedAt    time.Time     `json:"created_at"`
+	RolledBackAt *time.Time    `json:"rolled_back_at,omitempty"`
+	RolledBackBy *int64        `json:"rolled_back_by,omitempty"`
+}
+
+// ImportBatchItem is one product an import batch changed. Before is nil for
+// products the batch created; ImageIDs are the images it attached.
+type ImportBatchItem struct {
+	ProductID int64         `json:"product_id"`
+	Action    ImportAction  `json:"action"`
+	Before    *Product      `json:"before"`
+	After     *Product      `json:"after"`
+	ImageIDs  []int64       `json:"image_ids,omitempty"`
+	Changes   []FieldChange `json:"changes,omitempty"`
+}
+
+// ImportRollback is the outcome of rolling back an import batch. Products
+// changed or deleted since the import are listed as conflicts; they stop the
+// rollback unless it is forced.
+type ImportRollback struct {
+	BatchID   int64            `json:"batch_id"`
+	Restored  int              `json:"restored"` // Products put back to their state before the import
+	Removed   int              `json:"removed"`  // Products the import created, now deleted
+	Conflicts []ImportConflict `json:"conflicts"`
+	Forced    bool             `json:"forced"`
+}
+
+// ImportConflict is a product that changed after the import that touched it
+type ImportConflict struct {
+	ProductID int64         `json:"product_id"`
+	Name      string        `json:"name"`
+	Reason    string        `json:"reason"`
+	Changes   []FieldChange `json:"changes,omitempty"` // From the import's result to now
+}
+
+// FieldChange is a product field that differs between two snapshots
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+// ProductChanges lists the catalog fields that differ from before to after.
+// Computed fields such as available stock and images are not compared.
+func ProductChanges(before, after *Product) []FieldChange {
+	if before == nil || after == nil {
+		return nil
+	}
+	var changes []FieldChange
+	add := func(field string, a, b any) {
+		if !reflect.DeepEqual(a, b) {
+			changes = append(changes, FieldChange{Field: field, Before: a, After: b})
+		}
+	}
+	add("sku", before.SKU, after.SKU)
+	add("name", before.Name, after.Name)
+	add("category", before.Category, after.Category)
+	add("category_id", before.CategoryID, after.CategoryID)
+	add("price", before.Price, after.Price)
+	add("description", before.Description, after.Description)
+	add("attributes", emptyAsNil(before.Attributes), emptyAsNil(after.Attributes))
+	add("is_available", before.IsAvailable, after.IsAvailable)
+	add("stock", before.Stock, after.Stock)
+	add("variants", comparableVariants(before.Variants), comparableVariants(after.Variants))
+	return changes
+}
+
+func emptyAsNil(m map[string]any) map[string]any {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+// comparableVariants drops the fields of variants that are computed on read
+// or assigned by the database
+func comparableVariants(variants []ProductVariant) []ProductVariant {
+	if len(variants) == 0 {
+		return nil
+	}
+	out := make([]ProductVariant, len(variants))
+	for i, v := range variants {
+		if len(v.Options) == 0 {
+			v.Options = nil
+		}
+		v.ID, v.AvailableStock, v.EffectivePrice = 0, nil, nil
+		out[i] = v
+	}
+	return out
+}
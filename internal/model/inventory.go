@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+// Reasons a stock level changed
+const (
+	StockReasonImport     = "import"
+	StockReasonSale       = "sale"
+	StockReasonAdjustment = "adjustment"
+)
+
+// LowStockThreshold is the available quantity at or below which an item is
+// reported as running low
+const LowStockThreshold = 5
+
+// StockMovement is one change to the stock of a product or variant
+type StockMovement struct {
+	ID          int64     `json:"id"`
+	ProductID   int64     `json:"product_id"`
+	VariantID   *int64    `json:"variant_id,omitempty"` // Nil once the variant is deleted
+	VariantName string    `json:"variant_name,omitempty"`
+	Change      int       `json:"change"`
+	StockAfter  int       `json:"stock_after"`
+	Reason      string    `json:"reason"`
+	Note        string    `json:"note,omitempty"`
+	CreatedBy   *int64    `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// CartItem is a line in a user's cart. While the reservation hasn't expired,
+// the quantity is held out of the available stock. Discount is what the best
+// running promotion takes off the line; Subtotal is after the discount.
+type CartItem struct {
+	ID                   int64      `json:"id"`
+	ProductID            int64      `json:"product_id"`
+	VariantID            *int64     `json:"variant_id,omitempty"`
+	ProductName          string     `json:"product_name"`
+	VariantName          string     `json:"variant_name,omitempty"`
+	Qty                  int        `json:"qty"`
+	Price                float64    `json:"price"`
+	Discount             float64    `json:"discount"`
+	Promotion            string     `json:"promotion,omitempty"` // Label of the promotion giving the discount
+	Subtotal             float64    `json:"subtotal"`
+	ReservationExpiresAt *time.Time `json:"reservation_expires_at,omitempty"`
+	CreatedAt            time.Time  `json:"created_at"`
+}
+
+// Cart is a user's cart with its totals. Subtotal is before discounts.
+type Cart struct {
+	Items    []CartItem `json:"items"`
+	Subtotal float64    `json:"subtotal"`
+	Discount float64    `json:"discount"`
+	Total    float64    `json:"total"`
+}
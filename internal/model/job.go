@@ -0,0 +1,55 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type JobType string
+
+const (
+	JobTypeProductImport     JobType = "product_import"
+	JobTypeEmbeddingUpdate   JobType = "embedding_update"
+	JobTypeProductEnrichment JobType = "product_enrichment"
+)
+
+type JobStatus string
+
+const (
+	JobStatusQueued    JobStatus = "queued"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusSucceeded JobStatus = "succeeded"
+	JobStatusFailed    JobStatus = "failed"
+	JobStatusCancelled JobStatus = "cancelled"
+)
+
+type Job struct {
+	ID              int64           `json:"id"`
+	Type            JobType         `json:"type"`
+	Status          JobStatus       `json:"status"`
+	SellerID        *int64          `json:"seller_id,omitempty"`
+	Payload         json.RawMessage `json:"payload"`
+	Input           []byte          `json:"-"` // Raw upload kept for the worker, not exposed in JSON
+	TotalItems      int             `json:"total_items"`
+	ProcessedItems  int             `json:"processed_items"`
+	FailedItems     int             `json:"failed_items"`
+	Attempts        int             `json:"attempts"`
+	MaxAttempts     int             `json:"max_attempts"`
+	CancelRequested bool            `json:"cancel_requested"`
+	LastError       string          `json:"last_error,omitempty"`
+	Result          json.RawMessage `json:"result,omitempty"`
+	CreatedBy       int64           `json:"created_by"`
+	CreatedAt       time.Time       `json:"created_at"`
+	StartedAt       *time.Time      `json:"started_at,omitempty"`
+	FinishedAt      *time.Time      `json:"finished_at,omitempty"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+type JobError struct {
+	ID        int64     `json:"id"`
+	JobID     int64     `json:"job_id"`
+	Attempt   int       `json:"attempt"`
+	ItemRef   string    `json:"item_ref"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -1,10 +1,67 @@
 package model
 
+import "time"
+
 type Product struct {
-    ID          int64   `json:"id"`
-    Name        string  `json:"name"`
-    Category    string  `json:"category"`
-    Price       float64 `json:"price"`
-    Description string  `json:"description"`
-    Embedding   []float32 `json:"-"`
+    ID          int64          `json:"id"`
+    SellerID    int64          `json:"seller_id"`
+    SKU         string         `json:"sku"`
+    Name        string         `json:"name"`
+    Category    string         `json:"category"`
+    CategoryID  *int64         `json:"category_id"`
+    Price       float64        `json:"price"`
+    Description string         `json:"description"`
+    Attributes  map[string]any `json:"attributes"`
+    IsAvailable bool           `json:"is_available"`
+
+    // Stock is nil when it isn't tracked. Products with variants track stock
+    // per variant instead. AvailableStock is Stock minus cart reservations.
+    Stock          *int `json:"stock"`
+    AvailableStock *int `json:"available_stock,omitempty"`
+
+    Variants  []ProductVariant `json:"variants"`
+    Images    []ProductImage   `json:"images"`
+    Embedding []float32        `json:"-"`
+    CreatedAt time.Time        `json:"created_at"`
+    UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// ProductVariant is a purchasable option of a product, e.g. "Large" or
+// "Level 3", with its own price. Stock is nil when it isn't tracked.
+type ProductVariant struct {
+    ID          int64             `json:"id,omitempty"`
+    Name        string            `json:"name"`
+    Options     map[string]string `json:"options,omitempty"`
+    SKU         string            `json:"sku,omitempty"`
+    Price       float64           `json:"price"`
+    Stock       *int              `json:"stock,omitempty"`
+    IsAvailable bool              `json:"is_available"`
+
+    // AvailableStock is Stock minus cart reservations; it is computed on read
+    AvailableStock *int `json:"available_stock,omitempty"`
+    // EffectivePrice is the price after running promotions, when one lowers
+    // it; it is computed on read in search results
+    EffectivePrice *float64 `json:"effective_price,omitempty"`
+}
+
+// InStock reports whether the variant can be ordered
+func (v ProductVariant) InStock() bool {
+    stock := v.Stock
+    if v.AvailableStock != nil {
+        stock = v.AvailableStock
+    }
+    return v.IsAvailable && (stock == nil || *stock > 0)
+}
+
+// ProductImage is an uploaded picture of a product and its thumbnail. The
+// URLs come from the blob store and may expire when it signs them.
+type ProductImage struct {
+    ID           int64  `json:"id"`
+    Key          string `json:"key"`
+    ThumbnailKey string `json:"thumbnail_key"`
+    URL          string `json:"url"`
+    ThumbnailURL string `json:"thumbnail_url"`
+    ContentType  string `json:"content_type"`
+    Width        int    `json:"width"`
+    Height       int    `json:"height"`
 }
@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// DuplicateProduct is one side of a duplicate candidate
+type DuplicateProduct struct {
+	ID          int64     `json:"id"`
+	SKU         string    `json:"sku,omitempty"`
+	Name        string    `json:"name"`
+	Category    string    `json:"category"`
+	Price       float64   `json:"price"`
+	IsAvailable bool      `json:"is_available"`
+	CartItems   int       `json:"cart_items"` // Cart lines that would move if this product were merged away
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// DuplicateCandidate is a pair of a seller's products that look like the
+// same item. Score blends how close their embeddings are with how alike
+// their names are; it is the name similarity alone when either product has
+// no embedding yet.
+type DuplicateCandidate struct {
+	Product             DuplicateProduct `json:"product"`
+	Duplicate           DuplicateProduct `json:"duplicate"`
+	NameSimilarity      float64          `json:"name_similarity"`
+	EmbeddingSimilarity *float64         `json:"embedding_similarity,omitempty"`
+	Score               float64          `json:"score"`
+}
+
+// ProductMerge is a duplicate folded into the product that survives it
+type ProductMerge struct {
+	ID                int64     `json:"id"`
+	SellerID          int64     `json:"seller_id"`
+	SurvivorID        int64     `json:"survivor_id"`
+	MergedID          int64     `json:"merged_id"`
+	CartItemsMoved    int       `json:"cart_items_moved"`
+	SearchLogsUpdated int       `json:"search_logs_updated"`
+	PromotionsUpdated int       `json:"promotions_updated"`
+	CreatedBy         int64     `json:"created_by"`
+	CreatedAt         time.Time `json:"created_at"`
+}
@@ -0,0 +1,72 @@
+package model
+
+type ImportRowStatus string
+
+const (
+	ImportRowAccepted ImportRowStatus = "accepted"
+	ImportRowRejected ImportRowStatus = "rejected"
+)
+
+// ImportAction is what an accepted row did to the catalog
+type ImportAction string
+
+const (
+	ImportActionCreated   ImportAction = "created"
+	ImportActionUpdated   ImportAction = "updated"
+	ImportActionUnchanged ImportAction = "unchanged"
+)
+
+// Import modes. append always inserts; upsert matches rows to existing
+// products by SKU; replace upserts and then deactivates the seller's products
+// that aren't in the file.
+const (
+	ImportModeAppend  = "append"
+	ImportModeUpsert  = "upsert"
+	ImportModeReplace = "replace"
+)
+
+// ImportRow is the outcome of one spreadsheet row
+type ImportRow struct {
+	Row      int             `json:"row"` // 1-based, as shown in the spreadsheet
+	SKU      string          `json:"sku,omitempty"`
+	Status   ImportRowStatus `json:"status"`
+	Action   ImportAction    `json:"action,omitempty"`
+	Errors   []string        `json:"errors,omitempty"`
+	Warnings []string        `json:"warnings,omitempty"` // Problems that didn't stop the row
+	Product  *Product        `json:"product,omitempty"`  // Dry runs only; applied imports keep it in their batch
+
+	// Images embedded in the row's cells (XLSX only), attached to the product
+	// once it is saved
+	Images     [][]byte `json:"-"`
+	ImageCount int      `json:"images,omitempty"`
+}
+
+// ImportReport describes how a product catalog file was read and what
+// happened to every row. Blank rows, and the import template's example row,
+// are counted as skipped but not listed.
+type ImportReport struct {
+	Format    string            `json:"format"`
+	Sheet     string            `json:"sheet,omitempty"`     // XLSX only
+	Encoding  string            `json:"encoding,omitempty"`  // CSV only
+	Delimiter string            `json:"delimiter,omitempty"` // CSV only
+	HeaderRow int               `json:"header_row,omitempty"`
+	Columns   map[string]string `json:"columns"` // header as written -> product field
+	Ignored   []string          `json:"ignored_columns,omitempty"`
+	Mode      string            `json:"mode,omitempty"`
+	DryRun    bool              `json:"dry_run"`
+	BatchID   int64             `json:"batch_id,omitempty"` // Import batch to roll back; not set for dry runs
+	Accepted  int               `json:"accepted"`
+	Rejected  int               `json:"rejected"`
+	Skipped   int               `json:"skipped"`
+
+	// Filled in once accepted rows have been applied to the catalog
+	Created     int `json:"created"`
+	Updated     int `json:"updated"`
+	Unchanged   int `json:"unchanged"`
+	Deactivated int `json:"deactivated"`
+	ImagesAdded int `json:"images_added"`
+	// Categories added to the seller's tree for categories that matched none
+	CategoriesCreated int `json:"categories_created"`
+
+	Rows []ImportRow `json:"rows"`
+}
@@ -0,0 +1,53 @@
+package model
+
+import "time"
+
+// Fields an enrichment job can suggest
+const (
+	EnrichDescription = "description"
+	EnrichCategory    = "category"
+	EnrichAttributes  = "attributes"
+)
+
+// EnrichFields lists every field an enrichment job can suggest
+var EnrichFields = []string{EnrichDescription, EnrichCategory, EnrichAttributes}
+
+// Product suggestion statuses
+const (
+	SuggestionPending  = "pending"
+	SuggestionApproved = "approved"
+	SuggestionRejected = "rejected"
+)
+
+// ProductContent is the part of a product an enrichment job can rewrite.
+// In a suggestion, fields that weren't asked for are nil.
+type ProductContent struct {
+	Description *string        `json:"description,omitempty"`
+	Category    *string        `json:"category,omitempty"`
+	Attributes  map[string]any `json:"attributes,omitempty"`
+}
+
+// ProductSuggestion is content a language model wrote for a product. It
+// changes nothing until the seller approves it; Original is the product's
+// content when it was written, so a product edited since can be spotted.
+type ProductSuggestion struct {
+	ID            int64          `json:"id"`
+	ProductID     int64          `json:"product_id"`
+	ProductName   string         `json:"product_name"`
+	SellerID      int64          `json:"seller_id"`
+	JobID         *int64         `json:"job_id,omitempty"`
+	Model         string         `json:"model"`
+	Suggested     ProductContent `json:"suggested"`
+	Original      ProductContent `json:"original"`
+	Status        string         `json:"status"`
+	AppliedFields []string       `json:"applied_fields,omitempty"` // Fields an approval copied to the product
+	ReviewedBy    *int64         `json:"reviewed_by,omitempty"`
+	ReviewedAt    *time.Time     `json:"reviewed_at,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+// Content returns the product's current description, category and attributes
+func (p *Product) Content() ProductContent {
+	description, category := p.Description, p.Category
+	return ProductContent{Description: &description, Category: &category, Attributes: p.Attributes}
+}
@@ -0,0 +1,123 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Kinds of promotion
+const (
+	PromotionPercentage = "percentage"  // Value percent off each unit
+	PromotionFixed      = "fixed"       // Value off each unit
+	PromotionBundle     = "bundle"      // Every BuyQty units cost Value together
+	PromotionBuyXGetY   = "buy_x_get_y" // Of every BuyQty + GetQty units, GetQty are free
+)
+
+// PromotionRule is the part of a promotion that prices items. Products carry
+// the rules of the promotions running on them when they are read.
+type PromotionRule struct {
+	ID     int64      `json:"id"`
+	Name   string     `json:"name"`
+	Kind   string     `json:"kind"`
+	Value  float64    `json:"value"`
+	BuyQty int        `json:"buy_qty,omitempty"`
+	GetQty int        `json:"get_qty,omitempty"`
+	EndsAt *time.Time `json:"ends_at,omitempty"`
+}
+
+// Promotion is a discount a seller runs over its whole store, or only the
+// listed products and categories, while it is active and within its window.
+// DaysOfWeek limits it to ISO weekdays (1 is Monday) in Timezone.
+type Promotion struct {
+	PromotionRule
+	SellerID    int64      `json:"seller_id"`
+	ProductIDs  []int64    `json:"product_ids"`
+	CategoryIDs []int64    `json:"category_ids"`
+	DaysOfWeek  []int      `json:"days_of_week"`
+	Timezone    string     `json:"timezone"`
+	StartsAt    *time.Time `json:"starts_at"`
+	IsActive    bool       `json:"is_active"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Discount is what the rule takes off qty units at unitPrice, rounded to
+// cents. Bundles never raise the price.
+func (r PromotionRule) Discount(unitPrice float64, qty int) float64 {
+	var discount float64
+	switch r.Kind {
+	case PromotionPercentage:
+		discount = unitPrice * float64(qty) * math.Min(r.Value, 100) / 100
+	case PromotionFixed:
+		discount = math.Min(r.Value, unitPrice) * float64(qty)
+	case PromotionBundle:
+		if r.BuyQty > 0 {
+			discount = math.Max(unitPrice*float64(r.BuyQty)-r.Value, 0) * float64(qty/r.BuyQty)
+		}
+	case PromotionBuyXGetY:
+		if group := r.BuyQty + r.GetQty; r.GetQty > 0 && group > 0 {
+			discount = unitPrice * float64(r.GetQty*(qty/group))
+		}
+	}
+	return math.Round(discount*100) / 100
+}
+
+// QuotesPrice reports whether the rule's label contains a price
+func (r PromotionRule) QuotesPrice() bool {
+	return r.Kind == PromotionFixed || r.Kind == PromotionBundle
+}
+
+// Label describes the rule the way customers know it, e.g. "Beli 2 gratis 1"
+func (r PromotionRule) Label() string {
+	switch r.Kind {
+	case PromotionPercentage:
+		return "Diskon " + strconv.FormatFloat(r.Value, 'f', -1, 64) + "%"
+	case PromotionFixed:
+		return "Potongan Rp " + strconv.FormatFloat(r.Value, 'f', -1, 64)
+	case PromotionBundle:
+		return fmt.Sprintf("%d seharga Rp %s", r.BuyQty, strconv.FormatFloat(r.Value, 'f', -1, 64))
+	case PromotionBuyXGetY:
+		return fmt.Sprintf("Beli %d gratis %d", r.BuyQty, r.GetQty)
+	}
+	return r.Name
+}
+
+// BestPromotion returns the rule giving the largest discount on qty units at
+// unitPrice, and that discount. Promotions don't stack. It returns nil when
+// no rule gives a discount.
+func BestPromotion(rules []PromotionRule, unitPrice float64, qty int) (*PromotionRule, float64) {
+	var best *PromotionRule
+	var bestDiscount float64
+	for i := range rules {
+		if discount := rules[i].Discount(unitPrice, qty); discount > bestDiscount {
+			best, bestDiscount = &rules[i], discount
+		}
+	}
+	return best, bestDiscount
+}
+
+// EffectivePrice is the price of one unit after the best per-unit rule, or
+// nil when no rule lowers it
+func EffectivePrice(rules []PromotionRule, unitPrice float64) *float64 {
+	if _, discount := BestPromotion(rules, unitPrice, 1); discount > 0 {
+		price := math.Round((unitPrice-discount)*100) / 100
+		return &price
+	}
+	return nil
+}
+
+// PriceChange is one change to the price of a product or variant. OldPrice
+// is nil for the price the item was created with.
+type PriceChange struct {
+	ID          int64     `json:"id"`
+	ProductID   int64     `json:"product_id"`
+	VariantID   *int64    `json:"variant_id,omitempty"` // Nil once the variant is deleted
+	VariantName string    `json:"variant_name,omitempty"`
+	OldPrice    *float64  `json:"old_price"`
+	NewPrice    float64   `json:"new_price"`
+	Reason      string    `json:"reason"`
+	CreatedBy   *int64    `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,99 @@
+package model
+
+import "testing"
+
+func TestPromotionDiscount(t *testing.T) {
+	tests := []struct {
+		name      string
+		rule      PromotionRule
+		unitPrice float64
+		qty       int
+		want      float64
+	}{
+		{"percentage", PromotionRule{Kind: PromotionPercentage, Value: 10}, 25000, 2, 5000},
+		{"percentage rounds to cents", PromotionRule{Kind: PromotionPercentage, Value: 10}, 33333.33, 1, 3333.33},
+		{"percentage over 100 makes it free", PromotionRule{Kind: PromotionPercentage, Value: 150}, 25000, 2, 50000},
+		{"fixed per unit", PromotionRule{Kind: PromotionFixed, Value: 3000}, 25000, 3, 9000},
+		{"fixed above the unit price makes it free", PromotionRule{Kind: PromotionFixed, Value: 30000}, 25000, 2, 50000},
+		{"bundle of exactly buy_qty", PromotionRule{Kind: PromotionBundle, Value: 10000, BuyQty: 3}, 4000, 3, 2000},
+		{"bundle with leftover units", PromotionRule{Kind: PromotionBundle, Value: 10000, BuyQty: 3}, 4000, 7, 4000},
+		{"bundle short of buy_qty", PromotionRule{Kind: PromotionBundle, Value: 10000, BuyQty: 3}, 4000, 2, 0},
+		{"bundle dearer than the units", PromotionRule{Kind: PromotionBundle, Value: 15000, BuyQty: 3}, 4000, 6, 0},
+		{"bundle without buy_qty", PromotionRule{Kind: PromotionBundle, Value: 10000}, 4000, 6, 0},
+		{"buy 2 get 1, one group", PromotionRule{Kind: PromotionBuyXGetY, BuyQty: 2, GetQty: 1}, 5000, 3, 5000},
+		{"buy 2 get 1, partial second group", PromotionRule{Kind: PromotionBuyXGetY, BuyQty: 2, GetQty: 1}, 5000, 5, 5000},
+		{"buy 2 get 1, two groups", PromotionRule{Kind: PromotionBuyXGetY, BuyQty: 2, GetQty: 1}, 5000, 6, 10000},
+		{"buy 2 get 1, short of a group", PromotionRule{Kind: PromotionBuyXGetY, BuyQty: 2, GetQty: 1}, 5000, 2, 0},
+		{"buy 1 get 1", PromotionRule{Kind: PromotionBuyXGetY, BuyQty: 1, GetQty: 1}, 5000, 5, 10000},
+		{"buy x get nothing", PromotionRule{Kind: PromotionBuyXGetY, BuyQty: 2}, 5000, 6, 0},
+		{"unknown kind", PromotionRule{Kind: "mystery", Value: 50}, 5000, 2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Discount(tt.unitPrice, tt.qty); got != tt.want {
+				t.Errorf("Discount(%v, %d) = %v, want %v", tt.unitPrice, tt.qty, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBestPromotion(t *testing.T) {
+	rules := []PromotionRule{
+		{ID: 1, Kind: PromotionPercentage, Value: 10},
+		{ID: 2, Kind: PromotionFixed, Value: 3000},
+		{ID: 3, Kind: PromotionBuyXGetY, BuyQty: 2, GetQty: 1},
+		{ID: 4, Kind: PromotionBundle, Value: 35000, BuyQty: 2},
+	}
+	tests := []struct {
+		name         string
+		rules        []PromotionRule
+		unitPrice    float64
+		qty          int
+		wantID       int64 // 0 for no rule
+		wantDiscount float64
+	}{
+		// 10% = 2500, 3000 off, no free unit, bundle needs 2
+		{"one unit takes the fixed discount", rules, 25000, 1, 2, 3000},
+		// 10% = 5000, 6000 off, no free unit, bundle saves 15000
+		{"two units take the bundle", rules, 25000, 2, 4, 15000},
+		// 10% = 7500, 9000 off, one free = 25000, one bundle = 15000
+		{"three units take the free one", rules, 25000, 3, 3, 25000},
+		// On a cheap item the percentage beats the fixed amount capped at the price
+		{"percentage on a cheap item", []PromotionRule{
+			{ID: 1, Kind: PromotionPercentage, Value: 50},
+			{ID: 2, Kind: PromotionFixed, Value: 1000},
+		}, 5000, 1, 1, 2500},
+		{"ties go to the first rule", []PromotionRule{
+			{ID: 1, Kind: PromotionFixed, Value: 2500},
+			{ID: 2, Kind: PromotionPercentage, Value: 10},
+		}, 25000, 1, 1, 2500},
+		{"no rule applies", []PromotionRule{{ID: 4, Kind: PromotionBundle, Value: 35000, BuyQty: 2}}, 25000, 1, 0, 0},
+		{"no rules", nil, 25000, 1, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			best, discount := BestPromotion(tt.rules, tt.unitPrice, tt.qty)
+			var gotID int64
+			if best != nil {
+				gotID = best.ID
+			}
+			if gotID != tt.wantID || discount != tt.wantDiscount {
+				t.Errorf("BestPromotion = rule %d, %v off; want rule %d, %v off", gotID, discount, tt.wantID, tt.wantDiscount)
+			}
+		})
+	}
+}
+
+func TestEffectivePrice(t *testing.T) {
+	if got := EffectivePrice([]PromotionRule{{Kind: PromotionPercentage, Value: 20}}, 25000); got == nil || *got != 20000 {
+		t.Errorf("EffectivePrice with 20%% off = %v, want 20000", got)
+	}
+	// Bundles and buy-x-get-y don't lower the price of a single unit
+	rules := []PromotionRule{
+		{Kind: PromotionBundle, Value: 35000, BuyQty: 2},
+		{Kind: PromotionBuyXGetY, BuyQty: 1, GetQty: 1},
+	}
+	if got := EffectivePrice(rules, 25000); got != nil {
+		t.Errorf("EffectivePrice with multi-unit rules = %v, want nil", *got)
+	}
+}
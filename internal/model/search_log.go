@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+type SearchSource string
+
+const (
+	SearchSourceSearch SearchSource = "search"
+	SearchSourceChat   SearchSource = "chat"
+)
+
+// SearchLog records one product search or chat retrieval
+type SearchLog struct {
+	ID            int64        `json:"id"`
+	SellerID      *int64       `json:"seller_id,omitempty"`
+	UserID        int64        `json:"user_id"`
+	Source        SearchSource `json:"source"`
+	Query         string       `json:"query"`
+	Mode          string       `json:"mode"`
+	ResultIDs     []int64      `json:"result_ids"`
+	TopSimilarity *float64     `json:"top_similarity,omitempty"`
+	LatencyMs     int          `json:"latency_ms"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+// QueryStat aggregates the logged searches for one normalized query
+type QueryStat struct {
+	Query            string    `json:"query"`
+	Searches         int       `json:"searches"`
+	AvgResults       float64   `json:"avg_results"`
+	ZeroResults      int       `json:"zero_results"`
+	AvgTopSimilarity *float64  `json:"avg_top_similarity,omitempty"`
+	AvgLatencyMs     float64   `json:"avg_latency_ms"`
+	LastSearchedAt   time.Time `json:"last_searched_at"`
+}
+
+// UnretrievedProduct is a product that no logged search returned
+type UnretrievedProduct struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Category  string    `json:"category"`
+	Price     float64   `json:"price"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/divinecoid/oneagent/internal/db"
+	"github.com/divinecoid/oneagent/internal/model"
+)
+
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// maxScheduleWindows caps the windows of one product or category
+const maxScheduleWindows = 20
+
+// SchedulesJSON returns a scalar subquery aggregating the schedule that
+// decides when the product whose ID is productID is on the menu into a JSON
+// array of model.AvailabilityWindow
+func SchedulesJSON(productID string) string {
+	return `(SELECT COALESCE(jsonb_agg(jsonb_build_object(
+		'id', s.id, 'days_of_week', s.days_of_week,
+		'start', to_char(s.start_time, 'HH24:MI'), 'end', to_char(s.end_time, 'HH24:MI')
+	) ORDER BY s.start_time, s.id), '[]') FROM product_schedules(` + productID + `) s)`
+}
+
+// ValidateSchedule checks availability windows before they are saved. Times
+// are normalised to "15:04".
+func ValidateSchedule(windows []model.AvailabilityWindow) error {
+	if len(windows) > maxScheduleWindows {
+		return fmt.Errorf("%w: at most %d windows", ErrInvalidSchedule, maxScheduleWindows)
+	}
+	for i := range windows {
+		w := &windows[i]
+		start, err := time.Parse("15:04", w.Start)
+		if err != nil {
+			return fmt.Errorf("%w: start %q must be HH:MM", ErrInvalidSchedule, w.Start)
+		}
+		end, err := time.Parse("15:04", w.End)
+		if err != nil {
+			return fmt.Errorf("%w: end %q must be HH:MM", ErrInvalidSchedule, w.End)
+		}
+		if start.Equal(end) {
+			return fmt.Errorf("%w: start and end are both %s", ErrInvalidSchedule, w.Start)
+		}
+		w.Start, w.End = start.Format("15:04"), end.Format("15:04")
+
+		seen := map[int]bool{}
+		for _, day := range w.DaysOfWeek {
+			if day < 1 || day > 7 {
+				return fmt.Errorf("%w: days_of_week are 1 (Monday) to 7 (Sunday)", ErrInvalidSchedule)
+			}
+			if seen[day] {
+				return fmt.Errorf("%w: day %d is listed more than once", ErrInvalidSchedule, day)
+			}
+			seen[day] = true
+		}
+		if w.DaysOfWeek == nil {
+			w.DaysOfWeek = []int{}
+		}
+	}
+	return nil
+}
+
+type AvailabilityService struct{}
+
+func NewAvailabilityService() *AvailabilityService {
+	return &AvailabilityService{}
+}
+
+// SellerLocation returns the time zone of a seller's configuration, falling
+// back to Asia/Jakarta when it has none
+func (s *AvailabilityService) SellerLocation(ctx context.Context, sellerID int64) (*time.Location, error) {
+	var name string
+	if err := db.Pool.QueryRow(ctx, `SELECT seller_timezone($1)`, sellerID).Scan(&name); err != nil {
+		return nil, fmt.Errorf("failed to get seller time zone: %v", err)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load time zone %q: %v", name, err)
+	}
+	return loc, nil
+}
+
+// ProductSchedule returns the windows set on a product itself; categories'
+// windows are not included
+func (s *AvailabilityService) ProductSchedule(ctx context.Context, productID int64) ([]model.AvailabilityWindow, error) {
+	return listSchedule(ctx, "product_id", productID)
+}
+
+// CategorySchedule returns the windows set on a category
+func (s *AvailabilityService) CategorySchedule(ctx context.Context, categoryID int64) ([]model.AvailabilityWindow, error) {
+	return listSchedule(ctx, "category_id", categoryID)
+}
+
+// SetProductSchedule replaces the windows of a product. An empty schedule
+// makes the product follow its category's again.
+func (s *AvailabilityService) SetProductSchedule(ctx context.Context, sellerID, productID int64, windows []model.AvailabilityWindow) error {
+	return replaceSchedule(ctx, sellerID, "product_id", productID, windows)
+}
+
+// SetCategorySchedule replaces the windows of a category, which apply to its
+// products and subcategories that have none of their own
+func (s *AvailabilityService) SetCategorySchedule(ctx context.Context, sellerID, categoryID int64, windows []model.AvailabilityWindow) error {
+	return replaceSchedule(ctx, sellerID, "category_id", categoryID, windows)
+}
+
+// listSchedule reads the windows whose column (product_id or category_id) is id
+func listSchedule(ctx context.Context, column string, id int64) ([]model.AvailabilityWindow, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT id, days_of_week, to_char(start_time, 'HH24:MI'), to_char(end_time, 'HH24:MI')
+		FROM availability_schedules
+		WHERE `+column+` = $1
+		ORDER BY start_time, id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule: %v", err)
+	}
+	defer rows.Close()
+
+	windows := []model.AvailabilityWindow{}
+	for rows.Next() {
+		var w model.AvailabilityWindow
+		if err := rows.Scan(&w.ID, &w.DaysOfWeek, &w.Start, &w.End); err != nil {
+			return nil, fmt.Errorf("failed to scan schedule: %v", err)
+		}
+		windows = append(windows, w)
+	}
+	return windows, rows.Err()
+}
+
+func replaceSchedule(ctx context.Context, sellerID int64, column string, id int64, windows []model.AvailabilityWindow) error {
+	if err := ValidateSchedule(windows); err != nil {
+		return err
+	}
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM availability_schedules WHERE `+column+` = $1`, id); err != nil {
+		return fmt.Errorf("failed to clear schedule: %v", err)
+	}
+	for i := range windows {
+		w := &windows[i]
+		err := tx.QueryRow(ctx, `
+			INSERT INTO availability_schedules (seller_id, `+column+`, days_of_week, start_time, end_time)
+			VALUES ($1, $2, $3, $4::TIME, $5::TIME)
+			RETURNING id`, sellerID, id, w.DaysOfWeek, w.Start, w.End,
+		).Scan(&w.ID)
+		if err != nil {
+			return fmt.Errorf("failed to save schedule: %v", err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit schedule: %v", err)
+	}
+	return nil
+}
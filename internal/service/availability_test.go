@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TestScheduleCoversMatchesGo checks that the SQL schedule_covers, which
+// search filters on, agrees with AvailabilityWindow.Covers around midnight
+// and across seller time zones. It needs a migrated database in
+// DATABASE_URL and is skipped without one.
+func TestScheduleCoversMatchesGo(t *testing.T) {
+	url := os.Getenv("DATABASE_URL")
+	if url == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	windows := []model.AvailabilityWindow{
+		{DaysOfWeek: []int{1, 2, 3, 4, 5}, Start: "06:00", End: "10:00"},
+		{DaysOfWeek: []int{5, 6}, Start: "22:00", End: "02:00"},
+		{DaysOfWeek: []int{7}, Start: "23:00", End: "01:00"},
+		{DaysOfWeek: []int{}, Start: "20:00", End: "04:00"},
+	}
+	zones := []string{"UTC", "Asia/Jakarta", "Asia/Jayapura"}
+	// Every half hour of a week, starting on Sunday so Monday's overnight
+	// windows have a day before them
+	start := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, zone := range zones {
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			t.Skip(err)
+		}
+		for _, w := range windows {
+			for at := start; at.Before(start.AddDate(0, 0, 8)); at = at.Add(30 * time.Minute) {
+				var covers bool
+				err := pool.QueryRow(ctx, `
+					SELECT schedule_covers($1::SMALLINT[], $2::TIME, $3::TIME, $4::TIMESTAMPTZ AT TIME ZONE $5)`,
+					w.DaysOfWeek, w.Start, w.End, at, zone).Scan(&covers)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := w.Covers(at.In(loc)); covers != want {
+					t.Errorf("%s at %s in %s: SQL says %v, Go says %v", w, at.Format(time.RFC3339), zone, covers, want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore keeps uploaded files such as product images. Keys are slash
+// separated relative paths, e.g. "products/12/ab34.jpg".
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// URL is where clients can fetch the blob. It may expire when signed.
+	URL(key string) string
+}
+
+// publicBlobPrefix is the key prefix of the blobs that may be served to
+// anyone with their URL: product images. Other blobs, such as the catalog
+// files kept for imports, are only read through handlers that check the
+// caller.
+const publicBlobPrefix = "products/"
+
+// IsPublicBlob reports whether a blob may be served to anyone with its URL
+func IsPublicBlob(key string) bool {
+	return strings.HasPrefix(key, publicBlobPrefix)
+}
+
+var (
+	blobStore     BlobStore
+	blobStoreOnce sync.Once
+)
+
+// Blobs returns the blob store configured by the environment:
+//   - BLOB_LOCAL_DIR: where files are kept (default "data/blobs")
+//   - BLOB_PUBLIC_URL: URL prefix the files are served under (default "/api/v1/files")
+//   - BLOB_SIGNING_KEY: when set, URLs are signed and expire after BLOB_URL_TTL
+//     (default 24h); otherwise they are public
+func Blobs() BlobStore {
+	blobStoreOnce.Do(func() {
+		store := &LocalBlobStore{
+			Dir:        envOr("BLOB_LOCAL_DIR", filepath.Join("data", "blobs")),
+			BaseURL:    strings.TrimRight(envOr("BLOB_PUBLIC_URL", "/api/v1/files"), "/"),
+			SigningKey: []byte(os.Getenv("BLOB_SIGNING_KEY")),
+			URLTTL:     24 * time.Hour,
+		}
+		if ttl, err := time.ParseDuration(os.Getenv("BLOB_URL_TTL")); err == nil && ttl > 0 {
+			store.URLTTL = ttl
+		}
+		blobStore = store
+	})
+	return blobStore
+}
+
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// LocalBlobStore keeps blobs as files under Dir and serves them under BaseURL
+type LocalBlobStore struct {
+	Dir        string
+	BaseURL    string
+	SigningKey []byte // URLs are public when empty
+	URLTTL     time.Duration
+}
+
+// path maps a key to its file, rejecting keys that would escape Dir
+func (s *LocalBlobStore) path(key string) (string, error) {
+	clean := path.Clean("/" + key)[1:]
+	if clean == "" || clean != key {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(clean)), nil
+}
+
+// Put writes the blob through a temporary file, so readers never see a
+// partial file
+func (s *LocalBlobStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	file, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return fmt.Errorf("failed to create blob directory: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create blob: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write blob: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write blob: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return fmt.Errorf("failed to store blob: %v", err)
+	}
+	return nil
+}
+
+func (s *LocalBlobStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	file, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blob: %v", err)
+	}
+	return f, nil
+}
+
+// Delete removes a blob; deleting a missing blob is not an error
+func (s *LocalBlobStore) Delete(ctx context.Context, key string) error {
+	file, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete blob: %v", err)
+	}
+	return nil
+}
+
+func (s *LocalBlobStore) URL(key string) string {
+	u := s.BaseURL + "/" + key
+	if len(s.SigningKey) == 0 {
+		return u
+	}
+	expires := strconv.FormatInt(time.Now().Add(s.URLTTL).Unix(), 10)
+	return u + "?" + url.Values{"expires": {expires}, "signature": {s.sign(key, expires)}}.Encode()
+}
+
+func (s *LocalBlobStore) sign(key, expires string) string {
+	mac := hmac.New(sha256.New, s.SigningKey)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the expires and signature parameters of a URL made by URL.
+// Every URL is valid when the store isn't signing.
+func (s *LocalBlobStore) Verify(key, expires, signature string) bool {
+	if len(s.SigningKey) == 0 {
+		return true
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(s.sign(key, expires)))
+}
@@ -0,0 +1,383 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/divinecoid/oneagent/internal/db"
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrCategoryNotFound    = errors.New("category not found")
+	ErrCategoryHasChildren = errors.New("category has subcategories; move or delete them first")
+	ErrCategoryCycle       = errors.New("a category can't be moved below itself")
+	ErrDuplicateCategory   = errors.New("category slug already in use")
+)
+
+// categoryPathSeparator splits "Minuman > Kopi" into tree levels in free-text
+// categories
+const categoryPathSeparator = ">"
+
+const categoryColumns = `c.id, c.seller_id, c.parent_id, c.name, c.slug, c.aliases, c.position, c.created_at, c.updated_at`
+
+func scanCategory(row pgx.Row, extra ...any) (*model.Category, error) {
+	c := &model.Category{}
+	dest := append([]any{&c.ID, &c.SellerID, &c.ParentID, &c.Name, &c.Slug, &c.Aliases, &c.Position,
+		&c.CreatedAt, &c.UpdatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Slugify turns a category name into a URL-safe slug: lowercase ASCII
+// letters and digits separated by dashes
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	if len(b.String()) > 100 {
+		return strings.TrimRight(b.String()[:100], "-")
+	}
+	return b.String()
+}
+
+// categoryKey is how names and aliases are compared
+func categoryKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// normalizeAliases lowercases aliases and drops blanks and duplicates
+func normalizeAliases(aliases []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, alias := range aliases {
+		key := categoryKey(alias)
+		if key != "" && !seen[key] {
+			seen[key] = true
+			out = append(out, key)
+		}
+	}
+	return out
+}
+
+type CategoryService struct{}
+
+func NewCategoryService() *CategoryService {
+	return &CategoryService{}
+}
+
+// ListCategories returns a seller's categories in tree order: siblings by
+// position, then by creation
+func (s *CategoryService) ListCategories(ctx context.Context, sellerID int64) ([]*model.Category, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+categoryColumns+`,
+			(SELECT COUNT(*) FROM products p WHERE p.category_id = c.id AND p.deleted_at IS NULL)
+		FROM categories c
+		WHERE c.seller_id = $1
+		ORDER BY c.position, c.id`, sellerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list categories: %v", err)
+	}
+	defer rows.Close()
+
+	categories := []*model.Category{}
+	for rows.Next() {
+		var count int
+		c, err := scanCategory(rows, &count)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan category: %v", err)
+		}
+		c.ProductCount = count
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+// CategoryTree returns a seller's top-level categories with their children
+// nested below them
+func (s *CategoryService) CategoryTree(ctx context.Context, sellerID int64) ([]*model.Category, error) {
+	categories, err := s.ListCategories(ctx, sellerID)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]*model.Category, len(categories))
+	for _, c := range categories {
+		byID[c.ID] = c
+	}
+	roots := []*model.Category{}
+	for _, c := range categories {
+		if parent := byID[derefID(c.ParentID)]; parent != nil {
+			parent.Children = append(parent.Children, c)
+		} else {
+			roots = append(roots, c)
+		}
+	}
+	return roots, nil
+}
+
+func derefID(id *int64) int64 {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
+
+func (s *CategoryService) GetCategory(ctx context.Context, id int64) (*model.Category, error) {
+	c, err := scanCategory(db.Pool.QueryRow(ctx, `SELECT `+categoryColumns+` FROM categories c WHERE c.id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrCategoryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get category: %v", err)
+	}
+	return c, nil
+}
+
+// CreateCategory adds a category to its seller's tree. The slug is derived
+// from the name when empty.
+func (s *CategoryService) CreateCategory(ctx context.Context, c *model.Category) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := insertCategory(ctx, tx, c); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit category: %v", err)
+	}
+	return nil
+}
+
+func insertCategory(ctx context.Context, tx pgx.Tx, c *model.Category) error {
+	if err := checkCategoryParent(ctx, tx, c); err != nil {
+		return err
+	}
+	c.Aliases = normalizeAliases(c.Aliases)
+	if c.Slug == "" {
+		slug, err := uniqueCategorySlug(ctx, tx, c.SellerID, c.Name)
+		if err != nil {
+			return err
+		}
+		c.Slug = slug
+	}
+
+	err := tx.QueryRow(ctx, `
+		INSERT INTO categories (seller_id, parent_id, name, slug, aliases, position)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at`,
+		c.SellerID, c.ParentID, c.Name, c.Slug, c.Aliases, c.Position,
+	).Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt)
+	if isUniqueViolation(err) {
+		return ErrDuplicateCategory
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create category: %v", err)
+	}
+	return nil
+}
+
+// UpdateCategory saves a category's name, slug, aliases, parent and position.
+// A rename is copied to the category's products, whose embeddings are then
+// dropped so the next stale-embedding run re-embeds them.
+func (s *CategoryService) UpdateCategory(ctx context.Context, c *model.Category) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := checkCategoryParent(ctx, tx, c); err != nil {
+		return err
+	}
+	c.Aliases = normalizeAliases(c.Aliases)
+	err = tx.QueryRow(ctx, `
+		UPDATE categories SET name = $2, slug = $3, aliases = $4, parent_id = $5, position = $6
+		WHERE id = $1
+		RETURNING updated_at`,
+		c.ID, c.Name, c.Slug, c.Aliases, c.ParentID, c.Position,
+	).Scan(&c.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrCategoryNotFound
+	}
+	if isUniqueViolation(err) {
+		return ErrDuplicateCategory
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update category: %v", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE products SET category = $2
+		WHERE category_id = $1 AND category IS DISTINCT FROM $2`, c.ID, c.Name)
+	if err != nil {
+		return fmt.Errorf("failed to rename category of products: %v", err)
+	}
+	_, err = tx.Exec(ctx, `
+		DELETE FROM product_embeddings e
+		USING products p
+		WHERE e.product_id = p.id AND p.category_id = $1 AND e.content_hash <> p.content_hash`, c.ID)
+	if err != nil {
+		return fmt.Errorf("failed to invalidate product embeddings: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit category: %v", err)
+	}
+	return nil
+}
+
+// checkCategoryParent verifies that a category's parent belongs to the same
+// seller and isn't the category itself or one of its descendants
+func checkCategoryParent(ctx context.Context, tx pgx.Tx, c *model.Category) error {
+	if c.ParentID == nil {
+		return nil
+	}
+	var sellerID int64
+	var below bool
+	err := tx.QueryRow(ctx, `
+		SELECT seller_id, $2 <> 0 AND id IN (SELECT category_subtree($2))
+		FROM categories WHERE id = $1`, *c.ParentID, c.ID,
+	).Scan(&sellerID, &below)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && sellerID != c.SellerID) {
+		return fmt.Errorf("parent: %w", ErrCategoryNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check parent category: %v", err)
+	}
+	if below {
+		return ErrCategoryCycle
+	}
+	return nil
+}
+
+// DeleteCategory removes a category without subcategories. Its products keep
+// their category text but leave the tree.
+func (s *CategoryService) DeleteCategory(ctx context.Context, id int64) error {
+	result, err := db.Pool.Exec(ctx, `DELETE FROM categories WHERE id = $1`, id)
+	if isForeignKeyViolation(err) {
+		return ErrCategoryHasChildren
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete category: %v", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
+
+// uniqueCategorySlug returns the slug of name, numbered if the seller
+// already has it
+func uniqueCategorySlug(ctx context.Context, tx pgx.Tx, sellerID int64, name string) (string, error) {
+	base := Slugify(name)
+	if base == "" {
+		base = "category"
+	}
+	slug := base
+	for n := 2; ; n++ {
+		var taken bool
+		err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM categories WHERE seller_id = $1 AND slug = $2)`,
+			sellerID, slug).Scan(&taken)
+		if err != nil {
+			return "", fmt.Errorf("failed to check category slug: %v", err)
+		}
+		if !taken {
+			return slug, nil
+		}
+		slug = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// resolveCategory maps free text onto a seller's tree. Each level of a
+// "Parent > Child" path matches a category by name, slug or alias, ignoring
+// case; the first level may match anywhere in the tree, later levels only
+// below the previous one. Levels that match nothing are created. It returns
+// the deepest category and how many categories were created.
+func resolveCategory(ctx context.Context, tx pgx.Tx, sellerID int64, text string) (*model.Category, int, error) {
+	var current *model.Category
+	created := 0
+	for i, part := range strings.Split(text, categoryPathSeparator) {
+		name := strings.Join(strings.Fields(part), " ")
+		if name == "" {
+			continue
+		}
+		var parentID *int64
+		if current != nil {
+			parentID = &current.ID
+		}
+		found, err := scanCategory(tx.QueryRow(ctx, `
+			SELECT `+categoryColumns+`
+			FROM categories c
+			WHERE c.seller_id = $1
+			AND (($2::BIGINT IS NULL AND $5::BOOLEAN) OR c.parent_id = $2)
+			AND (lower(c.name) = $3 OR c.slug = $4 OR $3 = ANY(c.aliases))
+			ORDER BY c.parent_id NULLS FIRST, lower(c.name) = $3 DESC, c.position, c.id
+			LIMIT 1`, sellerID, parentID, categoryKey(name), Slugify(name), i == 0))
+		if err == nil {
+			current = found
+			continue
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, 0, fmt.Errorf("failed to look up category %q: %v", name, err)
+		}
+
+		if len([]rune(name)) > 100 {
+			return nil, 0, fmt.Errorf("category %q is longer than 100 characters", name)
+		}
+		c := &model.Category{SellerID: sellerID, ParentID: parentID, Name: name}
+		if err := insertCategory(ctx, tx, c); err != nil {
+			return nil, 0, err
+		}
+		current = c
+		created++
+	}
+	return current, created, nil
+}
+
+// assignCategory links a product into its seller's tree. An explicit
+// CategoryID wins and sets the category text to its name; otherwise the
+// free-text category is resolved with resolveCategory. It returns how many
+// categories were created.
+func assignCategory(ctx context.Context, tx pgx.Tx, p *model.Product) (int, error) {
+	if p.CategoryID != nil {
+		err := tx.QueryRow(ctx, `SELECT name FROM categories WHERE id = $1 AND seller_id = $2`,
+			*p.CategoryID, p.SellerID).Scan(&p.Category)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrCategoryNotFound
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to get category: %v", err)
+		}
+		return 0, nil
+	}
+	if strings.TrimSpace(p.Category) == "" || p.SellerID == 0 {
+		p.Category = strings.TrimSpace(p.Category)
+		return 0, nil
+	}
+	c, created, err := resolveCategory(ctx, tx, p.SellerID, p.Category)
+	if err != nil {
+		return 0, err
+	}
+	if c != nil {
+		p.CategoryID, p.Category = &c.ID, c.Name
+	}
+	return created, nil
+}
@@ -152,6 +152,30 @@ func (s *ConfigService) CreateConfiguration(ctx context.Context, config *model.U
 	if config.OpenAIEmbeddingModel == "" {
 		config.OpenAIEmbeddingModel = "text-embedding-3-small"
 	}
+	if config.SearchMode == "" {
+		config.SearchMode = "hybrid"
+	}
+	if config.Reranker == "" {
+		config.Reranker = "lexical"
+	}
+	if config.RerankCandidates == 0 {
+		config.RerankCandidates = 20
+	}
+	if config.TopK == 0 {
+		config.TopK = 5
+	}
+	if config.FallbackMode == "" {
+		config.FallbackMode = "broader"
+	}
+	if config.FallbackTopK == 0 {
+		config.FallbackTopK = 3
+	}
+	if config.MaxContextChars == 0 {
+		config.MaxContextChars = 6000
+	}
+	if config.Timezone == "" {
+		config.Timezone = "Asia/Jakarta"
+	}
 	now := time.Now()
 	config.CreatedAt = now
 	config.UpdatedAt = now
@@ -160,9 +184,13 @@ func (s *ConfigService) CreateConfiguration(ctx context.Context, config *model.U
 			user_id, name, openai_api_key, whatsapp_token, whatsapp_number,
 			basic_prompt, max_chat_reply_count, max_chat_reply_chars,
 			openai_api_key_expires, whatsapp_token_expires,
-			openai_model, openai_embedding_model,
+			openai_model, openai_embedding_model, search_mode,
+			reranker, rerank_candidates,
+			similarity_threshold, top_k, fallback_mode, fallback_top_k,
+			max_context_chars, include_prices, timezone,
 			created_at, updated_at, created_by, updated_by
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19,
+			$20, $21, $22, $23, $24, $25, $26)
 		RETURNING id`
 	err := db.DB.QueryRow(
 		ctx,
@@ -170,7 +198,10 @@ func (s *ConfigService) CreateConfiguration(ctx context.Context, config *model.U
 		config.UserID, config.Name, config.OpenAIAPIKey, config.WhatsappToken, config.WhatsappNumber,
 		config.BasicPrompt, config.MaxChatReplyCount, config.MaxChatReplyChars,
 		config.OpenAIAPIKeyExpires, config.WhatsappTokenExpires,
-		config.OpenAIModel, config.OpenAIEmbeddingModel,
+		config.OpenAIModel, config.OpenAIEmbeddingModel, config.SearchMode,
+		config.Reranker, config.RerankCandidates,
+		config.SimilarityThreshold, config.TopK, config.FallbackMode, config.FallbackTopK,
+		config.MaxContextChars, config.IncludePrices, config.Timezone,
 		config.CreatedAt, config.UpdatedAt, config.CreatedBy, config.UpdatedBy,
 	).Scan(&config.ID)
 	if err != nil {
@@ -186,7 +217,10 @@ func (s *ConfigService) GetConfiguration(ctx context.Context, id int64) (*model.
 		SELECT id, user_id, name, openai_api_key, whatsapp_token, whatsapp_number,
 			   basic_prompt, max_chat_reply_count, max_chat_reply_chars,
 			   openai_api_key_expires, whatsapp_token_expires,
-			   openai_model, openai_embedding_model,
+			   openai_model, openai_embedding_model, search_mode,
+			   reranker, rerank_candidates,
+			   similarity_threshold, top_k, fallback_mode, fallback_top_k,
+			   max_context_chars, include_prices, timezone,
 			   created_at, updated_at, created_by, updated_by
 		FROM user_configurations
 		WHERE id = $1`
@@ -194,7 +228,10 @@ func (s *ConfigService) GetConfiguration(ctx context.Context, id int64) (*model.
 		&config.ID, &config.UserID, &config.Name, &config.OpenAIAPIKey, &config.WhatsappToken, &config.WhatsappNumber,
 		&config.BasicPrompt, &config.MaxChatReplyCount, &config.MaxChatReplyChars,
 		&config.OpenAIAPIKeyExpires, &config.WhatsappTokenExpires,
-		&config.OpenAIModel, &config.OpenAIEmbeddingModel,
+		&config.OpenAIModel, &config.OpenAIEmbeddingModel, &config.SearchMode,
+		&config.Reranker, &config.RerankCandidates,
+		&config.SimilarityThreshold, &config.TopK, &config.FallbackMode, &config.FallbackTopK,
+		&config.MaxContextChars, &config.IncludePrices, &config.Timezone,
 		&config.CreatedAt, &config.UpdatedAt, &config.CreatedBy, &config.UpdatedBy,
 	)
 	if err != nil {
@@ -233,22 +270,52 @@ func (s *ConfigService) UpdateConfiguration(ctx context.Context, config *model.U
 		}
 		config.WhatsappToken = encrypted
 	}
+	if config.SearchMode == "" {
+		config.SearchMode = "hybrid"
+	}
+	if config.Reranker == "" {
+		config.Reranker = "lexical"
+	}
+	if config.RerankCandidates == 0 {
+		config.RerankCandidates = 20
+	}
+	if config.TopK == 0 {
+		config.TopK = 5
+	}
+	if config.FallbackMode == "" {
+		config.FallbackMode = "broader"
+	}
+	if config.FallbackTopK == 0 {
+		config.FallbackTopK = 3
+	}
+	if config.MaxContextChars == 0 {
+		config.MaxContextChars = 6000
+	}
+	if config.Timezone == "" {
+		config.Timezone = "Asia/Jakarta"
+	}
 	config.UpdatedAt = time.Now()
 	query := `
 		UPDATE user_configurations SET
 			name = $1, openai_api_key = $2, whatsapp_token = $3, whatsapp_number = $4,
 			basic_prompt = $5, max_chat_reply_count = $6, max_chat_reply_chars = $7,
 			openai_api_key_expires = $8, whatsapp_token_expires = $9,
-			openai_model = $10, openai_embedding_model = $11,
-			updated_at = $12, updated_by = $13
-		WHERE id = $14`
+			openai_model = $10, openai_embedding_model = $11, search_mode = $12,
+			reranker = $13, rerank_candidates = $14,
+			similarity_threshold = $15, top_k = $16, fallback_mode = $17, fallback_top_k = $18,
+			max_context_chars = $19, include_prices = $20, timezone = $21,
+			updated_at = $22, updated_by = $23
+		WHERE id = $24`
 	_, err := db.DB.Exec(
 		ctx,
 		query,
 		config.Name, config.OpenAIAPIKey, config.WhatsappToken, config.WhatsappNumber,
 		config.BasicPrompt, config.MaxChatReplyCount, config.MaxChatReplyChars,
 		config.OpenAIAPIKeyExpires, config.WhatsappTokenExpires,
-		config.OpenAIModel, config.OpenAIEmbeddingModel,
+		config.OpenAIModel, config.OpenAIEmbeddingModel, config.SearchMode,
+		config.Reranker, config.RerankCandidates,
+		config.SimilarityThreshold, config.TopK, config.FallbackMode, config.FallbackTopK,
+		config.MaxContextChars, config.IncludePrices, config.Timezone,
 		config.UpdatedAt, config.UpdatedBy, config.ID,
 	)
 	if err != nil {
@@ -291,7 +358,10 @@ func (s *ConfigService) GetConfigurationByUser(ctx context.Context, userID int64
 		SELECT id, user_id, name, openai_api_key, whatsapp_token, whatsapp_number,
 			   basic_prompt, max_chat_reply_count, max_chat_reply_chars,
 			   openai_api_key_expires, whatsapp_token_expires,
-			   openai_model, openai_embedding_model,
+			   openai_model, openai_embedding_model, search_mode,
+			   reranker, rerank_candidates,
+			   similarity_threshold, top_k, fallback_mode, fallback_top_k,
+			   max_context_chars, include_prices, timezone,
 			   created_at, updated_at, created_by, updated_by
 		FROM user_configurations
 		WHERE user_id = $1
@@ -301,7 +371,10 @@ func (s *ConfigService) GetConfigurationByUser(ctx context.Context, userID int64
 		&config.ID, &config.UserID, &config.Name, &config.OpenAIAPIKey, &config.WhatsappToken, &config.WhatsappNumber,
 		&config.BasicPrompt, &config.MaxChatReplyCount, &config.MaxChatReplyChars,
 		&config.OpenAIAPIKeyExpires, &config.WhatsappTokenExpires,
-		&config.OpenAIModel, &config.OpenAIEmbeddingModel,
+		&config.OpenAIModel, &config.OpenAIEmbeddingModel, &config.SearchMode,
+		&config.Reranker, &config.RerankCandidates,
+		&config.SimilarityThreshold, &config.TopK, &config.FallbackMode, &config.FallbackTopK,
+		&config.MaxContextChars, &config.IncludePrices, &config.Timezone,
 		&config.CreatedAt, &config.UpdatedAt, &config.CreatedBy, &config.UpdatedBy,
 	)
 	if err != nil {
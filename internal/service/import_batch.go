@@ -0,0 +1,506 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/divinecoid/oneagent/internal/db"
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrImportBatchNotFound = errors.New("import batch not found")
+	ErrImportRolledBack    = errors.New("import batch is already rolled back")
+	ErrImportConflict      = errors.New("products changed since the import")
+)
+
+// embeddingSnapshot is a stored vector of a product, kept so a rollback can
+// put back the vectors an import dropped without embedding the product again
+type embeddingSnapshot struct {
+	Model       string `json:"model"`
+	Dimensions  int    `json:"dimensions"`
+	Embedding   string `json:"embedding"` // pgvector text form
+	ContentHash string `json:"content_hash"`
+}
+
+// productEmbeddings reads every stored vector of a product
+func productEmbeddings(ctx context.Context, tx pgx.Tx, productID int64) ([]embeddingSnapshot, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT model, dimensions, embedding::TEXT, content_hash
+		FROM product_embeddings WHERE product_id = $1`, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read product embeddings: %v", err)
+	}
+	defer rows.Close()
+
+	embeddings := []embeddingSnapshot{}
+	for rows.Next() {
+		var e embeddingSnapshot
+		if err := rows.Scan(&e.Model, &e.Dimensions, &e.Embedding, &e.ContentHash); err != nil {
+			return nil, fmt.Errorf("failed to scan product embedding: %v", err)
+		}
+		embeddings = append(embeddings, e)
+	}
+	return embeddings, rows.Err()
+}
+
+// RecordBatch makes the import an import batch: the source file is kept
+// and every product the import changes is snapshotted, so it can be rolled
+// back. The batch row is written in the import's transaction and the file is
+// stored by Commit, so neither exists unless the import is committed.
+// batch.ID is set.
+func (imp *ProductImport) RecordBatch(ctx context.Context, batch *model.ImportBatch, source []byte) error {
+	// Keyed by content like images, so importing a file again stores nothing
+	// new, and a file is never deleted from under an earlier batch
+	sum := sha256.Sum256(source)
+	batch.SourceKey = fmt.Sprintf("imports/%d/%s.%s", imp.sellerID, hex.EncodeToString(sum[:16]), batch.Format)
+	batch.SourceSize = len(source)
+	imp.source = source
+
+	batch.SellerID, batch.Mode, batch.Status = imp.sellerID, imp.mode, model.ImportBatchApplied
+	err := imp.tx.QueryRow(ctx, `
+		INSERT INTO import_batches (seller_id, job_id, file_name, format, mode, source_key, source_size, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, 0))
+		RETURNING id, created_at`,
+		batch.SellerID, batch.JobID, batch.FileName, batch.Format, batch.Mode, batch.SourceKey, batch.SourceSize, batch.CreatedBy,
+	).Scan(&batch.ID, &batch.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create import batch: %v", err)
+	}
+	imp.batch = batch
+	return nil
+}
+
+// FinishBatch stores the row outcomes and counts of a recorded import. It
+// must be called before Commit.
+func (imp *ProductImport) FinishBatch(ctx context.Context, report *model.ImportReport) error {
+	if imp.batch == nil {
+		return nil
+	}
+	b := imp.batch
+	report.BatchID = b.ID
+	b.Created, b.Updated, b.Deactivated, b.Rejected, b.Report = report.Created, report.Updated, report.Deactivated, report.Rejected, report
+	_, err := imp.tx.Exec(ctx, `
+		UPDATE import_batches SET
+			created_count = $2, updated_count = $3, deactivated_count = $4, rejected_count = $5, report = $6
+		WHERE id = $1`, b.ID, b.Created, b.Updated, b.Deactivated, b.Rejected, report)
+	if err != nil {
+		return fmt.Errorf("failed to save import batch: %v", err)
+	}
+	return nil
+}
+
+// recordItem snapshots a product the import created or changed
+func (imp *ProductImport) recordItem(ctx context.Context, tx pgx.Tx, action model.ImportAction, before, after *model.Product, embeddings []embeddingSnapshot) error {
+	if embeddings == nil {
+		embeddings = []embeddingSnapshot{}
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO import_batch_items (batch_id, product_id, action, before, after, embeddings)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		imp.batch.ID, after.ID, action, before, after, embeddings)
+	if err != nil {
+		return fmt.Errorf("failed to record import change: %v", err)
+	}
+	return nil
+}
+
+// recordImages notes the images the import attached to a product. A product
+// the import otherwise left unchanged is snapshotted as it was.
+func (imp *ProductImport) recordImages(ctx context.Context, tx pgx.Tx, productID int64, imageIDs []int64) error {
+	result, err := tx.Exec(ctx, `
+		UPDATE import_batch_items SET image_ids = image_ids || $3::BIGINT[]
+		WHERE batch_id = $1 AND product_id = $2`, imp.batch.ID, productID, imageIDs)
+	if err != nil {
+		return fmt.Errorf("failed to record import images: %v", err)
+	}
+	if result.RowsAffected() > 0 {
+		return nil
+	}
+
+	p, err := scanProduct(tx.QueryRow(ctx, `SELECT `+productColumns+` FROM products WHERE id = $1`, productID))
+	if err != nil {
+		return fmt.Errorf("failed to get product: %v", err)
+	}
+	p.Images = slices.DeleteFunc(p.Images, func(img model.ProductImage) bool {
+		return slices.Contains(imageIDs, img.ID)
+	})
+	_, err = tx.Exec(ctx, `
+		INSERT INTO import_batch_items (batch_id, product_id, action, before, after, image_ids)
+		VALUES ($1, $2, $3, $4, $4, $5)`,
+		imp.batch.ID, productID, model.ImportActionUnchanged, p, imageIDs)
+	if err != nil {
+		return fmt.Errorf("failed to record import images: %v", err)
+	}
+	return nil
+}
+
+// deactivateRecorded is DeactivateMissing for a recorded import: each
+// product is snapshotted before it is deactivated
+func (imp *ProductImport) deactivateRecorded(ctx context.Context, skus []string) (int, error) {
+	rows, err := imp.tx.Query(ctx, `
+		SELECT `+productColumns+`
+		FROM products
+		WHERE seller_id = $1 AND deleted_at IS NULL AND is_available
+		AND (sku IS NULL OR NOT (sku = ANY($2)))
+		ORDER BY id
+		FOR UPDATE`, imp.sellerID, skus)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list missing products: %v", err)
+	}
+	var missing []*model.Product
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan product: %v", err)
+		}
+		missing = append(missing, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to list missing products: %v", err)
+	}
+
+	for _, before := range missing {
+		after := *before
+		after.IsAvailable = false
+		err := imp.tx.QueryRow(ctx, `
+			UPDATE products SET is_available = FALSE WHERE id = $1
+			RETURNING updated_at`, before.ID).Scan(&after.UpdatedAt)
+		if err != nil {
+			return 0, fmt.Errorf("failed to deactivate missing products: %v", err)
+		}
+		// Deactivating doesn't touch the text, so the vectors stay as they are
+		if err := imp.recordItem(ctx, imp.tx, model.ImportActionDeactivated, before, &after, nil); err != nil {
+			return 0, err
+		}
+	}
+	return len(missing), nil
+}
+
+type ImportService struct{}
+
+func NewImportService() *ImportService {
+	return &ImportService{}
+}
+
+const importBatchColumns = `id, seller_id, job_id, file_name, format, mode, source_key, source_size, status,
+	created_count, updated_count, deactivated_count, rejected_count, COALESCE(created_by, 0), created_at,
+	rolled_back_at, rolled_back_by`
+
+func scanImportBatch(row pgx.Row, extra ...any) (*model.ImportBatch, error) {
+	b := &model.ImportBatch{}
+	err := row.Scan(append([]any{&b.ID, &b.SellerID, &b.JobID, &b.FileName, &b.Format, &b.Mode, &b.SourceKey,
+		&b.SourceSize, &b.Status, &b.Created, &b.Updated, &b.Deactivated, &b.Rejected, &b.CreatedBy, &b.CreatedAt,
+		&b.RolledBackAt, &b.RolledBackBy}, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// ListBatches returns a seller's most recent import batches, newest first,
+// without their reports
+func (s *ImportService) ListBatches(ctx context.Context, sellerID int64, limit int) ([]*model.ImportBatch, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+importBatchColumns+`
+		FROM import_batches
+		WHERE seller_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`, sellerID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list import batches: %v", err)
+	}
+	defer rows.Close()
+
+	batches := []*model.ImportBatch{}
+	for rows.Next() {
+		b, err := scanImportBatch(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan import batch: %v", err)
+		}
+		batches = append(batches, b)
+	}
+	return batches, rows.Err()
+}
+
+// GetBatch returns an import batch with its report
+func (s *ImportService) GetBatch(ctx context.Context, id int64) (*model.ImportBatch, error) {
+	var report *model.ImportReport
+	b, err := scanImportBatch(db.Pool.QueryRow(ctx, `
+		SELECT `+importBatchColumns+`, report
+		FROM import_batches WHERE id = $1`, id), &report)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrImportBatchNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import batch: %v", err)
+	}
+	b.Report = report
+	return b, nil
+}
+
+// GetJobBatch returns the import batch a job committed, with its report
+func (s *ImportService) GetJobBatch(ctx context.Context, jobID int64) (*model.ImportBatch, error) {
+	var report *model.ImportReport
+	b, err := scanImportBatch(db.Pool.QueryRow(ctx, `
+		SELECT `+importBatchColumns+`, report
+		FROM import_batches WHERE job_id = $1`, jobID), &report)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrImportBatchNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import batch: %v", err)
+	}
+	b.Report = report
+	return b, nil
+}
+
+// ListItems returns a page of the products an import batch changed, in ID
+// order, with what changed in each, and their total count
+func (s *ImportService) ListItems(ctx context.Context, batchID int64, limit, offset int) ([]model.ImportBatchItem, int, error) {
+	var total int
+	err := db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM import_batch_items WHERE batch_id = $1`, batchID).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count import changes: %v", err)
+	}
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT product_id, action, before, after, image_ids
+		FROM import_batch_items
+		WHERE batch_id = $1
+		ORDER BY product_id
+		LIMIT $2 OFFSET $3`, batchID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list import changes: %v", err)
+	}
+	defer rows.Close()
+
+	items := []model.ImportBatchItem{}
+	for rows.Next() {
+		var item model.ImportBatchItem
+		if err := rows.Scan(&item.ProductID, &item.Action, &item.Before, &item.After, &item.ImageIDs); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan import change: %v", err)
+		}
+		item.Changes = model.ProductChanges(item.Before, item.After)
+		items = append(items, item)
+	}
+	return items, total, rows.Err()
+}
+
+// OpenSource opens the file an import batch was read from
+func (s *ImportService) OpenSource(ctx context.Context, batch *model.ImportBatch) (io.ReadCloser, error) {
+	return Blobs().Open(ctx, batch.SourceKey)
+}
+
+// rollbackItem is an import batch item with what a rollback needs
+type rollbackItem struct {
+	model.ImportBatchItem
+	embeddings []embeddingSnapshot
+	current    *model.Product // nil when the product has been deleted since
+}
+
+// RollbackBatch puts every product an import batch changed back the way it
+// was before the import, on behalf of userID: created products are deleted,
+// and updated or deactivated ones get their fields, variants and vectors
+// back. Images the import attached are removed. Products changed or deleted
+// since are reported as conflicts and nothing is rolled back, unless force
+// is set; then changed products are restored anyway and deleted ones are
+// left alone. Stock and price changes are logged as adjustments.
+func (s *ImportService) RollbackBatch(ctx context.Context, id, userID int64, force bool) (*model.ImportRollback, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var status string
+	err = tx.QueryRow(ctx, `SELECT status FROM import_batches WHERE id = $1 FOR UPDATE`, id).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrImportBatchNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import batch: %v", err)
+	}
+	if status == model.ImportBatchRolledBack {
+		return nil, ErrImportRolledBack
+	}
+
+	items, err := lockRollbackItems(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	result := &model.ImportRollback{BatchID: id, Conflicts: []model.ImportConflict{}, Forced: force}
+	for _, item := range items {
+		switch {
+		case item.current == nil && item.Action == model.ImportActionCreated:
+			// Deleted since, which is what the rollback would do anyway
+		case item.current == nil:
+			result.Conflicts = append(result.Conflicts, model.ImportConflict{
+				ProductID: item.ProductID, Name: item.After.Name, Reason: "deleted since the import",
+			})
+		default:
+			if changes := model.ProductChanges(item.After, item.current); len(changes) > 0 {
+				result.Conflicts = append(result.Conflicts, model.ImportConflict{
+					ProductID: item.ProductID, Name: item.current.Name, Reason: "changed since the import", Changes: changes,
+				})
+			}
+		}
+	}
+	if len(result.Conflicts) > 0 && !force {
+		return result, ErrImportConflict
+	}
+
+	src := StockSource{Reason: model.StockReasonAdjustment, UserID: userID, Note: fmt.Sprintf("rollback of import %d", id)}
+	var blobKeys []string
+	for _, item := range items {
+		if item.current == nil {
+			continue
+		}
+		keys, err := removeImportImages(ctx, tx, item.ProductID, item.ImageIDs)
+		if err != nil {
+			return nil, err
+		}
+		blobKeys = append(blobKeys, keys...)
+
+		if item.Action == model.ImportActionCreated {
+			if _, err := tx.Exec(ctx, `UPDATE products SET deleted_at = NOW() WHERE id = $1`, item.ProductID); err != nil {
+				return nil, fmt.Errorf("failed to delete product %d: %v", item.ProductID, err)
+			}
+			if _, err := tx.Exec(ctx, `DELETE FROM product_embeddings WHERE product_id = $1`, item.ProductID); err != nil {
+				return nil, fmt.Errorf("failed to delete product embeddings: %v", err)
+			}
+			result.Removed++
+			continue
+		}
+		if err := restoreProduct(ctx, tx, item, src); err != nil {
+			return nil, err
+		}
+		result.Restored++
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE import_batches SET status = $2, rolled_back_at = NOW(), rolled_back_by = NULLIF($3, 0)
+		WHERE id = $1`, id, model.ImportBatchRolledBack, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update import batch: %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit rollback: %v", err)
+	}
+
+	// The rows are gone, so a file left behind is only wasted space
+	for _, key := range blobKeys {
+		Blobs().Delete(ctx, key)
+	}
+	return result, nil
+}
+
+// lockRollbackItems reads the items of an import batch along with the
+// current state of their products, locked until the rollback ends
+func lockRollbackItems(ctx context.Context, tx pgx.Tx, batchID int64) ([]*rollbackItem, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT product_id, action, before, after, embeddings, image_ids
+		FROM import_batch_items
+		WHERE batch_id = $1
+		ORDER BY product_id`, batchID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list import changes: %v", err)
+	}
+	var items []*rollbackItem
+	for rows.Next() {
+		item := &rollbackItem{}
+		if err := rows.Scan(&item.ProductID, &item.Action, &item.Before, &item.After, &item.embeddings, &item.ImageIDs); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan import change: %v", err)
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list import changes: %v", err)
+	}
+
+	for _, item := range items {
+		current, err := scanProduct(tx.QueryRow(ctx, `
+			SELECT `+productColumns+`
+			FROM products
+			WHERE id = $1 AND deleted_at IS NULL
+			FOR UPDATE`, item.ProductID))
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("failed to get product %d: %v", item.ProductID, err)
+		}
+		item.current = current
+	}
+	return items, nil
+}
+
+// removeImportImages deletes the images an import attached to a product and
+// returns the keys of their files
+func removeImportImages(ctx context.Context, tx pgx.Tx, productID int64, imageIDs []int64) ([]string, error) {
+	if len(imageIDs) == 0 {
+		return nil, nil
+	}
+	rows, err := tx.Query(ctx, `
+		DELETE FROM product_images WHERE product_id = $1 AND id = ANY($2)
+		RETURNING blob_key, thumbnail_key`, productID, imageIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete imported images: %v", err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key, thumbnailKey string
+		if err := rows.Scan(&key, &thumbnailKey); err != nil {
+			return nil, fmt.Errorf("failed to delete imported images: %v", err)
+		}
+		keys = append(keys, key, thumbnailKey)
+	}
+	return keys, rows.Err()
+}
+
+// restoreProduct saves the snapshot of a product from before the import
+// over its current state, then puts back the vectors it had
+func restoreProduct(ctx context.Context, tx pgx.Tx, item *rollbackItem, src StockSource) error {
+	if len(model.ProductChanges(item.current, item.Before)) == 0 {
+		return nil
+	}
+	p := *item.Before
+	p.Images, p.AvailableStock = nil, nil
+	if p.CategoryID != nil {
+		// A category deleted since falls back to matching the category text
+		var exists bool
+		err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1 AND seller_id = $2)`,
+			*p.CategoryID, p.SellerID).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to get category: %v", err)
+		}
+		if !exists {
+			p.CategoryID = nil
+		}
+	}
+	if err := updateProduct(ctx, tx, &p, src); err != nil {
+		return fmt.Errorf("failed to restore product %d: %w", p.ID, err)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM product_embeddings WHERE product_id = $1`, p.ID); err != nil {
+		return fmt.Errorf("failed to restore product embeddings: %v", err)
+	}
+	for _, e := range item.embeddings {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO product_embeddings (product_id, model, dimensions, embedding, content_hash)
+			VALUES ($1, $2, $3, $4::vector, $5)`, p.ID, e.Model, e.Dimensions, e.Embedding, e.ContentHash)
+		if err != nil {
+			return fmt.Errorf("failed to restore product embeddings: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,481 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/divinecoid/oneagent/internal/db"
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrVariantNotFound     = errors.New("variant not found")
+	ErrVariantRequired     = errors.New("product has variants; choose one")
+	ErrProductUnavailable  = errors.New("product is not available")
+	ErrInsufficientStock   = errors.New("not enough stock")
+	ErrCartItemNotFound    = errors.New("cart item not found")
+	ErrCartEmpty           = errors.New("cart is empty")
+	ErrStockNotTrackedHere = errors.New("product has variants; stock is tracked per variant")
+	ErrVariantInCarts      = errors.New("variant is in customers' carts")
+)
+
+// CartReservationTTL is how long a cart item holds its stock. Adding to or
+// changing the item renews the hold.
+const CartReservationTTL = 30 * time.Minute
+
+// StockSource says why stock changed and who changed it, for the movement
+// log. Price changes made along with it are logged with the same source.
+type StockSource struct {
+	Reason string
+	UserID int64 // 0 when no user is responsible
+	Note   string
+}
+
+type InventoryService struct{}
+
+func NewInventoryService() *InventoryService {
+	return &InventoryService{}
+}
+
+// recordStockChange logs the change from before to after. Nothing is logged
+// when stock isn't tracked afterwards or didn't change; an item that starts
+// being tracked is logged as a change from zero.
+func recordStockChange(ctx context.Context, tx pgx.Tx, productID int64, variantID *int64, before, after *int, src StockSource) error {
+	if after == nil || (before != nil && *before == *after) {
+		return nil
+	}
+	change := *after
+	if before != nil {
+		change -= *before
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO stock_movements (product_id, variant_id, variant_name, change, stock_after, reason, note, created_by)
+		VALUES ($1, $2, (SELECT name FROM product_variants WHERE id = $2), $3, $4, $5, NULLIF($6, ''), NULLIF($7, 0))`,
+		productID, variantID, change, *after, src.Reason, src.Note, src.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to record stock movement: %v", err)
+	}
+	return nil
+}
+
+// stockItem is a product or variant locked for a stock change
+type stockItem struct {
+	productID int64
+	variantID *int64
+	name      string
+	price     float64
+	available bool
+	stock     *int // nil when not tracked
+}
+
+// lockStockItem locks the row holding the stock of a product, or of one of
+// its variants, until the transaction ends
+func lockStockItem(ctx context.Context, tx pgx.Tx, productID int64, variantID *int64) (*stockItem, error) {
+	item := &stockItem{productID: productID, variantID: variantID}
+	var hasVariants bool
+	err := tx.QueryRow(ctx, `
+		SELECT p.name, p.price, p.is_available, p.stock,
+			EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = p.id)
+		FROM products p
+		WHERE p.id = $1 AND p.deleted_at IS NULL
+		FOR UPDATE`, productID,
+	).Scan(&item.name, &item.price, &item.available, &item.stock, &hasVariants)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock product: %v", err)
+	}
+	if variantID == nil {
+		if hasVariants {
+			return nil, ErrVariantRequired
+		}
+		return item, nil
+	}
+
+	var variantName string
+	var variantAvailable bool
+	err = tx.QueryRow(ctx, `
+		SELECT name, price, is_available, stock
+		FROM product_variants
+		WHERE id = $1 AND product_id = $2
+		FOR UPDATE`, *variantID, productID,
+	).Scan(&variantName, &item.price, &variantAvailable, &item.stock)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrVariantNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock variant: %v", err)
+	}
+	item.name += " - " + variantName
+	item.available = item.available && variantAvailable
+	return item, nil
+}
+
+// setStock writes a locked item's new stock level
+func (item *stockItem) setStock(ctx context.Context, tx pgx.Tx, stock int) error {
+	var err error
+	if item.variantID == nil {
+		_, err = tx.Exec(ctx, `UPDATE products SET stock = $2 WHERE id = $1`, item.productID, stock)
+	} else {
+		_, err = tx.Exec(ctx, `UPDATE product_variants SET stock = $2 WHERE id = $1`, *item.variantID, stock)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update stock: %v", err)
+	}
+	return nil
+}
+
+// freeStock is the locked item's stock that isn't reserved by other carts,
+// or nil when stock isn't tracked. cartID's own reservation counts as free.
+func (item *stockItem) freeStock(ctx context.Context, tx pgx.Tx, cartID int64) (*int, error) {
+	if item.stock == nil {
+		return nil, nil
+	}
+	var free int
+	err := tx.QueryRow(ctx, `
+		SELECT available_stock($1, $2) + COALESCE((
+			SELECT qty FROM stock_reservations WHERE cart_id = $3 AND expires_at > NOW()
+		), 0)`, item.productID, item.variantID, cartID,
+	).Scan(&free)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check stock: %v", err)
+	}
+	return &free, nil
+}
+
+// AdjustStock changes the stock of a product, or of one of its variants, by
+// change and logs it as a manual adjustment. An untracked item starts being
+// tracked from zero. Stock can't go below zero.
+func (s *InventoryService) AdjustStock(ctx context.Context, productID int64, variantID *int64, change int, note string, userID int64) (*model.StockMovement, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	item, err := lockStockItem(ctx, tx, productID, variantID)
+	if errors.Is(err, ErrVariantRequired) {
+		return nil, ErrStockNotTrackedHere
+	}
+	if err != nil {
+		return nil, err
+	}
+	before := 0
+	if item.stock != nil {
+		before = *item.stock
+	}
+	after := before + change
+	if after < 0 {
+		return nil, fmt.Errorf("%w: %s has %d in stock", ErrInsufficientStock, item.name, before)
+	}
+	if err := item.setStock(ctx, tx, after); err != nil {
+		return nil, err
+	}
+
+	m := &model.StockMovement{ProductID: productID, VariantID: variantID, Change: change, StockAfter: after,
+		Reason: model.StockReasonAdjustment, Note: note}
+	if userID != 0 {
+		m.CreatedBy = &userID
+	}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO stock_movements (product_id, variant_id, variant_name, change, stock_after, reason, note, created_by)
+		VALUES ($1, $2, (SELECT name FROM product_variants WHERE id = $2), $3, $4, $5, NULLIF($6, ''), $7)
+		RETURNING id, COALESCE(variant_name, ''), created_at`,
+		m.ProductID, m.VariantID, m.Change, m.StockAfter, m.Reason, m.Note, m.CreatedBy,
+	).Scan(&m.ID, &m.VariantName, &m.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record stock movement: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit stock adjustment: %v", err)
+	}
+	return m, nil
+}
+
+// ListMovements returns the most recent stock movements of a product and its
+// variants, newest first
+func (s *InventoryService) ListMovements(ctx context.Context, productID int64, limit int) ([]model.StockMovement, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT id, product_id, variant_id, COALESCE(variant_name, ''), change, stock_after, reason, COALESCE(note, ''),
+			created_by, created_at
+		FROM stock_movements
+		WHERE product_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`, productID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stock movements: %v", err)
+	}
+	defer rows.Close()
+
+	movements := []model.StockMovement{}
+	for rows.Next() {
+		var m model.StockMovement
+		if err := rows.Scan(&m.ID, &m.ProductID, &m.VariantID, &m.VariantName, &m.Change, &m.StockAfter, &m.Reason,
+			&m.Note, &m.CreatedBy, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan stock movement: %v", err)
+		}
+		movements = append(movements, m)
+	}
+	return movements, rows.Err()
+}
+
+// GetCart returns a user's cart. Reservation expiry is only shown while the
+// reservation still holds stock. Each line gets the best discount of the
+// promotions running now on its product, applied to the line's quantity.
+func (s *InventoryService) GetCart(ctx context.Context, userID int64) (*model.Cart, error) {
+	return readCart(ctx, db.Pool, userID, false)
+}
+
+// rowsQuerier is what both the pool and a transaction offer
+type rowsQuerier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// readCart reads a user's cart for GetCart, in the order the lines were
+// added. With lock set, q must be a transaction, and the lines are locked
+// and returned in product and variant order, the order checkout locks their
+// stock in.
+func readCart(ctx context.Context, q rowsQuerier, userID int64, lock bool) (*model.Cart, error) {
+	order := "c.created_at, c.id"
+	if lock {
+		order = "c.product_id, c.variant_id NULLS FIRST, c.id FOR UPDATE OF c"
+	}
+	rows, err := q.Query(ctx, `
+		SELECT c.id, c.product_id, c.variant_id, p.name, COALESCE(v.name, ''), c.qty, c.price,
+			CASE WHEN r.expires_at > NOW() THEN r.expires_at END, c.created_at, `+PromotionsJSON("c.product_id")+`
+		FROM carts c
+		JOIN products p ON p.id = c.product_id
+		LEFT JOIN product_variants v ON v.id = c.variant_id
+		LEFT JOIN stock_reservations r ON r.cart_id = c.id
+		WHERE c.user_id = $1
+		ORDER BY `+order, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cart: %v", err)
+	}
+	defer rows.Close()
+
+	cart := &model.Cart{Items: []model.CartItem{}}
+	for rows.Next() {
+		var item model.CartItem
+		var promotions []model.PromotionRule
+		if err := rows.Scan(&item.ID, &item.ProductID, &item.VariantID, &item.ProductName, &item.VariantName,
+			&item.Qty, &item.Price, &item.ReservationExpiresAt, &item.CreatedAt, &promotions); err != nil {
+			return nil, fmt.Errorf("failed to scan cart item: %v", err)
+		}
+		gross := item.Price * float64(item.Qty)
+		if best, discount := model.BestPromotion(promotions, item.Price, item.Qty); best != nil {
+			item.Discount, item.Promotion = discount, best.Label()
+		}
+		item.Subtotal = gross - item.Discount
+		cart.Subtotal += gross
+		cart.Discount += item.Discount
+		cart.Total += item.Subtotal
+		cart.Items = append(cart.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get cart: %v", err)
+	}
+	return cart, nil
+}
+
+// AddToCart adds qty of a product or variant to a user's cart, merging with
+// an existing line for the same item, and reserves the stock
+func (s *InventoryService) AddToCart(ctx context.Context, userID, productID int64, variantID *int64, qty int) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// The line is created empty, or locked if it exists, in one statement, so
+	// concurrent adds of the same item meet on the same line. An empty line
+	// is filled in below or rolled back.
+	var cartID int64
+	var current int
+	err = tx.QueryRow(ctx, `
+		INSERT INTO carts (user_id, product_id, variant_id, qty, price)
+		VALUES ($1, $2, $3, 0, 0)
+		ON CONFLICT (user_id, product_id, COALESCE(variant_id, 0)) DO UPDATE SET qty = carts.qty
+		RETURNING id, qty`, userID, productID, variantID,
+	).Scan(&cartID, &current)
+	if err != nil {
+		if isForeignKeyViolation(err) && variantID != nil {
+			return ErrVariantNotFound
+		}
+		if isForeignKeyViolation(err) {
+			return ErrProductNotFound
+		}
+		return fmt.Errorf("failed to get cart item: %v", err)
+	}
+
+	if err := reserveCartItem(ctx, tx, cartID, productID, variantID, current+qty); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit cart: %v", err)
+	}
+	return nil
+}
+
+// UpdateCartItem sets the quantity of a cart line and renews its reservation
+func (s *InventoryService) UpdateCartItem(ctx context.Context, userID, cartID int64, qty int) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var productID int64
+	var variantID *int64
+	err = tx.QueryRow(ctx, `
+		SELECT product_id, variant_id FROM carts WHERE id = $1 AND user_id = $2 FOR UPDATE`, cartID, userID,
+	).Scan(&productID, &variantID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrCartItemNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get cart item: %v", err)
+	}
+
+	if err := reserveCartItem(ctx, tx, cartID, productID, variantID, qty); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit cart: %v", err)
+	}
+	return nil
+}
+
+// reserveCartItem sets a locked cart line to qty at the item's current price
+// and (re)reserves its stock
+func reserveCartItem(ctx context.Context, tx pgx.Tx, cartID, productID int64, variantID *int64, qty int) error {
+	item, err := lockStockItem(ctx, tx, productID, variantID)
+	if err != nil {
+		return err
+	}
+	if !item.available {
+		return fmt.Errorf("%w: %s", ErrProductUnavailable, item.name)
+	}
+	free, err := item.freeStock(ctx, tx, cartID)
+	if err != nil {
+		return err
+	}
+	if free != nil && *free < qty {
+		return fmt.Errorf("%w: only %d of %s left", ErrInsufficientStock, *free, item.name)
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE carts SET qty = $2, price = $3 WHERE id = $1`, cartID, qty, item.price)
+	if err != nil {
+		return fmt.Errorf("failed to save cart item: %v", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO stock_reservations (cart_id, product_id, variant_id, qty, expires_at)
+		VALUES ($1, $2, $3, $4, NOW() + make_interval(secs => $5))
+		ON CONFLICT (cart_id) DO UPDATE SET qty = EXCLUDED.qty, expires_at = EXCLUDED.expires_at`,
+		cartID, productID, variantID, qty, CartReservationTTL.Seconds())
+	if err != nil {
+		return fmt.Errorf("failed to reserve stock: %v", err)
+	}
+	return nil
+}
+
+// RemoveCartItem deletes a cart line, releasing its reservation
+func (s *InventoryService) RemoveCartItem(ctx context.Context, userID, cartID int64) error {
+	result, err := db.Pool.Exec(ctx, `DELETE FROM carts WHERE id = $1 AND user_id = $2`, cartID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove cart item: %v", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrCartItemNotFound
+	}
+	return nil
+}
+
+// Checkout sells everything in a user's cart: stock is deducted and logged
+// as sales, and the cart is emptied. Lines whose reservation expired are
+// sold only if the stock is still there. It returns the cart as sold.
+//
+// The cart's lines are locked before anything is checked, so a concurrent
+// change to the cart waits, and stock is locked in product and variant
+// order, so checkouts sharing items can't deadlock.
+func (s *InventoryService) Checkout(ctx context.Context, userID int64) (*model.Cart, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	cart, err := readCart(ctx, tx, userID, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(cart.Items) == 0 {
+		return nil, ErrCartEmpty
+	}
+
+	for _, line := range cart.Items {
+		item, err := lockStockItem(ctx, tx, line.ProductID, line.VariantID)
+		if err != nil {
+			return nil, err
+		}
+		if !item.available {
+			return nil, fmt.Errorf("%w: %s", ErrProductUnavailable, item.name)
+		}
+		free, err := item.freeStock(ctx, tx, line.ID)
+		if err != nil {
+			return nil, err
+		}
+		if free == nil {
+			continue // Stock isn't tracked
+		}
+		if *free < line.Qty {
+			return nil, fmt.Errorf("%w: only %d of %s left", ErrInsufficientStock, *free, item.name)
+		}
+		after := *item.stock - line.Qty
+		if err := item.setStock(ctx, tx, after); err != nil {
+			return nil, err
+		}
+		src := StockSource{Reason: model.StockReasonSale, UserID: userID, Note: fmt.Sprintf("cart item %d", line.ID)}
+		if err := recordStockChange(ctx, tx, line.ProductID, line.VariantID, item.stock, &after, src); err != nil {
+			return nil, err
+		}
+	}
+
+	// Only the lines that were locked; a line added meanwhile stays
+	ids := make([]int64, len(cart.Items))
+	for i, line := range cart.Items {
+		ids[i] = line.ID
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM carts WHERE user_id = $1 AND id = ANY($2)`, userID, ids); err != nil {
+		return nil, fmt.Errorf("failed to empty cart: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit checkout: %v", err)
+	}
+	for i := range cart.Items {
+		cart.Items[i].ReservationExpiresAt = nil
+	}
+	slices.SortFunc(cart.Items, func(a, b model.CartItem) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+	return cart, nil
+}
+
+// ExpireReservations deletes reservations that no longer hold stock. Expired
+// reservations are already ignored by available_stock; this only keeps the
+// table small.
+func (s *InventoryService) ExpireReservations(ctx context.Context) error {
+	if _, err := db.Pool.Exec(ctx, `DELETE FROM stock_reservations WHERE expires_at <= NOW()`); err != nil {
+		return fmt.Errorf("failed to expire reservations: %v", err)
+	}
+	return nil
+}
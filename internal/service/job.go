@@ -0,0 +1,480 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/divinecoid/oneagent/internal/db"
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrJobNotFound     = errors.New("job not found")
+	ErrJobCancelled    = errors.New("job cancelled")
+	ErrJobNotRetryable = errors.New("only failed or cancelled jobs can be retried")
+	ErrJobFinished     = errors.New("job already finished")
+	// ErrJobLost is returned to a worker whose job was reclaimed by another
+	// worker after it stopped reporting; the worker must stop
+	ErrJobLost = errors.New("job was taken over by another worker")
+)
+
+const (
+	jobPollInterval    = 2 * time.Second
+	jobStaleAfter      = 10 * time.Minute
+	jobCheckpointEvery = 2 * time.Second
+	maxStoredJobErrors = 1000
+)
+
+// JobHandlerFunc processes a claimed job. Progress and per-item errors are
+// reported through the JobReporter; returning an error fails the attempt.
+type JobHandlerFunc func(ctx context.Context, job *model.Job, r *JobReporter) error
+
+type JobService struct {
+	handlers map[model.JobType]JobHandlerFunc
+	periodic []periodicTask
+	workerID string
+	mu       sync.RWMutex
+}
+
+type periodicTask struct {
+	name     string
+	interval time.Duration
+	fn       func(ctx context.Context) error
+}
+
+func NewJobService() *JobService {
+	hostname, _ := os.Hostname()
+	return &JobService{
+		handlers: make(map[model.JobType]JobHandlerFunc),
+		workerID: fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+	}
+}
+
+// RegisterHandler sets the function that processes jobs of the given type.
+func (s *JobService) RegisterHandler(jobType model.JobType, handler JobHandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[jobType] = handler
+}
+
+// Every registers a task that runs at the given interval once Start is
+// called, e.g. to queue jobs for work that was detected automatically.
+func (s *JobService) Every(name string, interval time.Duration, fn func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.periodic = append(s.periodic, periodicTask{name: name, interval: interval, fn: fn})
+}
+
+const jobColumns = `
+	id, type, status, seller_id, payload, total_items, processed_items, failed_items,
+	attempts, max_attempts, cancel_requested, COALESCE(last_error, ''), result,
+	created_by, created_at, started_at, finished_at, updated_at`
+
+func scanJob(row pgx.Row) (*model.Job, error) {
+	job := &model.Job{}
+	err := row.Scan(
+		&job.ID, &job.Type, &job.Status, &job.SellerID, &job.Payload,
+		&job.TotalItems, &job.ProcessedItems, &job.FailedItems,
+		&job.Attempts, &job.MaxAttempts, &job.CancelRequested, &job.LastError, &job.Result,
+		&job.CreatedBy, &job.CreatedAt, &job.StartedAt, &job.FinishedAt, &job.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Enqueue stores a new job in the queued state.
+func (s *JobService) Enqueue(ctx context.Context, job *model.Job) error {
+	if job.Payload == nil {
+		job.Payload = []byte("{}")
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = 3
+	}
+	query := `
+		INSERT INTO jobs (type, status, seller_id, payload, input, max_attempts, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + jobColumns
+	created, err := scanJob(db.Pool.QueryRow(
+		ctx, query,
+		job.Type, model.JobStatusQueued, job.SellerID, job.Payload, job.Input, job.MaxAttempts, job.CreatedBy,
+	))
+	if err != nil {
+		return fmt.Errorf("failed to enqueue job: %v", err)
+	}
+	created.Input = job.Input
+	*job = *created
+	return nil
+}
+
+// GetJob retrieves a job by its ID.
+func (s *JobService) GetJob(ctx context.Context, id int64) (*model.Job, error) {
+	job, err := scanJob(db.Pool.QueryRow(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %v", err)
+	}
+	return job, nil
+}
+
+// ListJobs returns the most recent jobs, limited to those created by userID
+// unless userID is 0.
+func (s *JobService) ListJobs(ctx context.Context, userID int64, limit int) ([]model.Job, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+jobColumns+`
+		FROM jobs
+		WHERE $1 = 0 OR created_by = $1
+		ORDER BY created_at DESC
+		LIMIT $2`, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %v", err)
+	}
+	defer rows.Close()
+
+	jobs := []model.Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %v", err)
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, rows.Err()
+}
+
+// GetJobErrors returns the per-item errors logged for a job, oldest first.
+func (s *JobService) GetJobErrors(ctx context.Context, jobID int64, limit int) ([]model.JobError, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT id, job_id, attempt, item_ref, message, created_at
+		FROM job_errors
+		WHERE job_id = $1
+		ORDER BY id
+		LIMIT $2`, jobID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job errors: %v", err)
+	}
+	defer rows.Close()
+
+	jobErrors := []model.JobError{}
+	for rows.Next() {
+		var e model.JobError
+		if err := rows.Scan(&e.ID, &e.JobID, &e.Attempt, &e.ItemRef, &e.Message, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan job error: %v", err)
+		}
+		jobErrors = append(jobErrors, e)
+	}
+	return jobErrors, rows.Err()
+}
+
+// CancelJob cancels a queued job immediately, or asks a running job to stop
+// at its next checkpoint.
+func (s *JobService) CancelJob(ctx context.Context, id int64) (*model.Job, error) {
+	job, err := scanJob(db.Pool.QueryRow(ctx, `
+		UPDATE jobs SET
+			cancel_requested = TRUE,
+			status = CASE WHEN status = 'queued' THEN 'cancelled' ELSE status END,
+			finished_at = CASE WHEN status = 'queued' THEN NOW() ELSE finished_at END,
+			updated_at = NOW()
+		WHERE id = $1 AND status IN ('queued', 'running')
+		RETURNING `+jobColumns, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, getErr := s.GetJob(ctx, id); getErr != nil {
+			return nil, getErr
+		}
+		return nil, ErrJobFinished
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel job: %v", err)
+	}
+	return job, nil
+}
+
+// RetryJob puts a failed or cancelled job back on the queue with fresh
+// progress counters. Errors from earlier attempts are kept.
+func (s *JobService) RetryJob(ctx context.Context, id int64) (*model.Job, error) {
+	job, err := scanJob(db.Pool.QueryRow(ctx, `
+		UPDATE jobs SET
+			status = 'queued', cancel_requested = FALSE,
+			total_items = 0, processed_items = 0, failed_items = 0,
+			max_attempts = attempts + 1, run_after = NOW(),
+			locked_by = NULL, locked_at = NULL, finished_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND status IN ('failed', 'cancelled')
+		RETURNING `+jobColumns, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, getErr := s.GetJob(ctx, id); getErr != nil {
+			return nil, getErr
+		}
+		return nil, ErrJobNotRetryable
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retry job: %v", err)
+	}
+	return job, nil
+}
+
+// Start launches the given number of worker goroutines and the periodic
+// tasks. They stop when ctx is cancelled.
+func (s *JobService) Start(ctx context.Context, workers int) {
+	for i := 0; i < workers; i++ {
+		go s.work(ctx, fmt.Sprintf("%s-%d", s.workerID, i))
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, task := range s.periodic {
+		go s.schedule(ctx, task)
+	}
+}
+
+func (s *JobService) schedule(ctx context.Context, task periodicTask) {
+	ticker := time.NewTicker(task.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := task.fn(ctx); err != nil {
+				fmt.Printf("Periodic task %s failed: %v\n", task.name, err)
+			}
+		}
+	}
+}
+
+func (s *JobService) work(ctx context.Context, workerID string) {
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+	for {
+		// Drain the queue before waiting for the next tick
+		for {
+			job, err := s.claim(ctx, workerID)
+			if err != nil {
+				fmt.Printf("Job worker %s: failed to claim job: %v\n", workerID, err)
+				break
+			}
+			if job == nil {
+				break
+			}
+			s.run(ctx, job, workerID)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim locks the next runnable job. Jobs whose worker stopped reporting
+// are picked up again.
+func (s *JobService) claim(ctx context.Context, workerID string) (*model.Job, error) {
+	row := db.Pool.QueryRow(ctx, `
+		UPDATE jobs SET
+			status = 'running', locked_by = $1, locked_at = NOW(),
+			attempts = attempts + 1, total_items = 0, processed_items = 0, failed_items = 0, result = NULL,
+			started_at = COALESCE(started_at, NOW()), updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE (status = 'queued' AND run_after <= NOW())
+			   OR (status = 'running' AND locked_at < NOW() - make_interval(secs => $2))
+			ORDER BY run_after, id
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING `+jobColumns+`, input`, workerID, jobStaleAfter.Seconds())
+
+	job := &model.Job{}
+	err := row.Scan(
+		&job.ID, &job.Type, &job.Status, &job.SellerID, &job.Payload,
+		&job.TotalItems, &job.ProcessedItems, &job.FailedItems,
+		&job.Attempts, &job.MaxAttempts, &job.CancelRequested, &job.LastError, &job.Result,
+		&job.CreatedBy, &job.CreatedAt, &job.StartedAt, &job.FinishedAt, &job.UpdatedAt,
+		&job.Input,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (s *JobService) run(ctx context.Context, job *model.Job, workerID string) {
+	s.mu.RLock()
+	handler, ok := s.handlers[job.Type]
+	s.mu.RUnlock()
+
+	reporter := &JobReporter{job: job, workerID: workerID, lastFlush: time.Now()}
+
+	var runErr error
+	if !ok {
+		runErr = fmt.Errorf("no handler registered for job type %q", job.Type)
+	} else {
+		runErr = func() (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("job panicked: %v", r)
+				}
+			}()
+			return handler(ctx, job, reporter)
+		}()
+	}
+
+	// A cancellation that arrives after the handler finished does not undo its work
+	if err := reporter.flush(ctx); err != nil && (runErr == nil || errors.Is(err, ErrJobLost)) && !errors.Is(err, ErrJobCancelled) {
+		runErr = err
+	}
+
+	// Every update is conditional on still holding the lock, so a worker
+	// whose job was reclaimed can't overwrite the new worker's outcome
+	var result pgconn.CommandTag
+	var err error
+	switch {
+	case errors.Is(runErr, ErrJobLost):
+		fmt.Printf("Job worker %s: job %d was taken over by another worker\n", workerID, job.ID)
+		return
+	case runErr == nil:
+		result, err = db.Pool.Exec(ctx, `
+			UPDATE jobs SET status = 'succeeded', finished_at = NOW(), locked_by = NULL, updated_at = NOW()
+			WHERE id = $1 AND locked_by = $2`, job.ID, workerID)
+	case errors.Is(runErr, ErrJobCancelled):
+		result, err = db.Pool.Exec(ctx, `
+			UPDATE jobs SET status = 'cancelled', finished_at = NOW(), locked_by = NULL, updated_at = NOW()
+			WHERE id = $1 AND locked_by = $2`, job.ID, workerID)
+	case job.Attempts < job.MaxAttempts:
+		backoff := time.Duration(job.Attempts*job.Attempts) * 30 * time.Second
+		result, err = db.Pool.Exec(ctx, `
+			UPDATE jobs SET status = 'queued', last_error = $3, run_after = NOW() + make_interval(secs => $4),
+				locked_by = NULL, locked_at = NULL, updated_at = NOW()
+			WHERE id = $1 AND locked_by = $2`, job.ID, workerID, runErr.Error(), backoff.Seconds())
+	default:
+		result, err = db.Pool.Exec(ctx, `
+			UPDATE jobs SET status = 'failed', last_error = $3, finished_at = NOW(), locked_by = NULL, updated_at = NOW()
+			WHERE id = $1 AND locked_by = $2`, job.ID, workerID, runErr.Error())
+	}
+	if err != nil {
+		fmt.Printf("Job worker %s: failed to record result of job %d: %v\n", workerID, job.ID, err)
+	} else if result.RowsAffected() == 0 {
+		fmt.Printf("Job worker %s: job %d was taken over by another worker; its result was not recorded\n", workerID, job.ID)
+	}
+	if runErr != nil {
+		fmt.Printf("Job %d (%s) attempt %d ended: %v\n", job.ID, job.Type, job.Attempts, runErr)
+	}
+}
+
+// JobReporter accumulates progress for a running job and persists it
+// periodically. Each checkpoint doubles as a heartbeat and a cancellation check.
+type JobReporter struct {
+	job       *model.Job
+	workerID  string
+	total     int
+	processed int
+	failed    int
+	stored    int
+	lastFlush time.Time
+}
+
+// SetTotal records how many items the job expects to process.
+func (r *JobReporter) SetTotal(ctx context.Context, total int) error {
+	r.total = total
+	return r.flush(ctx)
+}
+
+// Succeeded records n items as processed without errors.
+func (r *JobReporter) Succeeded(n int) {
+	r.processed += n
+}
+
+// Failed records a processed item that could not be handled. itemRef
+// identifies the item for the person reading the log, e.g. "row 12".
+func (r *JobReporter) Failed(ctx context.Context, itemRef string, cause error) {
+	r.CountFailed()
+	r.LogError(ctx, itemRef, cause)
+}
+
+// CountFailed records a processed item that could not be handled without
+// logging why, for work whose errors are only logged with LogError once it
+// has been committed.
+func (r *JobReporter) CountFailed() {
+	r.processed++
+	r.failed++
+}
+
+// LogError logs why an item could not be handled on the job's current
+// attempt, without counting it.
+func (r *JobReporter) LogError(ctx context.Context, itemRef string, cause error) {
+	if r.stored >= maxStoredJobErrors {
+		return
+	}
+	r.stored++
+	_, err := db.Pool.Exec(ctx,
+		`INSERT INTO job_errors (job_id, attempt, item_ref, message) VALUES ($1, $2, $3, $4)`,
+		r.job.ID, r.job.Attempts, itemRef, cause.Error(),
+	)
+	if err != nil {
+		fmt.Printf("Job %d: failed to log error for %s: %v\n", r.job.ID, itemRef, err)
+	}
+}
+
+// SetResult stores the job's structured outcome, replacing any earlier one.
+func (r *JobReporter) SetResult(ctx context.Context, result any) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job result: %v", err)
+	}
+	stored, err := db.Pool.Exec(ctx, `
+		UPDATE jobs SET result = $3, updated_at = NOW() WHERE id = $1 AND locked_by = $2`, r.job.ID, r.workerID, data)
+	if err != nil {
+		return fmt.Errorf("failed to store job result: %v", err)
+	}
+	if stored.RowsAffected() == 0 {
+		return ErrJobLost
+	}
+	r.job.Result = data
+	return nil
+}
+
+// Checkpoint persists progress if enough time has passed since the last
+// write. It returns ErrJobCancelled once cancellation has been requested,
+// and ErrJobLost once another worker has reclaimed the job.
+func (r *JobReporter) Checkpoint(ctx context.Context) error {
+	if time.Since(r.lastFlush) < jobCheckpointEvery {
+		return nil
+	}
+	return r.flush(ctx)
+}
+
+func (r *JobReporter) flush(ctx context.Context) error {
+	var cancelRequested bool
+	err := db.Pool.QueryRow(ctx, `
+		UPDATE jobs SET total_items = $3, processed_items = $4, failed_items = $5,
+			locked_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND locked_by = $2
+		RETURNING cancel_requested`,
+		r.job.ID, r.workerID, r.total, r.processed, r.failed,
+	).Scan(&cancelRequested)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrJobLost
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update job progress: %v", err)
+	}
+	r.lastFlush = time.Now()
+	r.job.TotalItems, r.job.ProcessedItems, r.job.FailedItems = r.total, r.processed, r.failed
+	if cancelRequested {
+		return ErrJobCancelled
+	}
+	return nil
+}
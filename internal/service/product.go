@@ -0,0 +1,370 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/divinecoid/oneagent/internal/db"
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrDuplicateSKU    = errors.New("another product of this seller already has this SKU")
+)
+
+type ProductService struct{}
+
+func NewProductService() *ProductService {
+	return &ProductService{}
+}
+
+// VariantsJSON returns a scalar subquery aggregating the variants of the
+// product whose ID is productID into a JSON array, in display order
+func VariantsJSON(productID string) string {
+	return `(SELECT COALESCE(jsonb_agg(jsonb_build_object(
+		'id', v.id, 'name', v.name, 'options', v.options, 'sku', COALESCE(v.sku, ''),
+		'price', v.price, 'stock', v.stock, 'is_available', v.is_available,
+		'available_stock', available_stock(v.product_id, v.id)
+	) ORDER BY v.position, v.id), '[]') FROM product_variants v WHERE v.product_id = ` + productID + `)`
+}
+
+// productColumns are the columns scanProduct reads from an unaliased
+// products table
+var productColumns = `id, COALESCE(seller_id, 0), COALESCE(sku, ''), name, COALESCE(category, ''), category_id, price, COALESCE(description, ''),
+	attributes, is_available, stock, available_stock(id, NULL), created_at, updated_at, ` + VariantsJSON("products.id") + `,
+	` + ImagesJSON("products.id")
+
+func scanProduct(row pgx.Row) (*model.Product, error) {
+	p := &model.Product{}
+	err := row.Scan(&p.ID, &p.SellerID, &p.SKU, &p.Name, &p.Category, &p.CategoryID, &p.Price, &p.Description,
+		&p.Attributes, &p.IsAvailable, &p.Stock, &p.AvailableStock, &p.CreatedAt, &p.UpdatedAt, &p.Variants, &p.Images)
+	if err != nil {
+		return nil, err
+	}
+	if p.Attributes == nil {
+		p.Attributes = map[string]any{}
+	}
+	ResolveImageURLs(p.Images)
+	return p, nil
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// isForeignKeyViolation reports whether err is a foreign key violation
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23503"
+}
+
+// ListProducts returns a page of products, newest first, with the total
+// count. sellerID 0 lists every seller's products.
+func (s *ProductService) ListProducts(ctx context.Context, sellerID int64, limit, offset int) ([]model.Product, int, error) {
+	var total int
+	err := db.Pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM products
+		WHERE deleted_at IS NULL AND ($1 = 0 OR seller_id = $1)`, sellerID).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count products: %v", err)
+	}
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+productColumns+`
+		FROM products
+		WHERE deleted_at IS NULL AND ($1 = 0 OR seller_id = $1)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3`, sellerID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list products: %v", err)
+	}
+	defer rows.Close()
+
+	products := []model.Product{}
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan product: %v", err)
+		}
+		products = append(products, *p)
+	}
+	return products, total, rows.Err()
+}
+
+// GetProduct returns a product that hasn't been deleted
+func (s *ProductService) GetProduct(ctx context.Context, id int64) (*model.Product, error) {
+	p, err := scanProduct(db.Pool.QueryRow(ctx, `
+		SELECT `+productColumns+`
+		FROM products
+		WHERE id = $1 AND deleted_at IS NULL`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product: %v", err)
+	}
+	return p, nil
+}
+
+// CreateProduct inserts a product and its variants on behalf of userID. It is
+// embedded by the next stale-embedding run.
+func (s *ProductService) CreateProduct(ctx context.Context, p *model.Product, userID int64) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := insertProduct(ctx, tx, p, StockSource{Reason: model.StockReasonAdjustment, UserID: userID}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit product: %v", err)
+	}
+	return nil
+}
+
+func insertProduct(ctx context.Context, tx pgx.Tx, p *model.Product, src StockSource) error {
+	if p.Attributes == nil {
+		p.Attributes = map[string]any{}
+	}
+	if p.Images == nil {
+		p.Images = []model.ProductImage{} // Images are added once the product exists
+	}
+	if _, err := assignCategory(ctx, tx, p); err != nil {
+		return err
+	}
+	err := tx.QueryRow(ctx, `
+		INSERT INTO products (seller_id, sku, name, category, category_id, price, description, attributes, is_available, stock)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at, updated_at`,
+		p.SellerID, p.SKU, p.Name, p.Category, p.CategoryID, p.Price, p.Description, p.Attributes, p.IsAvailable, p.Stock,
+	).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	if isUniqueViolation(err) {
+		return ErrDuplicateSKU
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create product: %v", err)
+	}
+	if err := recordStockChange(ctx, tx, p.ID, nil, nil, p.Stock, src); err != nil {
+		return err
+	}
+	if err := recordPriceChange(ctx, tx, p.ID, nil, nil, p.Price, src); err != nil {
+		return err
+	}
+	return saveVariants(ctx, tx, p, src)
+}
+
+// UpdateProduct saves every editable field of a product. When the name,
+// category or description change, the product's vectors no longer describe
+// it, so they are dropped in the same transaction; search falls back to text
+// matching until the next stale-embedding run re-embeds it. Stock changes are
+// logged as adjustments by userID.
+func (s *ProductService) UpdateProduct(ctx context.Context, p *model.Product, userID int64) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := updateProduct(ctx, tx, p, StockSource{Reason: model.StockReasonAdjustment, UserID: userID}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit product update: %v", err)
+	}
+	return nil
+}
+
+func updateProduct(ctx context.Context, tx pgx.Tx, p *model.Product, src StockSource) error {
+	if p.Attributes == nil {
+		p.Attributes = map[string]any{}
+	}
+	if _, err := assignCategory(ctx, tx, p); err != nil {
+		return err
+	}
+	var stockBefore *int
+	var priceBefore *float64
+	err := tx.QueryRow(ctx, `
+		WITH old AS (SELECT stock, price FROM products WHERE id = $10 FOR UPDATE)
+		UPDATE products SET
+			sku = NULLIF($1, ''), name = $2, category = $3, category_id = $4, price = $5, description = $6,
+			attributes = $7, is_available = $8, stock = $9
+		WHERE id = $10 AND deleted_at IS NULL
+		RETURNING updated_at, (SELECT stock FROM old), (SELECT price FROM old)`,
+		p.SKU, p.Name, p.Category, p.CategoryID, p.Price, p.Description, p.Attributes, p.IsAvailable, p.Stock, p.ID,
+	).Scan(&p.UpdatedAt, &stockBefore, &priceBefore)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrProductNotFound
+	}
+	if isUniqueViolation(err) {
+		return ErrDuplicateSKU
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update product: %v", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		DELETE FROM product_embeddings e
+		USING products p
+		WHERE e.product_id = p.id AND p.id = $1 AND e.content_hash <> p.content_hash`, p.ID)
+	if err != nil {
+		return fmt.Errorf("failed to invalidate product embeddings: %v", err)
+	}
+	if err := recordStockChange(ctx, tx, p.ID, nil, stockBefore, p.Stock, src); err != nil {
+		return err
+	}
+	if err := recordPriceChange(ctx, tx, p.ID, nil, priceBefore, p.Price, src); err != nil {
+		return err
+	}
+	return saveVariants(ctx, tx, p, src)
+}
+
+// saveVariants makes the stored variants of a product match p.Variants.
+// Variants are matched by name, so an edited variant keeps its ID. Dropping a
+// variant that is in customers' carts is refused with ErrVariantInCarts.
+func saveVariants(ctx context.Context, tx pgx.Tx, p *model.Product, src StockSource) error {
+	if p.Variants == nil {
+		p.Variants = []model.ProductVariant{}
+	}
+	names := make([]string, len(p.Variants))
+	for i, v := range p.Variants {
+		names[i] = v.Name
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT v.name FROM product_variants v
+		WHERE v.product_id = $1 AND NOT (v.name = ANY($2))
+		AND EXISTS (SELECT 1 FROM carts c WHERE c.variant_id = v.id)
+		ORDER BY v.position, v.id`, p.ID, names)
+	if err != nil {
+		return fmt.Errorf("failed to check product variants: %v", err)
+	}
+	inCarts, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return fmt.Errorf("failed to check product variants: %v", err)
+	}
+	if len(inCarts) > 0 {
+		return fmt.Errorf("%w: %s", ErrVariantInCarts, strings.Join(inCarts, ", "))
+	}
+
+	_, err = tx.Exec(ctx, `
+		DELETE FROM product_variants WHERE product_id = $1 AND NOT (name = ANY($2))`, p.ID, names)
+	if err != nil {
+		return fmt.Errorf("failed to delete product variants: %v", err)
+	}
+
+	for i := range p.Variants {
+		v := &p.Variants[i]
+		if v.Options == nil {
+			v.Options = map[string]string{}
+		}
+		var stockBefore *int
+		var priceBefore *float64
+		err := tx.QueryRow(ctx, `
+			WITH old AS (SELECT stock, price FROM product_variants WHERE product_id = $1 AND name = $2 FOR UPDATE)
+			INSERT INTO product_variants (product_id, name, options, sku, price, stock, is_available, position)
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8)
+			ON CONFLICT (product_id, name) DO UPDATE SET
+				options = EXCLUDED.options, sku = EXCLUDED.sku, price = EXCLUDED.price,
+				stock = EXCLUDED.stock, is_available = EXCLUDED.is_available, position = EXCLUDED.position
+			RETURNING id, (SELECT stock FROM old), (SELECT price FROM old)`,
+			p.ID, v.Name, v.Options, v.SKU, v.Price, v.Stock, v.IsAvailable, i,
+		).Scan(&v.ID, &stockBefore, &priceBefore)
+		if err != nil {
+			return fmt.Errorf("failed to save variant %q: %v", v.Name, err)
+		}
+		if err := recordStockChange(ctx, tx, p.ID, &v.ID, stockBefore, v.Stock, src); err != nil {
+			return err
+		}
+		if err := recordPriceChange(ctx, tx, p.ID, &v.ID, priceBefore, v.Price, src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateVariants checks the variants of a product before they are saved
+func ValidateVariants(variants []model.ProductVariant) error {
+	seen := map[string]bool{}
+	for _, v := range variants {
+		name := strings.ToLower(strings.TrimSpace(v.Name))
+		switch {
+		case name == "":
+			return fmt.Errorf("variant name is required")
+		case len([]rune(v.Name)) > 100:
+			return fmt.Errorf("variant name %q is longer than 100 characters", v.Name)
+		case seen[name]:
+			return fmt.Errorf("variant %q is listed more than once", v.Name)
+		case v.Price < 0 || v.Price > 99999999.99:
+			return fmt.Errorf("variant %q has an invalid price", v.Name)
+		case v.Stock != nil && *v.Stock < 0:
+			return fmt.Errorf("variant %q has negative stock", v.Name)
+		case len([]rune(v.SKU)) > 100:
+			return fmt.Errorf("variant %q has a SKU longer than 100 characters", v.Name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// DeleteProduct soft-deletes a product and drops its vectors
+func (s *ProductService) DeleteProduct(ctx context.Context, id int64) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `
+		UPDATE products SET deleted_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete product: %v", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrProductNotFound
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM product_embeddings WHERE product_id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete product embeddings: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit product delete: %v", err)
+	}
+	return nil
+}
+
+// EachProduct calls fn for every product of a seller in ID order, streaming
+// them from the database rather than loading the whole catalog
+func (s *ProductService) EachProduct(ctx context.Context, sellerID int64, fn func(*model.Product) error) error {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+productColumns+`
+		FROM products
+		WHERE deleted_at IS NULL AND seller_id = $1
+		ORDER BY id`, sellerID)
+	if err != nil {
+		return fmt.Errorf("failed to list products: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan product: %v", err)
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
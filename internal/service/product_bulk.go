@@ -0,0 +1,269 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// ImportChunkSize is how many rows ApplyRows should be given at a time:
+// enough for COPY to pay off, while a chunk's products stay small in memory
+const ImportChunkSize = 500
+
+// importCategory is where a category as written in a file landed in the tree
+type importCategory struct {
+	id   *int64
+	name string
+}
+
+// ApplyRows applies a chunk of accepted rows to the catalog, setting each
+// row's Action. A row that can't be applied becomes a rejected row with the
+// reason. The products matching the chunk's SKUs are looked up in one query,
+// and new products without variants are written with COPY; the other rows
+// are applied one at a time. An error is returned only when the import
+// can't go on.
+func (imp *ProductImport) ApplyRows(ctx context.Context, rows []*model.ImportRow) error {
+	var pending []*model.ImportRow
+	for _, row := range rows {
+		row.Product.SellerID = imp.sellerID
+		if err := imp.claimSKU(row.Product); err != nil {
+			rejectImportRow(row, err)
+			continue
+		}
+		pending = append(pending, row)
+	}
+	if err := imp.lookUpSKUs(ctx, pending); err != nil {
+		return err
+	}
+	defer clear(imp.found)
+
+	var copies, singles []*model.ImportRow
+	for _, row := range pending {
+		if imp.copyable(row.Product) {
+			copies = append(copies, row)
+		} else {
+			singles = append(singles, row)
+		}
+	}
+	if len(copies) > 0 {
+		singles = append(singles, imp.copyRows(ctx, copies)...)
+	}
+
+	for _, row := range singles {
+		action, err := imp.apply(ctx, row.Product)
+		if err != nil {
+			rejectImportRow(row, err)
+			continue
+		}
+		row.Action = action
+	}
+	return nil
+}
+
+// rejectImportRow turns an accepted row into a rejected one
+func rejectImportRow(row *model.ImportRow, err error) {
+	row.Status, row.Errors, row.Product = model.ImportRowRejected, []string{err.Error()}, nil
+}
+
+// lookUpSKUs finds and locks the products matching the rows' SKUs, for
+// apply and copyable to use
+func (imp *ProductImport) lookUpSKUs(ctx context.Context, rows []*model.ImportRow) error {
+	skus := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if sku := row.Product.SKU; sku != "" {
+			skus = append(skus, sku)
+			imp.found[sku] = nil
+		}
+	}
+	if len(skus) == 0 {
+		return nil
+	}
+
+	result, err := imp.tx.Query(ctx, `
+		SELECT `+productColumns+`
+		FROM products
+		WHERE seller_id = $1 AND sku = ANY($2) AND deleted_at IS NULL
+		FOR UPDATE`, imp.sellerID, skus)
+	if err != nil {
+		return fmt.Errorf("failed to look up skus: %v", err)
+	}
+	defer result.Close()
+	for result.Next() {
+		p, err := scanProduct(result)
+		if err != nil {
+			return fmt.Errorf("failed to scan product: %v", err)
+		}
+		imp.found[p.SKU] = p
+	}
+	return result.Err()
+}
+
+// copyable reports whether a row makes a new product COPY can write: one
+// without variants whose SKU, if it has one, matches no product
+func (imp *ProductImport) copyable(p *model.Product) bool {
+	if len(p.Variants) > 0 || p.CategoryID != nil {
+		return false
+	}
+	if p.SKU == "" {
+		return true
+	}
+	existing, ok := imp.found[p.SKU]
+	return ok && existing == nil
+}
+
+// copyRows writes new products with COPY and returns the rows that have to
+// be applied one at a time instead: those whose category can't be resolved,
+// or all of them if the COPY fails.
+func (imp *ProductImport) copyRows(ctx context.Context, rows []*model.ImportRow) []*model.ImportRow {
+	var rest, copies []*model.ImportRow
+	for _, row := range rows {
+		if imp.mapCategory(ctx, row.Product) {
+			copies = append(copies, row)
+		} else {
+			rest = append(rest, row)
+		}
+	}
+	if len(copies) == 0 {
+		return rest
+	}
+	if err := imp.copyProducts(ctx, copies); err != nil {
+		fmt.Printf("Import for seller %d: copying %d rows failed, applying them one at a time: %v\n", imp.sellerID, len(copies), err)
+		return append(rest, copies...)
+	}
+	for _, row := range copies {
+		row.Action = model.ImportActionCreated
+	}
+	return rest
+}
+
+// mapCategory maps a row's category onto the tree like assignCategory
+// does, once per distinct text in the file. It reports false if the
+// category can't be resolved, leaving apply to report why.
+func (imp *ProductImport) mapCategory(ctx context.Context, p *model.Product) bool {
+	c, ok := imp.categories[p.Category]
+	if !ok {
+		sp, err := imp.tx.Begin(ctx)
+		if err != nil {
+			return false
+		}
+		defer sp.Rollback(ctx)
+		probe := &model.Product{SellerID: imp.sellerID, Category: p.Category}
+		created, err := assignCategory(ctx, sp, probe)
+		if err != nil || sp.Commit(ctx) != nil {
+			return false
+		}
+		imp.categoriesCreated += created
+		c = importCategory{id: probe.CategoryID, name: probe.Category}
+		imp.categories[p.Category] = c
+	}
+	p.CategoryID, p.Category = c.id, c.name
+	return true
+}
+
+// copyProducts inserts new products with COPY, along with their stock and
+// price history and, when a batch is recorded, their import batch items.
+// It's all or nothing: on error, nothing was written.
+func (imp *ProductImport) copyProducts(ctx context.Context, rows []*model.ImportRow) error {
+	sp, err := imp.tx.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin savepoint: %v", err)
+	}
+	defer sp.Rollback(ctx)
+
+	// COPY can't return the IDs it assigns, so they are taken up front
+	idRows, err := sp.Query(ctx, `
+		SELECT nextval(pg_get_serial_sequence('products', 'id')) FROM generate_series(1, $1)`, len(rows))
+	if err != nil {
+		return fmt.Errorf("failed to allocate product IDs: %v", err)
+	}
+	ids, err := pgx.CollectRows(idRows, pgx.RowTo[int64])
+	if err != nil {
+		return fmt.Errorf("failed to allocate product IDs: %v", err)
+	}
+
+	var userID, note any // NULL unless set, like recordStockChange writes them
+	if imp.src.UserID != 0 {
+		userID = imp.src.UserID
+	}
+	if imp.src.Note != "" {
+		note = imp.src.Note
+	}
+	products := make([][]any, len(rows))
+	prices := make([][]any, len(rows))
+	var movements [][]any
+	for i, row := range rows {
+		p := row.Product
+		p.ID = ids[i]
+		if p.Attributes == nil {
+			p.Attributes = map[string]any{}
+		}
+		p.Images, p.Variants = []model.ProductImage{}, []model.ProductVariant{}
+		var sku any
+		if p.SKU != "" {
+			sku = p.SKU
+		}
+		products[i] = []any{p.ID, p.SellerID, sku, p.Name, p.Category, p.CategoryID, p.Price, p.Description, p.Attributes, p.IsAvailable, p.Stock}
+		prices[i] = []any{p.ID, p.Price, priceChangeReason(imp.src), userID}
+		if p.Stock != nil {
+			movements = append(movements, []any{p.ID, *p.Stock, *p.Stock, imp.src.Reason, note, userID})
+		}
+	}
+	copies := []struct {
+		table   string
+		columns []string
+		rows    [][]any
+	}{
+		{"products", []string{"id", "seller_id", "sku", "name", "category", "category_id", "price", "description", "attributes", "is_available", "stock"}, products},
+		{"stock_movements", []string{"product_id", "change", "stock_after", "reason", "note", "created_by"}, movements},
+		{"price_history", []string{"product_id", "new_price", "reason", "created_by"}, prices},
+	}
+	for _, c := range copies {
+		if len(c.rows) == 0 {
+			continue
+		}
+		if _, err := sp.CopyFrom(ctx, pgx.Identifier{c.table}, c.columns, pgx.CopyFromRows(c.rows)); err != nil {
+			return fmt.Errorf("failed to copy %s: %v", c.table, err)
+		}
+	}
+
+	// Timestamps come from column defaults
+	stamps, err := sp.Query(ctx, `SELECT id, created_at, updated_at FROM products WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return fmt.Errorf("failed to read copied products: %v", err)
+	}
+	byID := make(map[int64]*model.Product, len(rows))
+	for _, row := range rows {
+		byID[row.Product.ID] = row.Product
+	}
+	for stamps.Next() {
+		var id int64
+		var p model.Product
+		if err := stamps.Scan(&id, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			stamps.Close()
+			return fmt.Errorf("failed to read copied products: %v", err)
+		}
+		byID[id].CreatedAt, byID[id].UpdatedAt = p.CreatedAt, p.UpdatedAt
+	}
+	if err := stamps.Err(); err != nil {
+		return fmt.Errorf("failed to read copied products: %v", err)
+	}
+
+	if imp.batch != nil {
+		items := make([][]any, len(rows))
+		for i, row := range rows {
+			items[i] = []any{imp.batch.ID, row.Product.ID, model.ImportActionCreated, row.Product}
+		}
+		_, err := sp.CopyFrom(ctx, pgx.Identifier{"import_batch_items"},
+			[]string{"batch_id", "product_id", "action", "after"}, pgx.CopyFromRows(items))
+		if err != nil {
+			return fmt.Errorf("failed to record import changes: %v", err)
+		}
+	}
+
+	if err := sp.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to release savepoint: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/divinecoid/oneagent/internal/db"
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrSameProduct is returned when a product is paired with itself
+	ErrSameProduct = errors.New("a product can't be its own duplicate")
+	// ErrCartItemsNotMovable is returned when a customer's cart line for the
+	// duplicate has no matching variant, or not enough stock, on the survivor
+	ErrCartItemsNotMovable = errors.New("cart items can't be moved to the surviving product")
+)
+
+// lockProductPair locks two live products of a seller, reporting either
+// one missing as ErrProductNotFound
+func lockProductPair(ctx context.Context, tx pgx.Tx, sellerID, a, b int64) error {
+	if a == b {
+		return ErrSameProduct
+	}
+	var n int
+	err := tx.QueryRow(ctx, `
+		SELECT COUNT(*) FROM (
+			SELECT id FROM products
+			WHERE id = ANY($1) AND seller_id = $2 AND deleted_at IS NULL
+			FOR UPDATE
+		) locked`, []int64{a, b}, sellerID).Scan(&n)
+	if err != nil {
+		return fmt.Errorf("failed to lock products: %v", err)
+	}
+	if n != 2 {
+		return ErrProductNotFound
+	}
+	return nil
+}
+
+// KeepProducts marks two of a seller's products as distinct, so they are no
+// longer reported as duplicates of each other
+func (s *ProductService) KeepProducts(ctx context.Context, sellerID, productID, otherID, userID int64) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockProductPair(ctx, tx, sellerID, productID, otherID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO product_duplicate_dismissals (product_id, other_id, created_by)
+		VALUES (LEAST($1::BIGINT, $2::BIGINT), GREATEST($1::BIGINT, $2::BIGINT), $3)
+		ON CONFLICT DO NOTHING`, productID, otherID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to keep products: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit keep: %v", err)
+	}
+	return nil
+}
+
+// MergeProducts folds a duplicate into the product that survives it. Cart
+// lines of the duplicate move to the survivor, onto the variant of the same
+// name, and are added to a line the same user already has for that item;
+// their stock is reserved on the survivor again. A line the survivor can't
+// take, for want of the variant or the stock, stops the merge with
+// ErrCartItemsNotMovable. Search logs and promotions that name the duplicate name the
+// survivor instead, so analytics and discounts carry over. The duplicate is
+// then soft-deleted. Its stock, variants and images are left as they were.
+func (s *ProductService) MergeProducts(ctx context.Context, sellerID, survivorID, duplicateID, userID int64) (*model.ProductMerge, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockProductPair(ctx, tx, sellerID, survivorID, duplicateID); err != nil {
+		return nil, err
+	}
+	merge := &model.ProductMerge{SellerID: sellerID, SurvivorID: survivorID, MergedID: duplicateID, CreatedBy: userID}
+	if merge.CartItemsMoved, err = moveCartItems(ctx, tx, survivorID, duplicateID); err != nil {
+		return nil, err
+	}
+
+	// A survivor that is already listed only loses the duplicate, so no array
+	// names a product twice
+	result, err := tx.Exec(ctx, `
+		UPDATE search_logs
+		SET result_ids = CASE WHEN $2::BIGINT = ANY(result_ids) THEN array_remove(result_ids, $1::BIGINT)
+			ELSE array_replace(result_ids, $1::BIGINT, $2::BIGINT) END
+		WHERE seller_id = $3 AND result_ids @> ARRAY[$1::BIGINT]`, duplicateID, survivorID, sellerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update search logs: %v", err)
+	}
+	merge.SearchLogsUpdated = int(result.RowsAffected())
+	result, err = tx.Exec(ctx, `
+		UPDATE promotions
+		SET product_ids = CASE WHEN $2::BIGINT = ANY(product_ids) THEN array_remove(product_ids, $1::BIGINT)
+			ELSE array_replace(product_ids, $1::BIGINT, $2::BIGINT) END
+		WHERE seller_id = $3 AND product_ids @> ARRAY[$1::BIGINT]`, duplicateID, survivorID, sellerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update promotions: %v", err)
+	}
+	merge.PromotionsUpdated = int(result.RowsAffected())
+
+	if _, err := tx.Exec(ctx, `UPDATE products SET deleted_at = NOW() WHERE id = $1`, duplicateID); err != nil {
+		return nil, fmt.Errorf("failed to delete product: %v", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM product_embeddings WHERE product_id = $1`, duplicateID); err != nil {
+		return nil, fmt.Errorf("failed to delete product embeddings: %v", err)
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO product_merges (seller_id, survivor_id, merged_id, cart_items_moved, search_logs_updated,
+			promotions_updated, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`,
+		sellerID, survivorID, duplicateID, merge.CartItemsMoved, merge.SearchLogsUpdated, merge.PromotionsUpdated, userID,
+	).Scan(&merge.ID, &merge.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record merge: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit merge: %v", err)
+	}
+	return merge, nil
+}
+
+// moveCartItems moves the cart lines of a duplicate to its survivor, and
+// returns how many lines moved. Each moved line is priced and reserved like
+// a line the customer added to the survivor.
+func moveCartItems(ctx context.Context, tx pgx.Tx, survivorID, duplicateID int64) (int, error) {
+	type cartLine struct {
+		id, userID int64
+		variantID  *int64
+		variant    string
+		qty        int
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT c.id, c.user_id, c.variant_id, COALESCE(v.name, ''), c.qty
+		FROM carts c
+		LEFT JOIN product_variants v ON v.id = c.variant_id
+		WHERE c.product_id = $1
+		ORDER BY c.id
+		FOR UPDATE OF c`, duplicateID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get cart items: %v", err)
+	}
+	var lines []cartLine
+	for rows.Next() {
+		var l cartLine
+		if err := rows.Scan(&l.id, &l.userID, &l.variantID, &l.variant, &l.qty); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan cart item: %v", err)
+		}
+		lines = append(lines, l)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to get cart items: %v", err)
+	}
+
+	for _, l := range lines {
+		var variantID *int64
+		if l.variantID != nil {
+			err := tx.QueryRow(ctx, `
+				SELECT id FROM product_variants WHERE product_id = $1 AND lower(name) = lower($2)`,
+				survivorID, l.variant).Scan(&variantID)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return 0, fmt.Errorf("%w: the survivor has no variant %q", ErrCartItemsNotMovable, l.variant)
+			}
+			if err != nil {
+				return 0, fmt.Errorf("failed to match variant: %v", err)
+			}
+		}
+
+		var existing int64
+		var existingQty int
+		err := tx.QueryRow(ctx, `
+			SELECT id, qty FROM carts
+			WHERE user_id = $1 AND product_id = $2 AND variant_id IS NOT DISTINCT FROM $3
+			FOR UPDATE`, l.userID, survivorID, variantID,
+		).Scan(&existing, &existingQty)
+		cartID, qty := l.id, l.qty
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			// The line becomes the survivor's; its old reservation goes and
+			// a new one is made below
+			if _, err := tx.Exec(ctx, `DELETE FROM stock_reservations WHERE cart_id = $1`, l.id); err != nil {
+				return 0, fmt.Errorf("failed to release reservation: %v", err)
+			}
+			if _, err := tx.Exec(ctx, `UPDATE carts SET product_id = $2, variant_id = $3 WHERE id = $1`,
+				l.id, survivorID, variantID); err != nil {
+				return 0, fmt.Errorf("failed to move cart item: %v", err)
+			}
+		case err != nil:
+			return 0, fmt.Errorf("failed to get cart item: %v", err)
+		default:
+			// The duplicate's line and its reservation go; the user's line
+			// for the survivor holds both quantities
+			if _, err := tx.Exec(ctx, `DELETE FROM carts WHERE id = $1`, l.id); err != nil {
+				return 0, fmt.Errorf("failed to remove cart item: %v", err)
+			}
+			cartID, qty = existing, existingQty+l.qty
+		}
+
+		err = reserveCartItem(ctx, tx, cartID, survivorID, variantID, qty)
+		switch {
+		case errors.Is(err, ErrInsufficientStock), errors.Is(err, ErrProductUnavailable), errors.Is(err, ErrVariantRequired):
+			return 0, fmt.Errorf("%w: %w", ErrCartItemsNotMovable, err)
+		case err != nil:
+			return 0, err
+		}
+	}
+	return len(lines), nil
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/xuri/excelize/v2"
+)
+
+// ProductExportColumns is the header of exported catalogs. Every column maps
+// back to a product field on import, so an exported file can be imported
+// again without losing anything.
+var ProductExportColumns = []string{
+	importFieldSKU, importFieldName, importFieldCategory, importFieldPrice, importFieldDescription,
+	importFieldAvailable, importFieldStock, importFieldAttributes, importFieldVariants,
+}
+
+// productExportSheet is the sheet name of exported XLSX catalogs
+const productExportSheet = "Products"
+
+// exportRecord renders a product as CSV cells in ProductExportColumns order.
+// Prices are written with a "." decimal point and no grouping, which the
+// import reads unambiguously.
+func exportRecord(p *model.Product) []string {
+	stock := ""
+	if p.Stock != nil {
+		stock = strconv.Itoa(*p.Stock)
+	}
+	return []string{
+		p.SKU, p.Name, p.Category, strconv.FormatFloat(p.Price, 'f', -1, 64), p.Description,
+		strconv.FormatBool(p.IsAvailable), stock, exportAttributesCell(p), exportVariantsCell(p),
+	}
+}
+
+// exportXLSXRow renders a product as XLSX cells in ProductExportColumns
+// order. Prices and stock stay numeric so sellers can edit and sum them in
+// Excel; untracked stock is an empty cell.
+func exportXLSXRow(p *model.Product) []any {
+	var stock any
+	if p.Stock != nil {
+		stock = *p.Stock
+	}
+	return []any{
+		p.SKU, p.Name, p.Category, p.Price, p.Description, p.IsAvailable, stock, exportAttributesCell(p), exportVariantsCell(p),
+	}
+}
+
+// exportAttributesCell renders a product's attributes as the JSON object the
+// import reads. A product without attributes gets "{}" rather than "null",
+// which the import would reject.
+func exportAttributesCell(p *model.Product) string {
+	if p.Attributes == nil {
+		return "{}"
+	}
+	data, _ := json.Marshal(p.Attributes)
+	return string(data)
+}
+
+// exportVariants returns a product's variants without their IDs, which are
+// internal (the import matches variants by name), or their reserved stock
+func exportVariants(p *model.Product) []model.ProductVariant {
+	variants := make([]model.ProductVariant, len(p.Variants))
+	for i, v := range p.Variants {
+		v.ID, v.AvailableStock = 0, nil
+		variants[i] = v
+	}
+	return variants
+}
+
+// exportVariantsCell renders a product's variants as the JSON array the
+// import reads, or an empty cell when it has none
+func exportVariantsCell(p *model.Product) string {
+	if len(p.Variants) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(exportVariants(p))
+	return string(data)
+}
+
+// exportProduct is the JSON form of an exported product
+type exportProduct struct {
+	SKU         string                 `json:"sku"`
+	Name        string                 `json:"name"`
+	Category    string                 `json:"category"`
+	Price       float64                `json:"price"`
+	Description string                 `json:"description"`
+	IsAvailable bool                   `json:"is_available"`
+	Stock       *int                   `json:"stock"`
+	Attributes  map[string]any         `json:"attributes"`
+	Variants    []model.ProductVariant `json:"variants"`
+}
+
+func newExportProduct(p *model.Product) exportProduct {
+	return exportProduct{
+		SKU:         p.SKU,
+		Name:        p.Name,
+		Category:    p.Category,
+		Price:       p.Price,
+		Description: p.Description,
+		IsAvailable: p.IsAvailable,
+		Stock:       p.Stock,
+		Attributes:  p.Attributes,
+		Variants:    exportVariants(p),
+	}
+}
+
+// ExportProducts writes a seller's catalog to w in the given format. CSV and
+// JSON are streamed row by row; XLSX is assembled with excelize's stream
+// writer and written once complete.
+func (s *ProductService) ExportProducts(ctx context.Context, w io.Writer, format string, sellerID int64) error {
+	switch format {
+	case FormatCSV:
+		return s.exportCSV(ctx, w, sellerID)
+	case FormatJSON:
+		return s.exportJSON(ctx, w, sellerID)
+	case FormatXLSX:
+		return s.exportXLSX(ctx, w, sellerID)
+	}
+	return fmt.Errorf("unsupported format %q", format)
+}
+
+func (s *ProductService) exportCSV(ctx context.Context, w io.Writer, sellerID int64) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(ProductExportColumns); err != nil {
+		return fmt.Errorf("failed to write CSV: %v", err)
+	}
+	err := s.EachProduct(ctx, sellerID, func(p *model.Product) error {
+		return writer.Write(exportRecord(p))
+	})
+	if err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func (s *ProductService) exportJSON(ctx context.Context, w io.Writer, sellerID int64) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	first := true
+	err := s.EachProduct(ctx, sellerID, func(p *model.Product) error {
+		data, err := json.Marshal(newExportProduct(p))
+		if err != nil {
+			return fmt.Errorf("failed to marshal product %d: %v", p.ID, err)
+		}
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "]\n")
+	return err
+}
+
+func (s *ProductService) exportXLSX(ctx context.Context, w io.Writer, sellerID int64) error {
+	xlsx := excelize.NewFile()
+	defer xlsx.Close()
+	if err := xlsx.SetSheetName("Sheet1", productExportSheet); err != nil {
+		return fmt.Errorf("failed to create sheet: %v", err)
+	}
+	stream, err := xlsx.NewStreamWriter(productExportSheet)
+	if err != nil {
+		return fmt.Errorf("failed to create sheet: %v", err)
+	}
+
+	header := make([]any, len(ProductExportColumns))
+	for i, column := range ProductExportColumns {
+		header[i] = column
+	}
+	if err := stream.SetRow("A1", header); err != nil {
+		return fmt.Errorf("failed to write header: %v", err)
+	}
+
+	row := 2
+	err = s.EachProduct(ctx, sellerID, func(p *model.Product) error {
+		cell, _ := excelize.CoordinatesToCellName(1, row)
+		row++
+		return stream.SetRow(cell, exportXLSXRow(p))
+	})
+	if err != nil {
+		return err
+	}
+	if err := stream.Flush(); err != nil {
+		return fmt.Errorf("failed to write sheet: %v", err)
+	}
+	if _, err := xlsx.WriteTo(w); err != nil {
+		return fmt.Errorf("failed to write workbook: %v", err)
+	}
+	return nil
+}
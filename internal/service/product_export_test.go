@@ -0,0 +1,118 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/xuri/excelize/v2"
+)
+
+func intPtr(v int) *int { return &v }
+
+// exportTestProducts covers what an export must carry back into an import:
+// variants with options and their own stock, attributes, product stock,
+// unavailability, and text that needs quoting
+func exportTestProducts() []*model.Product {
+	return []*model.Product{
+		{
+			ID: 1, SKU: "KPI-001", Name: "Kopi Susu Gula Aren", Category: "Minuman",
+			Price: 18000, Description: "Espresso, susu segar \"full cream\" dan gula aren\nDisajikan dingin",
+			IsAvailable: true,
+			Attributes:  map[string]any{"suhu": "dingin", "kafein": true, "ukuran_ml": float64(350)},
+			Variants: []model.ProductVariant{
+				{ID: 11, Name: "Regular", Options: map[string]string{"ukuran": "R"}, SKU: "KPI-001-R", Price: 18000,
+					Stock: intPtr(40), IsAvailable: true, AvailableStock: intPtr(38)},
+				{ID: 12, Name: "Large", Options: map[string]string{"ukuran": "L"}, SKU: "KPI-001-L", Price: 22500.5,
+					Stock: intPtr(0), IsAvailable: false},
+			},
+		},
+		{
+			ID: 2, SKU: "NSG-002", Name: "Nasi Goreng Spesial", Category: "Makanan",
+			Price: 25000, Description: "Nasi goreng, telur, ayam suwir", IsAvailable: false,
+			Stock: intPtr(12), Attributes: map[string]any{"pedas": "sedang"},
+		},
+		{
+			ID: 3, Name: "Es Teh", Price: 5000, IsAvailable: true,
+		},
+	}
+}
+
+// TestExportImportRoundTrip exports products in every format and imports
+// the file again, expecting the same products back
+func TestExportImportRoundTrip(t *testing.T) {
+	products := exportTestProducts()
+	files := map[string][]byte{}
+
+	var csvFile bytes.Buffer
+	writer := csv.NewWriter(&csvFile)
+	writer.Write(ProductExportColumns)
+	for _, p := range products {
+		writer.Write(exportRecord(p))
+	}
+	writer.Flush()
+	files[FormatCSV] = csvFile.Bytes()
+
+	exported := make([]exportProduct, len(products))
+	for i, p := range products {
+		exported[i] = newExportProduct(p)
+	}
+	jsonFile, err := json.Marshal(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files[FormatJSON] = jsonFile
+
+	xlsx := excelize.NewFile()
+	header := make([]any, len(ProductExportColumns))
+	for i, column := range ProductExportColumns {
+		header[i] = column
+	}
+	xlsx.SetSheetRow("Sheet1", "A1", &header)
+	for i, p := range products {
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		row := exportXLSXRow(p)
+		xlsx.SetSheetRow("Sheet1", cell, &row)
+	}
+	xlsxFile, err := xlsx.WriteToBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	files[FormatXLSX] = xlsxFile.Bytes()
+
+	for format, data := range files {
+		t.Run(format, func(t *testing.T) {
+			report, err := ParseProductFile(format, data, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Rejected != 0 || len(report.Rows) != len(products) {
+				t.Fatalf("accepted %d, rejected %d: %+v", report.Accepted, report.Rejected, report.Rows)
+			}
+			if len(report.Ignored) != 0 {
+				t.Errorf("ignored columns %v, want every exported column imported", report.Ignored)
+			}
+			for i, row := range report.Rows {
+				want, got := products[i], row.Product
+				if got.SKU != want.SKU || got.Name != want.Name || got.Category != want.Category ||
+					got.Price != want.Price || got.Description != want.Description || got.IsAvailable != want.IsAvailable {
+					t.Errorf("row %d: got %+v, want %+v", row.Row, got, want)
+				}
+				if !reflect.DeepEqual(got.Stock, want.Stock) {
+					t.Errorf("row %d: stock = %v, want %v", row.Row, got.Stock, want.Stock)
+				}
+				if len(want.Attributes) > 0 && !reflect.DeepEqual(got.Attributes, want.Attributes) {
+					t.Errorf("row %d: attributes = %v, want %v", row.Row, got.Attributes, want.Attributes)
+				}
+				if wantVariants := exportVariants(want); len(wantVariants) > 0 || len(got.Variants) > 0 {
+					if !reflect.DeepEqual(got.Variants, wantVariants) {
+						t.Errorf("row %d: variants = %+v, want %+v", row.Row, got.Variants, wantVariants)
+					}
+				}
+			}
+		})
+	}
+}
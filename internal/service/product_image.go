@@ -0,0 +1,227 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // Registers GIF decoding
+	"image/jpeg"
+	_ "image/png" // Registers PNG decoding
+
+	"github.com/divinecoid/oneagent/internal/db"
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrInvalidImage  = errors.New("not a JPEG, PNG or GIF image")
+	ErrImageTooLarge = errors.New("image is too large")
+	ErrImageNotFound = errors.New("image not found")
+)
+
+const (
+	MaxImageBytes  = 10 << 20
+	maxImagePixels = 40_000_000 // Decoding is refused above this, whatever the file size
+	thumbnailSize  = 320        // Longest side of thumbnails, in pixels
+)
+
+// imageContentTypes maps decoded image formats to their MIME type and the
+// extension the original is stored with
+var imageContentTypes = map[string][2]string{
+	"jpeg": {"image/jpeg", "jpg"},
+	"png":  {"image/png", "png"},
+	"gif":  {"image/gif", "gif"},
+}
+
+// ImagesJSON returns a subquery aggregating the images of the product whose
+// ID is productID as a JSON array ordered by position. The URLs are left
+// empty; ResolveImageURLs fills them in.
+func ImagesJSON(productID string) string {
+	return `(SELECT COALESCE(jsonb_agg(jsonb_build_object(
+		'id', i.id, 'key', i.blob_key, 'thumbnail_key', i.thumbnail_key,
+		'content_type', i.content_type, 'width', i.width, 'height', i.height
+	) ORDER BY i.position, i.id), '[]') FROM product_images i WHERE i.product_id = ` + productID + `)`
+}
+
+// ResolveImageURLs sets the URLs of images from their blob keys
+func ResolveImageURLs(images []model.ProductImage) {
+	store := Blobs()
+	for i := range images {
+		images[i].URL = store.URL(images[i].Key)
+		images[i].ThumbnailURL = store.URL(images[i].ThumbnailKey)
+	}
+}
+
+// CheckImage reports whether data is an image that AddImage would accept,
+// without decoding the pixels
+func CheckImage(data []byte) error {
+	_, _, err := imageConfig(data)
+	return err
+}
+
+func imageConfig(data []byte) (image.Config, string, error) {
+	if len(data) > MaxImageBytes {
+		return image.Config{}, "", fmt.Errorf("%w: over %d MB", ErrImageTooLarge, MaxImageBytes>>20)
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return config, "", ErrInvalidImage
+	}
+	if _, ok := imageContentTypes[format]; !ok {
+		return config, "", ErrInvalidImage
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return config, "", fmt.Errorf("%w: %dx%d pixels", ErrImageTooLarge, config.Width, config.Height)
+	}
+	return config, format, nil
+}
+
+// rowQuerier is what both the pool and a transaction offer
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+type ImageService struct {
+	store BlobStore
+}
+
+func NewImageService(store BlobStore) *ImageService {
+	return &ImageService{store: store}
+}
+
+// AddImage stores an image and its thumbnail and appends it to the product's
+// images. Adding an image the product already has returns the existing one
+// with added false.
+func (s *ImageService) AddImage(ctx context.Context, productID int64, data []byte) (*model.ProductImage, bool, error) {
+	return addProductImage(ctx, db.Pool, s.store, productID, data)
+}
+
+func addProductImage(ctx context.Context, q rowQuerier, store BlobStore, productID int64, data []byte) (*model.ProductImage, bool, error) {
+	config, format, err := imageConfig(data)
+	if err != nil {
+		return nil, false, err
+	}
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+
+	img := &model.ProductImage{}
+	err = q.QueryRow(ctx, `
+		SELECT id, blob_key, thumbnail_key, content_type, width, height
+		FROM product_images WHERE product_id = $1 AND checksum = $2`, productID, checksum,
+	).Scan(&img.ID, &img.Key, &img.ThumbnailKey, &img.ContentType, &img.Width, &img.Height)
+	if err == nil {
+		img.URL, img.ThumbnailURL = store.URL(img.Key), store.URL(img.ThumbnailKey)
+		return img, false, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, fmt.Errorf("failed to look up image: %v", err)
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, ErrInvalidImage
+	}
+	var thumb bytes.Buffer
+	if err := jpeg.Encode(&thumb, makeThumbnail(decoded, thumbnailSize), &jpeg.Options{Quality: 85}); err != nil {
+		return nil, false, fmt.Errorf("failed to encode thumbnail: %v", err)
+	}
+
+	// Keys are derived from the content, so storing the same image again
+	// overwrites identical files
+	contentType := imageContentTypes[format]
+	name := fmt.Sprintf("%s%d/%s", publicBlobPrefix, productID, checksum[:32])
+	img.Key, img.ThumbnailKey = name+"."+contentType[1], name+"_thumb.jpg"
+	img.ContentType, img.Width, img.Height = contentType[0], config.Width, config.Height
+	if err := store.Put(ctx, img.Key, data, img.ContentType); err != nil {
+		return nil, false, err
+	}
+	if err := store.Put(ctx, img.ThumbnailKey, thumb.Bytes(), "image/jpeg"); err != nil {
+		return nil, false, err
+	}
+
+	err = q.QueryRow(ctx, `
+		INSERT INTO product_images (product_id, blob_key, thumbnail_key, content_type, width, height, size_bytes, checksum, position)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8,
+			(SELECT COALESCE(MAX(position) + 1, 0) FROM product_images WHERE product_id = $1))
+		RETURNING id`,
+		productID, img.Key, img.ThumbnailKey, img.ContentType, img.Width, img.Height, len(data), checksum,
+	).Scan(&img.ID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to save image: %v", err)
+	}
+	img.URL, img.ThumbnailURL = store.URL(img.Key), store.URL(img.ThumbnailKey)
+	return img, true, nil
+}
+
+// DeleteImage removes an image from a product and deletes its files
+func (s *ImageService) DeleteImage(ctx context.Context, productID, imageID int64) error {
+	var key, thumbnailKey string
+	err := db.Pool.QueryRow(ctx, `
+		DELETE FROM product_images WHERE id = $1 AND product_id = $2
+		RETURNING blob_key, thumbnail_key`, imageID, productID,
+	).Scan(&key, &thumbnailKey)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrImageNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete image: %v", err)
+	}
+	// The row is gone, so a file left behind is only wasted space
+	if err := s.store.Delete(ctx, key); err != nil {
+		return err
+	}
+	return s.store.Delete(ctx, thumbnailKey)
+}
+
+// makeThumbnail scales img to fit in a size x size square, averaging the
+// source pixels under each thumbnail pixel. Transparency is flattened onto white,
+// since thumbnails are JPEG.
+func makeThumbnail(img image.Image, size int) *image.RGBA {
+	bounds := img.Bounds()
+	sw, sh := bounds.Dx(), bounds.Dy()
+	dw, dh := sw, sh
+	if sw > size || sh > size {
+		if sw >= sh {
+			dw, dh = size, sh*size/sw
+		} else {
+			dw, dh = sw*size/sh, size
+		}
+	}
+	dw, dh = max(dw, 1), max(dh, 1)
+
+	src := image.NewRGBA(image.Rect(0, 0, sw, sh))
+	draw.Draw(src, src.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Over)
+	if dw == sw && dh == sh {
+		return src
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0 := y * sh / dh
+		y1 := max((y+1)*sh/dh, y0+1)
+		for x := 0; x < dw; x++ {
+			x0 := x * sw / dw
+			x1 := max((x+1)*sw/dw, x0+1)
+			var r, g, b, n int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					r += int(row[sx*4])
+					g += int(row[sx*4+1])
+					b += int(row[sx*4+2])
+					n++
+				}
+			}
+			i := y*dst.Stride + x*4
+			dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = uint8(r/n), uint8(g/n), uint8(b/n), 255
+		}
+	}
+	return dst
+}
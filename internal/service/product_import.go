@@ -0,0 +1,891 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	textunicode "golang.org/x/text/encoding/unicode"
+)
+
+// Product fields a spreadsheet column can map to
+const (
+	importFieldName        = "name"
+	importFieldCategory    = "category"
+	importFieldPrice       = "price"
+	importFieldDescription = "description"
+	importFieldAvailable   = "is_available"
+	importFieldAttributes  = "attributes"
+	importFieldSKU         = "sku"
+	importFieldVariants    = "variants"
+	importFieldStock       = "stock"
+)
+
+// importHeaderAliases lists the accepted headers per field, in English and
+// Indonesian. Headers are compared after normalizeHeader.
+var importHeaderAliases = map[string][]string{
+	importFieldName:        {"name", "product name", "product", "nama", "nama produk", "produk", "nama barang", "barang", "item", "menu", "nama menu"},
+	importFieldCategory:    {"category", "kategori", "kategori produk", "jenis", "type", "tipe"},
+	importFieldPrice:       {"price", "unit price", "price idr", "price rp", "harga", "harga jual", "harga satuan", "harga rp", "harga idr"},
+	importFieldDescription: {"description", "desc", "details", "deskripsi", "keterangan", "detail", "penjelasan"},
+	importFieldAvailable:   {"available", "is available", "availability", "tersedia", "ketersediaan", "status", "ready"},
+	importFieldAttributes:  {"attributes", "attribute", "atribut", "spesifikasi"},
+	importFieldVariants:    {"variants", "variant", "varian", "pilihan", "opsi"},
+	importFieldStock:       {"stock", "stok", "qty", "quantity", "jumlah", "jumlah stok", "sisa stok", "persediaan", "inventory"},
+	importFieldSKU:         {"sku", "external id", "product code", "item code", "plu", "barcode", "kode", "kode produk", "kode barang", "kode item"},
+}
+
+// importHeaderScanRows is how many leading rows are searched for the header
+const importHeaderScanRows = 10
+
+// normalizeHeader lowercases a header and drops punctuation, so "Harga (Rp)"
+// and "harga rp" compare equal
+func normalizeHeader(header string) string {
+	fields := strings.FieldsFunc(strings.ToLower(header), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(fields, " ")
+}
+
+var importHeaderFields = func() map[string]string {
+	fields := map[string]string{}
+	for field, aliases := range importHeaderAliases {
+		for _, alias := range aliases {
+			fields[normalizeHeader(alias)] = field
+		}
+	}
+	return fields
+}()
+
+// mapImportHeader maps header cells to product fields by column index.
+// It returns nil unless the name and price columns are both present.
+func mapImportHeader(row []string) map[int]string {
+	columns := map[int]string{}
+	seen := map[string]bool{}
+	for i, cell := range row {
+		field, ok := importHeaderFields[normalizeHeader(cell)]
+		if !ok || seen[field] {
+			continue // The first matching column wins
+		}
+		columns[i] = field
+		seen[field] = true
+	}
+	if !seen[importFieldName] || !seen[importFieldPrice] {
+		return nil
+	}
+	return columns
+}
+
+// Catalog file formats accepted for import and produced by export
+const (
+	FormatXLSX = "xlsx"
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+var ErrImportTooLarge = errors.New("catalog file is too large")
+
+// importUnzipFactor bounds how far an XLSX file may expand when unzipped,
+// relative to the file size limit
+const importUnzipFactor = 20
+
+// ImportLimits returns the size of the largest catalog file accepted for
+// import, in bytes, and the most rows it may have. They are set by the
+// environment:
+//   - IMPORT_MAX_FILE_MB: file size limit (default 50)
+//   - IMPORT_MAX_ROWS: row limit, blank rows not counted (default 200000)
+func ImportLimits() (maxBytes int64, maxRows int) {
+	maxBytes, maxRows = 50<<20, 200_000
+	if mb, err := strconv.Atoi(os.Getenv("IMPORT_MAX_FILE_MB")); err == nil && mb > 0 {
+		maxBytes = int64(mb) << 20
+	}
+	if n, err := strconv.Atoi(os.Getenv("IMPORT_MAX_ROWS")); err == nil && n > 0 {
+		maxRows = n
+	}
+	return maxBytes, maxRows
+}
+
+// CheckImportSize returns ErrImportTooLarge for files over the size limit
+func CheckImportSize(size int64) error {
+	if maxBytes, _ := ImportLimits(); size > maxBytes {
+		return fmt.Errorf("%w: over %d MB", ErrImportTooLarge, maxBytes>>20)
+	}
+	return nil
+}
+
+// OpenCatalogWorkbook opens an uploaded XLSX catalog. Large sheets are
+// unzipped to temporary files rather than memory, and files that unzip to
+// far more than the size limit are refused.
+func OpenCatalogWorkbook(data []byte) (*excelize.File, error) {
+	if err := CheckImportSize(int64(len(data))); err != nil {
+		return nil, err
+	}
+	maxBytes, _ := ImportLimits()
+	xlsx, err := excelize.OpenReader(bytes.NewReader(data), excelize.Options{UnzipSizeLimit: maxBytes * importUnzipFactor})
+	if err != nil {
+		return nil, fmt.Errorf("invalid Excel file: %v", err)
+	}
+	return xlsx, nil
+}
+
+// ProductScanner reads the rows of a catalog file one at a time, so a file
+// is never held as a table in memory. The header has been found by the time
+// a scanner is returned; Report describes it and the counts so far.
+//
+//	scan, err := ScanProductFile(format, data, "")
+//	...
+//	defer scan.Close()
+//	for scan.Next() {
+//		row := scan.Row()
+//		...
+//	}
+//	if err := scan.Err(); err != nil {
+//		...
+//	}
+type ProductScanner struct {
+	table   *productTable
+	read    func() ([]string, bool, error) // The next raw row; false at the end
+	skip    func() (bool, error)           // Like read without the cells, when that's cheaper
+	finish  func()                         // Called once every row was read
+	close   func() error
+	maxRows int
+	row     *model.ImportRow
+	err     error
+	done    bool
+
+	// XLSX only
+	xlsx     *excelize.File
+	sheet    string
+	pictures map[int][]string // Row number -> cells with pictures, in sheet order
+}
+
+// Report describes how the file is read: its header and the counts of the
+// rows read so far. Its Rows are left empty.
+func (s *ProductScanner) Report() *model.ImportReport {
+	return s.table.report
+}
+
+// Next reads the next non-blank row below the header. It returns false at
+// the end of the file or when reading fails; Err tells which.
+func (s *ProductScanner) Next() bool {
+	s.row = nil
+	for s.err == nil && !s.done {
+		cells, ok, err := s.read()
+		if err != nil {
+			s.err = err
+			break
+		}
+		if !ok {
+			s.done = true
+			if s.finish != nil {
+				s.finish()
+			}
+			break
+		}
+		row := s.table.add(cells)
+		if row == nil {
+			continue
+		}
+		if report := s.table.report; report.Accepted+report.Rejected > s.maxRows {
+			s.err = fmt.Errorf("%w: more than %d rows", ErrImportTooLarge, s.maxRows)
+			break
+		}
+		if s.pictures != nil && row.Status == model.ImportRowAccepted {
+			if s.err = attachRowPictures(s.xlsx, s.sheet, s.pictures[row.Row], row); s.err != nil {
+				break
+			}
+		}
+		s.row = row
+		return true
+	}
+	return false
+}
+
+// Row returns the row read by the last call to Next
+func (s *ProductScanner) Row() *model.ImportRow {
+	return s.row
+}
+
+// Err returns the error that stopped Next, if any
+func (s *ProductScanner) Err() error {
+	return s.err
+}
+
+// Close releases the file. It must be called once the scanner is no longer used.
+func (s *ProductScanner) Close() error {
+	if s.close == nil {
+		return nil
+	}
+	return s.close()
+}
+
+// collect reads every remaining row into the report
+func (s *ProductScanner) collect() (*model.ImportReport, error) {
+	defer s.Close()
+	report := s.Report()
+	for s.Next() {
+		report.Rows = append(report.Rows, *s.Row())
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// ScanProductFile starts reading products from an uploaded catalog in the
+// given format, returning an error if it has no recognizable header. The
+// sheet name only applies to XLSX files.
+func ScanProductFile(format string, data []byte, sheet string) (*ProductScanner, error) {
+	if err := CheckImportSize(int64(len(data))); err != nil {
+		return nil, err
+	}
+	switch format {
+	case FormatXLSX:
+		xlsx, err := OpenCatalogWorkbook(data)
+		if err != nil {
+			return nil, err
+		}
+		scan, err := ScanProductSheet(xlsx, sheet)
+		if err != nil {
+			xlsx.Close()
+			return nil, err
+		}
+		closeRows := scan.close
+		scan.close = func() error {
+			closeRows()
+			return xlsx.Close()
+		}
+		return scan, nil
+	case FormatCSV:
+		return scanProductCSV(data)
+	case FormatJSON:
+		return scanProductJSON(data)
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+// ParseProductFile reads every product of an uploaded catalog in the given
+// format. The sheet name only applies to XLSX files.
+func ParseProductFile(format string, data []byte, sheet string) (*model.ImportReport, error) {
+	scan, err := ScanProductFile(format, data, sheet)
+	if err != nil {
+		return nil, err
+	}
+	return scan.collect()
+}
+
+// CountProductRows returns how many rows a catalog has below its header,
+// blank ones included, without validating them. It is much cheaper than
+// reading the rows, and is meant for reporting progress.
+func CountProductRows(format string, data []byte, sheet string) (int, error) {
+	scan, err := ScanProductFile(format, data, sheet)
+	if err != nil {
+		return 0, err
+	}
+	defer scan.Close()
+	skip := scan.skip
+	if skip == nil {
+		skip = func() (bool, error) {
+			_, ok, err := scan.read()
+			return ok, err
+		}
+	}
+	count := 0
+	for {
+		ok, err := skip()
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return count, nil
+		}
+		count++
+	}
+}
+
+// ScanProductSheet starts reading products from a sheet, mapping columns by
+// their header. An empty sheet name picks the first sheet with a
+// recognizable header. Every non-blank row below the header is validated and
+// reported as accepted or rejected; accepted rows carry the parsed product.
+// Rows are streamed from the sheet as they are read.
+func ScanProductSheet(xlsx *excelize.File, sheet string) (*ProductScanner, error) {
+	sheets := xlsx.GetSheetList()
+	if sheet != "" {
+		if idx, _ := xlsx.GetSheetIndex(sheet); idx < 0 {
+			return nil, fmt.Errorf("sheet %q not found; available sheets: %s", sheet, strings.Join(sheets, ", "))
+		}
+		sheets = []string{sheet}
+	}
+	_, maxRows := ImportLimits()
+
+	for _, name := range sheets {
+		rows, err := xlsx.Rows(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sheet %q: %v", name, err)
+		}
+		read := func() ([]string, bool, error) {
+			if !rows.Next() {
+				if err := rows.Error(); err != nil {
+					return nil, false, fmt.Errorf("failed to read sheet %q: %v", name, err)
+				}
+				return nil, false, nil
+			}
+			// Raw values, so numeric cells aren't run through the cell's display format
+			cells, err := rows.Columns(excelize.Options{RawCellValue: true})
+			if err != nil {
+				return nil, false, fmt.Errorf("failed to read sheet %q: %v", name, err)
+			}
+			return cells, true, nil
+		}
+
+		table, err := findProductHeader(read, 1)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if table == nil {
+			rows.Close()
+			continue
+		}
+		table.report.Format = FormatXLSX
+		table.report.Sheet = name
+		pictures, err := sheetPictureCells(xlsx, name)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		skip := func() (bool, error) {
+			if !rows.Next() {
+				return false, rows.Error()
+			}
+			return true, nil
+		}
+		return &ProductScanner{table: table, read: read, skip: skip, close: rows.Close, maxRows: maxRows,
+			xlsx: xlsx, sheet: name, pictures: pictures}, nil
+	}
+
+	if sheet != "" {
+		return nil, fmt.Errorf("no header row with name and price columns found in sheet %q (%s)", sheet, acceptedHeadersHint())
+	}
+	return nil, fmt.Errorf("no sheet has a header row with name and price columns (%s)", acceptedHeadersHint())
+}
+
+// sheetPictureCells maps row numbers to the cells of a sheet that hold
+// pictures. Looking pictures up loads the whole sheet into memory, so it is
+// only done when the workbook has any.
+func sheetPictureCells(xlsx *excelize.File, sheet string) (map[int][]string, error) {
+	hasMedia := false
+	xlsx.Pkg.Range(func(key, _ any) bool {
+		hasMedia = strings.HasPrefix(key.(string), "xl/media/")
+		return !hasMedia
+	})
+	if !hasMedia {
+		return nil, nil
+	}
+	cells, err := xlsx.GetPictureCells(sheet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pictures of sheet %q: %v", sheet, err)
+	}
+	if len(cells) == 0 {
+		return nil, nil
+	}
+	sort.Slice(cells, func(i, j int) bool { return cellLess(cells[i], cells[j]) })
+	rows := map[int][]string{}
+	for _, cell := range cells {
+		if _, row, err := excelize.CellNameToCoordinates(cell); err == nil {
+			rows[row] = append(rows[row], cell)
+		}
+	}
+	return rows, nil
+}
+
+// attachRowPictures gives an accepted row the pictures placed in its cells.
+// Pictures that can't be used are reported as warnings on the row.
+func attachRowPictures(xlsx *excelize.File, sheet string, cells []string, row *model.ImportRow) error {
+	for _, cell := range cells {
+		pictures, err := xlsx.GetPictures(sheet, cell)
+		if err != nil {
+			return fmt.Errorf("failed to read picture in %s: %v", cell, err)
+		}
+		for _, picture := range pictures {
+			if err := CheckImage(picture.File); err != nil {
+				row.Warnings = append(row.Warnings, fmt.Sprintf("picture in %s skipped: %v", cell, err))
+				continue
+			}
+			row.Images = append(row.Images, picture.File)
+		}
+	}
+	row.ImageCount = len(row.Images)
+	return nil
+}
+
+// cellLess orders cell names by row, then column, so pictures keep the
+// order they appear in on the sheet
+func cellLess(a, b string) bool {
+	ac, ar, _ := excelize.CellNameToCoordinates(a)
+	bc, br, _ := excelize.CellNameToCoordinates(b)
+	if ar != br {
+		return ar < br
+	}
+	return ac < bc
+}
+
+// scanProductCSV starts reading products from CSV text, detecting the
+// encoding and the delimiter. Columns are mapped by header like in
+// spreadsheets.
+func scanProductCSV(data []byte) (*ProductScanner, error) {
+	text, encoding, err := decodeCSVText(data)
+	if err != nil {
+		return nil, err
+	}
+	delimiter := detectCSVDelimiter(text)
+
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1 // Short rows are reported per row, not as a parse error
+	reader.LazyQuotes = true
+	reader.ReuseRecord = true // Rows are copied as they are validated
+	read := func() ([]string, bool, error) {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid CSV file: %v", err)
+		}
+		return record, true, nil
+	}
+
+	table, err := findProductHeader(read, 1)
+	if err != nil {
+		return nil, err
+	}
+	if table == nil {
+		return nil, fmt.Errorf("no header row with name and price columns found (%s)", acceptedHeadersHint())
+	}
+	table.report.Format = FormatCSV
+	table.report.Encoding = encoding
+	table.report.Delimiter = string(delimiter)
+	_, maxRows := ImportLimits()
+	return &ProductScanner{table: table, read: read, maxRows: maxRows}, nil
+}
+
+// scanProductJSON starts reading products from a JSON array of objects. Keys
+// are matched like spreadsheet headers, and rows are numbered from 1 by
+// their position in the array.
+func scanProductJSON(data []byte) (*ProductScanner, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return nil, fmt.Errorf("invalid JSON file, expected an array of products")
+	}
+
+	// Keys are mapped per object, since objects may use different aliases.
+	// The table built from them has one column per product field.
+	fields := ProductExportColumns
+	fieldIndex, fieldColumns := map[string]int{}, map[int]string{}
+	for i, field := range fields {
+		fieldIndex[field], fieldColumns[i] = i, field
+	}
+	table := newProductTable(0)
+	table.setHeader(fields, fieldColumns)
+	report := table.report
+	report.Format = FormatJSON
+	report.Columns = map[string]string{}
+
+	ignored := map[string]bool{}
+	read := func() ([]string, bool, error) {
+		if !decoder.More() {
+			return nil, false, nil
+		}
+		var item map[string]any
+		if err := decoder.Decode(&item); err != nil {
+			return nil, false, fmt.Errorf("invalid JSON file, expected an array of products: %v", err)
+		}
+		keys := make([]string, 0, len(item))
+		for key := range item {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys) // Map order is random; the first alias in sorted order wins
+
+		row := make([]string, len(fields))
+		set := map[string]bool{}
+		for _, key := range keys {
+			field, ok := importHeaderFields[normalizeHeader(key)]
+			if !ok || set[field] {
+				ignored[key] = true
+				continue
+			}
+			set[field] = true
+			report.Columns[key] = field
+			row[fieldIndex[field]] = jsonCellValue(item[key])
+		}
+		return row, true, nil
+	}
+	finish := func() {
+		report.Ignored = nil
+		for key := range ignored {
+			report.Ignored = append(report.Ignored, key)
+		}
+		sort.Strings(report.Ignored)
+	}
+	_, maxRows := ImportLimits()
+	return &ProductScanner{table: table, read: read, finish: finish, maxRows: maxRows}, nil
+}
+
+// jsonCellValue renders a JSON value the way it would appear in a spreadsheet
+// cell, so it goes through the same validation
+func jsonCellValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func acceptedHeadersHint() string {
+	return fmt.Sprintf("accepted headers: %s; %s",
+		strings.Join(importHeaderAliases[importFieldName], "/"),
+		strings.Join(importHeaderAliases[importFieldPrice], "/"))
+}
+
+// productTable validates a table one row at a time. Rows are numbered from
+// rowBase, as shown in the report.
+type productTable struct {
+	report  *model.ImportReport
+	columns map[int]string // Column index -> product field; nil until the header is found
+	rowBase int
+	index   int // Index of the next row
+}
+
+func newProductTable(rowBase int) *productTable {
+	return &productTable{
+		report:  &model.ImportReport{Columns: map[string]string{}, Rows: []model.ImportRow{}},
+		rowBase: rowBase,
+	}
+}
+
+// findProductHeader reads rows until it finds the header among the first
+// ones, so titles or notes above the table are tolerated. It returns nil if
+// there's no header.
+func findProductHeader(read func() ([]string, bool, error), rowBase int) (*productTable, error) {
+	table := newProductTable(rowBase)
+	for table.index < importHeaderScanRows {
+		cells, ok, err := read()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		if columns := mapImportHeader(cells); columns != nil {
+			table.setHeader(cells, columns)
+			return table, nil
+		}
+		table.index++
+	}
+	return nil, nil
+}
+
+// setHeader records the header as the row at the current index
+func (t *productTable) setHeader(cells []string, columns map[int]string) {
+	t.columns = columns
+	t.report.HeaderRow = t.index + t.rowBase
+	for i, cell := range cells {
+		if field, ok := columns[i]; ok {
+			t.report.Columns[cell] = field
+		} else if strings.TrimSpace(cell) != "" {
+			t.report.Ignored = append(t.report.Ignored, cell)
+		}
+	}
+	t.index++
+}
+
+// add validates the next row below the header and counts it in the report.
+// It returns nil for blank rows and the import template's example row.
+func (t *productTable) add(cells []string) *model.ImportRow {
+	i := t.index
+	t.index++
+	values := map[string]string{}
+	blank := true
+	for col, field := range t.columns {
+		if col < len(cells) {
+			values[field] = strings.TrimSpace(cells[col])
+			if values[field] != "" {
+				blank = false
+			}
+		}
+	}
+	if blank || isTemplateExample(values) {
+		t.report.Skipped++
+		return nil
+	}
+
+	row := &model.ImportRow{Row: i + t.rowBase, SKU: values[importFieldSKU]}
+	product, errs := parseImportProduct(values)
+	if len(errs) > 0 {
+		row.Status = model.ImportRowRejected
+		row.Errors = errs
+		t.report.Rejected++
+	} else {
+		row.Status = model.ImportRowAccepted
+		row.Product = product
+		t.report.Accepted++
+	}
+	return row
+}
+
+// parseImportProduct validates one row's values, returning every problem
+// found rather than just the first
+func parseImportProduct(values map[string]string) (*model.Product, []string) {
+	var errs []string
+	product := &model.Product{
+		SKU:         values[importFieldSKU],
+		Name:        values[importFieldName],
+		Category:    values[importFieldCategory],
+		Description: values[importFieldDescription],
+		IsAvailable: true,
+	}
+
+	if product.Name == "" {
+		errs = append(errs, "name is required")
+	} else if len([]rune(product.Name)) > 255 {
+		errs = append(errs, "name is longer than 255 characters")
+	}
+	if len([]rune(product.SKU)) > 100 {
+		errs = append(errs, "sku is longer than 100 characters")
+	}
+	if len([]rune(product.Category)) > 100 {
+		errs = append(errs, "category is longer than 100 characters")
+	}
+
+	if raw := values[importFieldPrice]; raw == "" {
+		errs = append(errs, "price is required")
+	} else if price, err := ParseIndonesianNumber(raw); err != nil {
+		errs = append(errs, fmt.Sprintf("price %q is not a number", raw))
+	} else if price > 99999999.99 {
+		errs = append(errs, fmt.Sprintf("price %q is too large", raw))
+	} else {
+		product.Price = price
+	}
+
+	// Blank stock, attributes and variants stay nil, so an upsert keeps the
+	// product's current ones
+	if raw := values[importFieldStock]; raw != "" {
+		if stock, err := ParseIndonesianNumber(raw); err != nil || stock != float64(int(stock)) {
+			errs = append(errs, fmt.Sprintf("stock %q is not a whole number", raw))
+		} else {
+			n := int(stock)
+			product.Stock = &n
+		}
+	}
+	if raw := values[importFieldAttributes]; raw != "" {
+		if err := json.Unmarshal([]byte(raw), &product.Attributes); err != nil || product.Attributes == nil {
+			errs = append(errs, "attributes must be a JSON object")
+		}
+	}
+	if raw := values[importFieldVariants]; raw != "" {
+		variants, err := parseImportVariants(raw)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+		product.Variants = variants
+	}
+
+	if raw := values[importFieldAvailable]; raw != "" {
+		available, err := parseImportBool(raw)
+		if err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			product.IsAvailable = available
+		}
+	}
+	return product, errs
+}
+
+// importVariant is a variant as written in an import file. Availability
+// defaults to true when it's left out.
+type importVariant struct {
+	Name        string            `json:"name"`
+	Options     map[string]string `json:"options"`
+	SKU         string            `json:"sku"`
+	Price       *float64          `json:"price"`
+	Stock       *int              `json:"stock"`
+	IsAvailable *bool             `json:"is_available"`
+}
+
+// parseImportVariants reads a JSON array of variants, as written by export
+func parseImportVariants(raw string) ([]model.ProductVariant, error) {
+	var items []importVariant
+	if err := json.Unmarshal([]byte(raw), &items); err != nil {
+		return nil, fmt.Errorf("variants must be a JSON array of {name, price, sku, stock, options}")
+	}
+	variants := make([]model.ProductVariant, len(items))
+	for i, item := range items {
+		if item.Price == nil {
+			return nil, fmt.Errorf("variant %q has no price", item.Name)
+		}
+		variants[i] = model.ProductVariant{
+			Name:        strings.TrimSpace(item.Name),
+			Options:     item.Options,
+			SKU:         strings.TrimSpace(item.SKU),
+			Price:       *item.Price,
+			Stock:       item.Stock,
+			IsAvailable: item.IsAvailable == nil || *item.IsAvailable,
+		}
+	}
+	if err := ValidateVariants(variants); err != nil {
+		return nil, err
+	}
+	return variants, nil
+}
+
+// parseImportBool reads yes/no style cells in English and Indonesian
+func parseImportBool(raw string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "1", "true", "yes", "y", "ya", "ada", "tersedia", "ready", "available":
+		return true, nil
+	case "0", "false", "no", "n", "tidak", "tdk", "habis", "kosong", "sold out", "unavailable":
+		return false, nil
+	}
+	return false, fmt.Errorf("availability %q is not yes/no (ya/tidak)", raw)
+}
+
+// ParseIndonesianNumber parses numbers written with Indonesian conventions,
+// where "." groups thousands and "," marks decimals ("25.000", "1.250,50"),
+// while still accepting "25,000" and "12.5". A single separator followed by
+// exactly three digits is read as a thousands separator. A leading "Rp" or
+// "IDR" and a trailing ",-" are ignored. Anything else but digits and
+// separators is rejected, so signs, exponents, "NaN" and "Inf" never parse.
+func ParseIndonesianNumber(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	lower := strings.ToLower(s)
+	for _, prefix := range []string{"rp.", "rp", "idr"} {
+		if strings.HasPrefix(lower, prefix) {
+			s, lower = s[len(prefix):], lower[len(prefix):]
+			break
+		}
+	}
+	s = strings.ReplaceAll(strings.TrimSpace(s), " ", "")
+	s = strings.TrimSuffix(strings.TrimSuffix(s, ",-"), ".-")
+	if s == "" {
+		return 0, fmt.Errorf("empty number")
+	}
+	if strings.Trim(s, "0123456789.,") != "" || strings.Trim(s, ".,") == "" {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+
+	lastDot, lastComma := strings.LastIndex(s, "."), strings.LastIndex(s, ",")
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		// Both present: whichever comes last is the decimal separator
+		if lastComma > lastDot {
+			s = strings.ReplaceAll(s, ".", "")
+			s = strings.Replace(s, ",", ".", 1)
+		} else {
+			s = strings.ReplaceAll(s, ",", "")
+		}
+	case lastDot >= 0:
+		s = normalizeSingleSeparator(s, ".")
+	case lastComma >= 0:
+		s = normalizeSingleSeparator(s, ",")
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return value, nil
+}
+
+// normalizeSingleSeparator resolves a number that uses only sep, treating it
+// as a thousands separator when it repeats or groups exactly three digits
+func normalizeSingleSeparator(s, sep string) string {
+	parts := strings.Split(s, sep)
+	if len(parts) > 2 || len(parts[1]) == 3 {
+		return strings.Join(parts, "")
+	}
+	return parts[0] + "." + parts[1]
+}
+
+// decodeCSVText converts CSV bytes to UTF-8 text. UTF-8 and UTF-16 are
+// recognized by their byte order mark; text without one that isn't valid
+// UTF-8 is assumed to be Windows-1252, which is what Excel and most POS
+// systems on Windows write. It returns the name of the detected encoding.
+func decodeCSVText(data []byte) (string, string, error) {
+	var enc encoding.Encoding
+	var name string
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return string(data[3:]), "utf-8", nil
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		enc, name = textunicode.UTF16(textunicode.LittleEndian, textunicode.ExpectBOM), "utf-16le"
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		enc, name = textunicode.UTF16(textunicode.BigEndian, textunicode.ExpectBOM), "utf-16be"
+	case utf8.Valid(data):
+		return string(data), "utf-8", nil
+	default:
+		enc, name = charmap.Windows1252, "windows-1252"
+	}
+	decoded, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decode %s CSV: %v", name, err)
+	}
+	return string(decoded), name, nil
+}
+
+// csvDelimiters are tried in order; ties go to the earlier one
+var csvDelimiters = []rune{',', ';', '\t', '|'}
+
+// detectCSVDelimiter picks the delimiter that splits the first lines into the
+// most columns, consistently. Regional Excel settings in Indonesia commonly
+// write ";", since "," is the decimal separator.
+func detectCSVDelimiter(text string) rune {
+	const sampleRows = 10
+	best, bestFields := csvDelimiters[0], 1
+	for _, delimiter := range csvDelimiters {
+		reader := csv.NewReader(strings.NewReader(text))
+		reader.Comma = delimiter
+		reader.FieldsPerRecord = -1
+		reader.LazyQuotes = true
+
+		fields, consistent := 0, true
+		for i := 0; i < sampleRows; i++ {
+			record, err := reader.Read()
+			if err != nil {
+				break // io.EOF, or a sample that doesn't parse with this delimiter
+			}
+			if i == 0 {
+				fields = len(record)
+			} else if len(record) != fields {
+				consistent = false
+				break
+			}
+		}
+		if consistent && fields > bestFields {
+			best, bestFields = delimiter, fields
+		}
+	}
+	return best
+}
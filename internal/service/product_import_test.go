@@ -0,0 +1,233 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseIndonesianNumber(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+	}{
+		{"25000", 25000},
+		{"25.000", 25000},
+		{"25.000,00", 25000},
+		{"1.250,50", 1250.5},
+		{"1.250.000", 1250000},
+		{"25,000", 25000},
+		{"25,000.00", 25000},
+		{"12.5", 12.5},
+		{"12,5", 12.5},
+		{"Rp 25.000", 25000},
+		{"Rp25.000", 25000},
+		{"Rp. 25.000,-", 25000},
+		{"IDR 1.500", 1500},
+		{"25.000,-", 25000},
+		{" 7 ", 7},
+		{"1 250 000", 1250000},
+	}
+	for _, tt := range tests {
+		got, err := ParseIndonesianNumber(tt.in)
+		if err != nil {
+			t.Errorf("ParseIndonesianNumber(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseIndonesianNumber(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "Rp", "gratis", "25.000 ribu", "12-5", "-25000", "+25000", ".", ",-",
+		"NaN", "nan", "Rp NaN", "Inf", "+Inf", "-Infinity", "1.5e3", "1e5", "1E5", "0x1p4", "25_000"} {
+		if got, err := ParseIndonesianNumber(in); err == nil {
+			t.Errorf("ParseIndonesianNumber(%q) = %v, want an error", in, got)
+		}
+	}
+}
+
+func TestNormalizeHeader(t *testing.T) {
+	tests := map[string]string{
+		"Harga (Rp)":      "harga rp",
+		"  NAMA  PRODUK ": "nama produk",
+		"Nama_Produk":     "nama produk",
+		"Is-Available?":   "is available",
+		"Kategori/Jenis":  "kategori jenis",
+		"Harga Jual 2":    "harga jual 2",
+		"":                "",
+	}
+	for in, want := range tests {
+		if got := normalizeHeader(in); got != want {
+			t.Errorf("normalizeHeader(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMapImportHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		row  []string
+		want map[int]string
+	}{
+		{
+			name: "english",
+			row:  []string{"Name", "Price", "Category", "SKU"},
+			want: map[int]string{0: importFieldName, 1: importFieldPrice, 2: importFieldCategory, 3: importFieldSKU},
+		},
+		{
+			name: "indonesian",
+			row:  []string{"Kode Barang", "Nama Produk", "Harga Jual", "Stok", "Tersedia"},
+			want: map[int]string{0: importFieldSKU, 1: importFieldName, 2: importFieldPrice, 3: importFieldStock, 4: importFieldAvailable},
+		},
+		{
+			name: "price aliases with punctuation",
+			row:  []string{"Menu", "Harga (Rp)"},
+			want: map[int]string{0: importFieldName, 1: importFieldPrice},
+		},
+		{
+			name: "price idr",
+			row:  []string{"Produk", "Price (IDR)"},
+			want: map[int]string{0: importFieldName, 1: importFieldPrice},
+		},
+		{
+			name: "first matching column wins and unknown columns are left out",
+			row:  []string{"Nama", "Catatan", "Harga", "Harga Satuan"},
+			want: map[int]string{0: importFieldName, 2: importFieldPrice},
+		},
+		{name: "no price column", row: []string{"Nama", "Kategori"}},
+		{name: "no name column", row: []string{"Harga", "Stok"}},
+		{name: "data row", row: []string{"Nasi Goreng", "25.000"}},
+		{name: "empty", row: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mapImportHeader(tt.row); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mapImportHeader(%q) = %v, want %v", tt.row, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestImportHeaderRow checks that titles and notes above the table are
+// skipped and rows are numbered as the seller sees them
+func TestImportHeaderRow(t *testing.T) {
+	tests := []struct {
+		name      string
+		csv       string
+		headerRow int
+		firstRow  int
+	}{
+		{"header first", "Nama,Harga\nNasi Goreng,25.000\n", 1, 2},
+		{"title above", "Daftar Menu Warung Bu Sri\nPer 1 Januari\nNama,Harga\nNasi Goreng,25.000\n", 3, 4},
+		{"notes that mention a column", "Harga sudah termasuk pajak,\nNama,Harga (Rp)\nNasi Goreng,25.000\n", 2, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := ParseProductFile(FormatCSV, []byte(tt.csv), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.HeaderRow != tt.headerRow {
+				t.Errorf("header row = %d, want %d", report.HeaderRow, tt.headerRow)
+			}
+			if len(report.Rows) != 1 || report.Rows[0].Row != tt.firstRow {
+				t.Fatalf("rows = %+v, want one at row %d", report.Rows, tt.firstRow)
+			}
+			if p := report.Rows[0].Product; p == nil || p.Name != "Nasi Goreng" || p.Price != 25000 {
+				t.Errorf("product = %+v, want Nasi Goreng at 25000", p)
+			}
+		})
+	}
+
+	var rows string
+	for i := 0; i < importHeaderScanRows; i++ {
+		rows += "catatan\n"
+	}
+	if _, err := ParseProductFile(FormatCSV, []byte(rows+"Nama,Harga\nNasi Goreng,25.000\n"), ""); err == nil {
+		t.Errorf("a header below the first %d rows was found, want an error", importHeaderScanRows)
+	}
+}
+
+func TestDecodeCSVText(t *testing.T) {
+	const text = "Nama;Harga\nCafé Latte;25.000\n"
+	utf16le := []byte{0xFF, 0xFE}
+	for _, r := range text {
+		utf16le = append(utf16le, byte(r), byte(r>>8))
+	}
+	utf16be := []byte{0xFE, 0xFF}
+	for _, r := range text {
+		utf16be = append(utf16be, byte(r>>8), byte(r))
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		encoding string
+	}{
+		{"utf-8", []byte(text), "utf-8"},
+		{"utf-8 with BOM", append([]byte{0xEF, 0xBB, 0xBF}, text...), "utf-8"},
+		{"utf-16le", utf16le, "utf-16le"},
+		{"utf-16be", utf16be, "utf-16be"},
+		{"windows-1252", []byte("Nama;Harga\nCaf\xe9 Latte;25.000\n"), "windows-1252"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, encoding, err := decodeCSVText(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != text {
+				t.Errorf("text = %q, want %q", got, text)
+			}
+			if encoding != tt.encoding {
+				t.Errorf("encoding = %q, want %q", encoding, tt.encoding)
+			}
+		})
+	}
+}
+
+func TestDetectCSVDelimiter(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want rune
+	}{
+		{"comma", "Nama,Harga,Stok\nNasi Goreng,25000,10\n", ','},
+		{"semicolon with decimal commas", "Nama;Harga;Stok\nNasi Goreng;25.000,00;10\nEs Teh;5.000,50;3\n", ';'},
+		{"semicolon with commas in the text", "Nama;Deskripsi;Harga\nNasi Goreng;Nasi, telur, ayam;25000\n", ';'},
+		{"comma with quoted semicolons", "Nama,Deskripsi,Harga\nNasi Goreng,\"Pedas; manis\",25000\n", ','},
+		{"tab", "Nama\tHarga\nNasi Goreng\t25000\n", '\t'},
+		{"pipe", "Nama|Harga\nNasi Goreng|25000\n", '|'},
+		{"single column", "Nama\nNasi Goreng\n", ','},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectCSVDelimiter(tt.text); got != tt.want {
+				t.Errorf("delimiter = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestScanProductCSV reads a file as Excel with Indonesian regional settings
+// writes it: Windows-1252, ";" delimited, with decimal commas
+func TestScanProductCSV(t *testing.T) {
+	data := []byte("Nama Produk;Harga (Rp);Stok\r\nCaf\xe9 Latte;25.000,00;10\r\nEs Teh;5.500;\r\n")
+	report, err := ParseProductFile(FormatCSV, data, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Encoding != "windows-1252" || report.Delimiter != ";" {
+		t.Errorf("read as %s with %q, want windows-1252 with \";\"", report.Encoding, report.Delimiter)
+	}
+	if report.Accepted != 2 {
+		t.Fatalf("accepted %d rows, want 2: %+v", report.Accepted, report.Rows)
+	}
+	first, second := report.Rows[0].Product, report.Rows[1].Product
+	if first.Name != "Café Latte" || first.Price != 25000 || first.Stock == nil || *first.Stock != 10 {
+		t.Errorf("first product = %+v", first)
+	}
+	if second.Name != "Es Teh" || second.Price != 5500 || second.Stock != nil {
+		t.Errorf("second product = %+v", second)
+	}
+}
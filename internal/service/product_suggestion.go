@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/divinecoid/oneagent/internal/db"
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrSuggestionNotFound = errors.New("suggestion not found")
+	ErrSuggestionReviewed = errors.New("suggestion was already reviewed")
+	ErrSuggestionStale    = errors.New("product changed since the suggestion was written")
+	ErrNothingSuggested   = errors.New("none of the fields were suggested")
+)
+
+const suggestionColumns = `s.id, s.product_id, p.name, s.seller_id, s.job_id, s.model, s.suggested, s.original,
+	s.status, s.applied_fields, s.reviewed_by, s.reviewed_at, s.created_at`
+
+func scanSuggestion(row pgx.Row) (*model.ProductSuggestion, error) {
+	var s model.ProductSuggestion
+	err := row.Scan(&s.ID, &s.ProductID, &s.ProductName, &s.SellerID, &s.JobID, &s.Model, &s.Suggested, &s.Original,
+		&s.Status, &s.AppliedFields, &s.ReviewedBy, &s.ReviewedAt, &s.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// CheckSellerProducts reports ErrProductNotFound, naming the IDs, unless
+// every product ID is a live product of the seller
+func (s *ProductService) CheckSellerProducts(ctx context.Context, sellerID int64, ids []int64) error {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT id FROM products WHERE id = ANY($1) AND seller_id = $2 AND deleted_at IS NULL`, ids, sellerID)
+	if err != nil {
+		return fmt.Errorf("failed to check products: %v", err)
+	}
+	found, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return fmt.Errorf("failed to check products: %v", err)
+	}
+	var missing []int64
+	for _, id := range ids {
+		if !slices.Contains(found, id) {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %v", ErrProductNotFound, missing)
+	}
+	return nil
+}
+
+// SaveSuggestion stores a pending suggestion for a product, replacing the
+// one it already had pending. sg.ID and sg.CreatedAt are set.
+func (s *ProductService) SaveSuggestion(ctx context.Context, sg *model.ProductSuggestion) error {
+	sg.Status = model.SuggestionPending
+	err := db.Pool.QueryRow(ctx, `
+		INSERT INTO product_suggestions (product_id, seller_id, job_id, model, suggested, original)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (product_id) WHERE status = 'pending' DO UPDATE
+		SET job_id = EXCLUDED.job_id, model = EXCLUDED.model, suggested = EXCLUDED.suggested,
+			original = EXCLUDED.original, created_at = NOW()
+		RETURNING id, created_at`,
+		sg.ProductID, sg.SellerID, sg.JobID, sg.Model, sg.Suggested, sg.Original,
+	).Scan(&sg.ID, &sg.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save suggestion: %v", err)
+	}
+	return nil
+}
+
+// JobSuggestions returns the IDs of products that have a pending suggestion
+// written by a job, so a retried job can skip them
+func (s *ProductService) JobSuggestions(ctx context.Context, jobID int64) ([]int64, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT product_id FROM product_suggestions WHERE job_id = $1 AND status = 'pending'`, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list job suggestions: %v", err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list job suggestions: %v", err)
+	}
+	return ids, nil
+}
+
+// ListSuggestions returns a seller's suggestions with the given status,
+// newest first, and how many there are in total
+func (s *ProductService) ListSuggestions(ctx context.Context, sellerID int64, status string, limit, offset int) ([]model.ProductSuggestion, int, error) {
+	var total int
+	err := db.Pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM product_suggestions WHERE seller_id = $1 AND status = $2`, sellerID, status).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count suggestions: %v", err)
+	}
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+suggestionColumns+`
+		FROM product_suggestions s
+		JOIN products p ON p.id = s.product_id
+		WHERE s.seller_id = $1 AND s.status = $2
+		ORDER BY s.created_at DESC, s.id DESC
+		LIMIT $3 OFFSET $4`, sellerID, status, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list suggestions: %v", err)
+	}
+	defer rows.Close()
+
+	suggestions := []model.ProductSuggestion{}
+	for rows.Next() {
+		sg, err := scanSuggestion(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan suggestion: %v", err)
+		}
+		suggestions = append(suggestions, *sg)
+	}
+	return suggestions, total, rows.Err()
+}
+
+// GetSuggestion returns a suggestion
+func (s *ProductService) GetSuggestion(ctx context.Context, id int64) (*model.ProductSuggestion, error) {
+	sg, err := scanSuggestion(db.Pool.QueryRow(ctx, `
+		SELECT `+suggestionColumns+`
+		FROM product_suggestions s
+		JOIN products p ON p.id = s.product_id
+		WHERE s.id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSuggestionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get suggestion: %v", err)
+	}
+	return sg, nil
+}
+
+// ApproveSuggestion copies the given fields of a pending suggestion to its
+// product, or every suggested field when fields is empty, and returns the
+// updated product. A field the seller edited since the suggestion was
+// written is reported as ErrSuggestionStale rather than overwritten. The
+// product is saved like an edit by userID, so a new description or category
+// gets it re-embedded.
+func (s *ProductService) ApproveSuggestion(ctx context.Context, id int64, fields []string, userID int64) (*model.Product, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	sg, err := lockPendingSuggestion(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	p, err := scanProduct(tx.QueryRow(ctx, `
+		SELECT `+productColumns+`
+		FROM products
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE`, sg.ProductID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product: %v", err)
+	}
+
+	if len(fields) == 0 {
+		fields = model.EnrichFields
+	}
+	current := p.Content()
+	var applied []string
+	for _, field := range fields {
+		switch {
+		case field == model.EnrichDescription && sg.Suggested.Description != nil:
+			if *current.Description != derefString(sg.Original.Description) {
+				return nil, fmt.Errorf("%w: description", ErrSuggestionStale)
+			}
+			p.Description = *sg.Suggested.Description
+		case field == model.EnrichCategory && sg.Suggested.Category != nil:
+			if *current.Category != derefString(sg.Original.Category) {
+				return nil, fmt.Errorf("%w: category", ErrSuggestionStale)
+			}
+			p.Category, p.CategoryID = *sg.Suggested.Category, nil // Matched against the tree again
+		case field == model.EnrichAttributes && sg.Suggested.Attributes != nil:
+			if len(current.Attributes) != 0 || len(sg.Original.Attributes) != 0 {
+				if !reflect.DeepEqual(current.Attributes, sg.Original.Attributes) {
+					return nil, fmt.Errorf("%w: attributes", ErrSuggestionStale)
+				}
+			}
+			p.Attributes = sg.Suggested.Attributes
+		default:
+			continue
+		}
+		applied = append(applied, field)
+	}
+	if len(applied) == 0 {
+		return nil, ErrNothingSuggested
+	}
+
+	if err := updateProduct(ctx, tx, p, StockSource{Reason: model.StockReasonAdjustment, UserID: userID}); err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE product_suggestions
+		SET status = $2, applied_fields = $3, reviewed_by = $4, reviewed_at = NOW()
+		WHERE id = $1`, id, model.SuggestionApproved, applied, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to approve suggestion: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit suggestion: %v", err)
+	}
+	return p, nil
+}
+
+// RejectSuggestion discards a pending suggestion, leaving its product as it is
+func (s *ProductService) RejectSuggestion(ctx context.Context, id, userID int64) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := lockPendingSuggestion(ctx, tx, id); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE product_suggestions SET status = $2, reviewed_by = $3, reviewed_at = NOW()
+		WHERE id = $1`, id, model.SuggestionRejected, userID)
+	if err != nil {
+		return fmt.Errorf("failed to reject suggestion: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit suggestion: %v", err)
+	}
+	return nil
+}
+
+// lockPendingSuggestion locks a suggestion that is still waiting for review
+func lockPendingSuggestion(ctx context.Context, tx pgx.Tx, id int64) (*model.ProductSuggestion, error) {
+	sg, err := scanSuggestion(tx.QueryRow(ctx, `
+		SELECT `+suggestionColumns+`
+		FROM product_suggestions s
+		JOIN products p ON p.id = s.product_id
+		WHERE s.id = $1
+		FOR UPDATE OF s`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSuggestionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get suggestion: %v", err)
+	}
+	if sg.Status != model.SuggestionPending {
+		return nil, ErrSuggestionReviewed
+	}
+	return sg, nil
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,447 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/divinecoid/oneagent/internal/db"
+	"github.com/xuri/excelize/v2"
+)
+
+// Sheets of the XLSX import template. The product sheet comes first, so the
+// importer finds its header before anything else.
+const (
+	templateProductSheet      = "Produk"
+	templateInstructionSheet  = "Petunjuk"
+	templateCategorySheet     = "Kategori"
+	templateValidatedRows     = 1000 // Rows below the header that get dropdowns and formats
+	templateDefaultCategory   = "Makanan"
+	templatePriceNumberFormat = 3 // Built-in "#,##0"
+)
+
+// templateColumn documents a column of the import template, in Indonesian
+type templateColumn struct {
+	Required string
+	Notes    string
+	Example  string
+}
+
+// templateColumns has an entry for every column of ProductExportColumns;
+// generating the template fails when one is missing
+var templateColumns = map[string]templateColumn{
+	importFieldSKU: {
+		Required: "Untuk upsert/replace",
+		Notes:    "Kode produk dari toko atau sistem kasir (POS), unik per produk. Dipakai untuk mencocokkan produk saat impor ulang; wajib untuk mode upsert dan replace.",
+		Example:  "CONTOH-001", // Marks the example row, which the importer skips
+	},
+	importFieldName: {
+		Required: "Ya",
+		Notes:    "Nama produk, maksimal 255 karakter.",
+		Example:  "Nasi Goreng Spesial",
+	},
+	importFieldCategory: {
+		Required: "Tidak",
+		Notes:    "Kategori produk. Pilih dari daftar atau ketik kategori baru; kategori yang belum ada dibuat otomatis.",
+	},
+	importFieldPrice: {
+		Required: "Ya",
+		Notes:    "Harga jual dalam Rupiah, misalnya 25000. Penulisan 25.000, 25.000,00 atau Rp 25.000 juga diterima.",
+		Example:  "25000",
+	},
+	importFieldDescription: {
+		Required: "Tidak",
+		Notes:    "Deskripsi produk, dipakai untuk pencarian dan jawaban chat.",
+		Example:  "Nasi goreng dengan telur, ayam suwir dan kerupuk",
+	},
+	importFieldAvailable: {
+		Required: "Tidak",
+		Notes:    "Apakah produk sedang dijual: ya atau tidak. Kosong berarti ya.",
+		Example:  "ya",
+	},
+	importFieldStock: {
+		Required: "Tidak",
+		Notes:    "Jumlah stok, bilangan bulat. Kosongkan jika stok tidak dicatat. Produk dengan varian mencatat stok per varian.",
+		Example:  "50",
+	},
+	importFieldAttributes: {
+		Required: "Tidak",
+		Notes:    `Atribut tambahan untuk filter pencarian, dalam format JSON objek, misalnya {"pedas": "sedang"}.`,
+		Example:  `{"pedas": "sedang"}`,
+	},
+	importFieldVariants: {
+		Required: "Tidak",
+		Notes:    `Varian dalam format JSON: daftar {name, price, sku, stock, options}; name dan price wajib, misalnya [{"name": "Jumbo", "price": 32000}].`,
+		Example:  `[{"name": "Reguler", "price": 25000}, {"name": "Jumbo", "price": 32000}]`,
+	},
+}
+
+// templateInstructions are the general notes above the column table
+var templateInstructions = []string{
+	"Isi data produk di sheet " + templateProductSheet + ", satu baris per produk, mulai dari baris 2.",
+	"Jangan mengubah atau menghapus baris judul kolom. Urutan kolom boleh diubah.",
+	"Baris 2 berisi contoh dan tidak ikut diimpor selama sku dan name-nya tidak diubah. Boleh ditimpa dengan produk Anda atau dihapus.",
+	"Kolom wajib: name dan price. Kolom lain boleh dikosongkan.",
+	"Saat impor ulang dengan mode upsert atau replace, produk dicocokkan lewat sku. Sel stock, attributes dan variants yang kosong tidak mengubah data yang sudah ada.",
+	"Mode replace menonaktifkan produk yang tidak ada di file.",
+	"Gambar produk bisa ditempel di dalam sel pada baris produknya (hanya file XLSX).",
+	"Unggah dengan dry_run=true terlebih dulu untuk memeriksa hasil tanpa menyimpan apa pun.",
+}
+
+// WriteImportTemplate writes an empty catalog in the given format (xlsx or
+// csv) with the header the importer reads and an example row, which the
+// importer skips while it is left as it is. The XLSX
+// template also has dropdowns of the seller's categories, a number format
+// for prices and a sheet of instructions. The template is read back with the
+// importer before it is written, so it can't drift from what is accepted.
+func (s *ProductService) WriteImportTemplate(ctx context.Context, w io.Writer, format string, sellerID int64) error {
+	categories, err := templateCategories(ctx, sellerID)
+	if err != nil {
+		return err
+	}
+	example := templateExample(categories)
+
+	var buf bytes.Buffer
+	switch format {
+	case FormatXLSX:
+		err = writeTemplateXLSX(&buf, categories, example)
+	case FormatCSV:
+		err = writeTemplateCSV(&buf, example)
+	default:
+		err = fmt.Errorf("unsupported format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := checkImportTemplate(format, buf.Bytes(), example); err != nil {
+		return err
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// checkImportTemplate reads a template back with the importer. Every column
+// must be mapped and the example row skipped, and the example must be a
+// product the importer would accept once a seller edits it.
+func checkImportTemplate(format string, data []byte, example []string) error {
+	report, err := ParseProductFile(format, data, "")
+	if err != nil {
+		return fmt.Errorf("import template is not readable by the importer: %v", err)
+	}
+	// Formatted but empty XLSX rows are skipped too, so the example is only
+	// known to be among the skipped rows
+	if len(report.Columns) != len(ProductExportColumns) || len(report.Rows) != 0 || report.Skipped == 0 {
+		return fmt.Errorf("import template doesn't match the importer: %d of %d columns mapped, %d rows imported",
+			len(report.Columns), len(ProductExportColumns), len(report.Rows))
+	}
+	values := map[string]string{}
+	for i, field := range ProductExportColumns {
+		values[field] = example[i]
+	}
+	if _, errs := parseImportProduct(values); len(errs) > 0 {
+		return fmt.Errorf("import template example is rejected by the importer: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// isTemplateExample reports whether a row's values are the template's
+// example row, left in by a seller who didn't remove it. A row whose SKU or
+// name was edited is a real product.
+func isTemplateExample(values map[string]string) bool {
+	return values[importFieldSKU] == templateColumns[importFieldSKU].Example &&
+		values[importFieldName] == templateColumns[importFieldName].Example
+}
+
+// templateCategories returns the distinct category names of a seller's tree
+func templateCategories(ctx context.Context, sellerID int64) ([]string, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT DISTINCT name FROM categories WHERE seller_id = $1 ORDER BY name`, sellerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list categories: %v", err)
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan category: %v", err)
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// templateExample returns the example row in ProductExportColumns order. It
+// uses one of the seller's own categories when it has any.
+func templateExample(categories []string) []string {
+	row := make([]string, len(ProductExportColumns))
+	for i, field := range ProductExportColumns {
+		row[i] = templateColumns[field].Example
+		if field == importFieldCategory {
+			row[i] = templateDefaultCategory
+			if len(categories) > 0 {
+				row[i] = categories[0]
+			}
+		}
+	}
+	return row
+}
+
+func writeTemplateCSV(w io.Writer, example []string) error {
+	// A byte order mark makes Excel open the file as UTF-8
+	if _, err := w.Write([]byte{0xEF, 0xBB, 0xBF}); err != nil {
+		return err
+	}
+	writer := csv.NewWriter(w)
+	if err := writer.Write(ProductExportColumns); err != nil {
+		return fmt.Errorf("failed to write CSV: %v", err)
+	}
+	if err := writer.Write(example); err != nil {
+		return fmt.Errorf("failed to write CSV: %v", err)
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeTemplateXLSX(w io.Writer, categories, example []string) error {
+	xlsx := excelize.NewFile()
+	defer xlsx.Close()
+	if err := xlsx.SetSheetName("Sheet1", templateProductSheet); err != nil {
+		return fmt.Errorf("failed to create sheet: %v", err)
+	}
+	if err := writeTemplateProducts(xlsx, categories, example); err != nil {
+		return err
+	}
+	if err := writeTemplateInstructions(xlsx); err != nil {
+		return err
+	}
+	if len(categories) > 0 {
+		// Kept on a hidden sheet, since a typed-in list is limited to 255 characters
+		if _, err := xlsx.NewSheet(templateCategorySheet); err != nil {
+			return fmt.Errorf("failed to create sheet: %v", err)
+		}
+		for i, name := range categories {
+			cell, _ := excelize.CoordinatesToCellName(1, i+1)
+			if err := xlsx.SetCellStr(templateCategorySheet, cell, name); err != nil {
+				return fmt.Errorf("failed to write categories: %v", err)
+			}
+		}
+		if err := xlsx.SetSheetVisible(templateCategorySheet, false); err != nil {
+			return fmt.Errorf("failed to hide categories: %v", err)
+		}
+	}
+	xlsx.SetActiveSheet(0)
+	if _, err := xlsx.WriteTo(w); err != nil {
+		return fmt.Errorf("failed to write workbook: %v", err)
+	}
+	return nil
+}
+
+// writeTemplateProducts fills in the product sheet: header, example row,
+// formats and validations
+func writeTemplateProducts(xlsx *excelize.File, categories, example []string) error {
+	sheet := templateProductSheet
+	header := make([]any, len(ProductExportColumns))
+	row := make([]any, len(ProductExportColumns))
+	column := map[string]string{} // field -> column letter
+	for i, field := range ProductExportColumns {
+		if _, ok := templateColumns[field]; !ok {
+			return fmt.Errorf("no template notes for column %q", field)
+		}
+		header[i], row[i] = field, example[i]
+		column[field], _ = excelize.ColumnNumberToName(i + 1)
+	}
+	// Numbers stay numeric, like in exports
+	for i, field := range ProductExportColumns {
+		switch field {
+		case importFieldPrice:
+			price, _ := ParseIndonesianNumber(example[i])
+			row[i] = price
+		case importFieldStock:
+			stock, _ := ParseIndonesianNumber(example[i])
+			row[i] = int(stock)
+		}
+	}
+	if err := xlsx.SetSheetRow(sheet, "A1", &header); err != nil {
+		return fmt.Errorf("failed to write header: %v", err)
+	}
+	if err := xlsx.SetSheetRow(sheet, "A2", &row); err != nil {
+		return fmt.Errorf("failed to write example: %v", err)
+	}
+
+	last := len(ProductExportColumns)
+	lastColumn, _ := excelize.ColumnNumberToName(last)
+	headerStyle, err := xlsx.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"#DDEBF7"}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create style: %v", err)
+	}
+	exampleStyle, err := xlsx.NewStyle(&excelize.Style{Font: &excelize.Font{Italic: true, Color: "#808080"}})
+	if err != nil {
+		return fmt.Errorf("failed to create style: %v", err)
+	}
+	examplePriceStyle, err := xlsx.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Italic: true, Color: "#808080"}, NumFmt: templatePriceNumberFormat,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create style: %v", err)
+	}
+	priceStyle, err := xlsx.NewStyle(&excelize.Style{NumFmt: templatePriceNumberFormat})
+	if err != nil {
+		return fmt.Errorf("failed to create style: %v", err)
+	}
+
+	lastRow := templateValidatedRows + 1
+	priceColumn := column[importFieldPrice]
+	styles := []struct {
+		from, to string
+		style    int
+	}{
+		{"A1", lastColumn + "1", headerStyle},
+		{"A2", lastColumn + "2", exampleStyle},
+		{priceColumn + "3", fmt.Sprintf("%s%d", priceColumn, lastRow), priceStyle},
+		{priceColumn + "2", priceColumn + "2", examplePriceStyle},
+	}
+	for _, s := range styles {
+		if err := xlsx.SetCellStyle(sheet, s.from, s.to, s.style); err != nil {
+			return fmt.Errorf("failed to set style: %v", err)
+		}
+	}
+	if err := xlsx.SetColWidth(sheet, "A", lastColumn, 18); err != nil {
+		return fmt.Errorf("failed to set column width: %v", err)
+	}
+	for _, field := range []string{importFieldName, importFieldDescription, importFieldAttributes, importFieldVariants} {
+		if err := xlsx.SetColWidth(sheet, column[field], column[field], 32); err != nil {
+			return fmt.Errorf("failed to set column width: %v", err)
+		}
+	}
+	if err := xlsx.SetPanes(sheet, &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+		return fmt.Errorf("failed to freeze header: %v", err)
+	}
+
+	// Validations only warn, so values the importer reads in other ways (such
+	// as "Rp 25.000" or a new category) can still be entered
+	rangeOf := func(field string) string {
+		return fmt.Sprintf("%s2:%s%d", column[field], column[field], lastRow)
+	}
+	var validations []*excelize.DataValidation
+
+	dv := excelize.NewDataValidation(true)
+	dv.Sqref = rangeOf(importFieldPrice)
+	if err := dv.SetRange(0, 99999999.99, excelize.DataValidationTypeDecimal, excelize.DataValidationOperatorBetween); err != nil {
+		return fmt.Errorf("failed to add validation: %v", err)
+	}
+	dv.SetError(excelize.DataValidationErrorStyleWarning, "Harga tidak valid", "Harga harus berupa angka antara 0 dan 99.999.999,99.")
+	validations = append(validations, dv)
+
+	dv = excelize.NewDataValidation(true)
+	dv.Sqref = rangeOf(importFieldStock)
+	if err := dv.SetRange(0, 2147483647, excelize.DataValidationTypeWhole, excelize.DataValidationOperatorBetween); err != nil {
+		return fmt.Errorf("failed to add validation: %v", err)
+	}
+	dv.SetError(excelize.DataValidationErrorStyleWarning, "Stok tidak valid", "Stok harus berupa bilangan bulat 0 atau lebih.")
+	validations = append(validations, dv)
+
+	dv = excelize.NewDataValidation(true)
+	dv.Sqref = rangeOf(importFieldAvailable)
+	if err := dv.SetDropList([]string{"ya", "tidak"}); err != nil {
+		return fmt.Errorf("failed to add validation: %v", err)
+	}
+	dv.SetError(excelize.DataValidationErrorStyleWarning, "Ketersediaan", "Isi dengan ya atau tidak.")
+	validations = append(validations, dv)
+
+	if len(categories) > 0 {
+		dv = excelize.NewDataValidation(true)
+		dv.Sqref = rangeOf(importFieldCategory)
+		dv.SetSqrefDropList(fmt.Sprintf("%s!$A$1:$A$%d", templateCategorySheet, len(categories)))
+		dv.SetError(excelize.DataValidationErrorStyleInformation, "Kategori baru",
+			"Kategori ini belum ada dan akan dibuat saat impor.")
+		validations = append(validations, dv)
+	}
+
+	for _, dv := range validations {
+		if err := xlsx.AddDataValidation(sheet, dv); err != nil {
+			return fmt.Errorf("failed to add validation: %v", err)
+		}
+	}
+	return nil
+}
+
+// writeTemplateInstructions adds the instructions sheet: general notes, then
+// a table of the columns with the other headers each accepts
+func writeTemplateInstructions(xlsx *excelize.File) error {
+	sheet := templateInstructionSheet
+	if _, err := xlsx.NewSheet(sheet); err != nil {
+		return fmt.Errorf("failed to create sheet: %v", err)
+	}
+
+	rows := [][]any{{"Petunjuk Impor Produk"}, {}}
+	for _, line := range templateInstructions {
+		rows = append(rows, []any{"• " + line})
+	}
+	rows = append(rows, []any{})
+	tableRow := len(rows) + 1
+	rows = append(rows, []any{"Kolom", "Wajib diisi", "Penjelasan", "Contoh", "Judul kolom lain yang diterima"})
+	for _, field := range ProductExportColumns {
+		col := templateColumns[field]
+		var aliases []string
+		for _, alias := range importHeaderAliases[field] {
+			if alias != field {
+				aliases = append(aliases, alias)
+			}
+		}
+		example := col.Example
+		if field == importFieldCategory {
+			example = templateDefaultCategory
+		}
+		rows = append(rows, []any{field, col.Required, col.Notes, example, strings.Join(aliases, ", ")})
+	}
+
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := xlsx.SetSheetRow(sheet, cell, &row); err != nil {
+			return fmt.Errorf("failed to write instructions: %v", err)
+		}
+	}
+
+	titleStyle, err := xlsx.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true, Size: 14}})
+	if err != nil {
+		return fmt.Errorf("failed to create style: %v", err)
+	}
+	headerStyle, err := xlsx.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"#DDEBF7"}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create style: %v", err)
+	}
+	wrapStyle, err := xlsx.NewStyle(&excelize.Style{Alignment: &excelize.Alignment{WrapText: true, Vertical: "top"}})
+	if err != nil {
+		return fmt.Errorf("failed to create style: %v", err)
+	}
+	if err := xlsx.SetCellStyle(sheet, "A1", "A1", titleStyle); err != nil {
+		return fmt.Errorf("failed to set style: %v", err)
+	}
+	if err := xlsx.SetCellStyle(sheet, fmt.Sprintf("A%d", tableRow), fmt.Sprintf("E%d", tableRow), headerStyle); err != nil {
+		return fmt.Errorf("failed to set style: %v", err)
+	}
+	if err := xlsx.SetCellStyle(sheet, fmt.Sprintf("A%d", tableRow+1), fmt.Sprintf("E%d", len(rows)), wrapStyle); err != nil {
+		return fmt.Errorf("failed to set style: %v", err)
+	}
+	for _, width := range []struct {
+		col   string
+		width float64
+	}{{"A", 16}, {"B", 20}, {"C", 60}, {"D", 36}, {"E", 40}} {
+		if err := xlsx.SetColWidth(sheet, width.col, width.col, width.width); err != nil {
+			return fmt.Errorf("failed to set column width: %v", err)
+		}
+	}
+	return nil
+}
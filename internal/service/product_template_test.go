@@ -0,0 +1,99 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+)
+
+// TestImportTemplateReadsBack generates both templates, with and without the
+// seller's categories, and reads them with the importer: every column maps
+// and the untouched example row imports nothing
+func TestImportTemplateReadsBack(t *testing.T) {
+	for _, categories := range [][]string{nil, {"Minuman", "Nasi"}} {
+		example := templateExample(categories)
+		for _, format := range []string{FormatXLSX, FormatCSV} {
+			t.Run(format, func(t *testing.T) {
+				var buf bytes.Buffer
+				var err error
+				if format == FormatXLSX {
+					err = writeTemplateXLSX(&buf, categories, example)
+				} else {
+					err = writeTemplateCSV(&buf, example)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := checkImportTemplate(format, buf.Bytes(), example); err != nil {
+					t.Fatal(err)
+				}
+
+				scan, err := ScanProductFile(format, buf.Bytes(), "")
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer scan.Close()
+				for scan.Next() {
+					t.Errorf("row %d was imported: %+v", scan.Row().Row, scan.Row().Product)
+				}
+				if err := scan.Err(); err != nil {
+					t.Fatal(err)
+				}
+				report := scan.Report()
+				if report.HeaderRow != 1 || report.Skipped == 0 || len(report.Ignored) != 0 {
+					t.Errorf("header row %d, skipped %d, ignored %v; want header row 1, the example skipped and nothing ignored",
+						report.HeaderRow, report.Skipped, report.Ignored)
+				}
+				for _, field := range ProductExportColumns {
+					if report.Columns[field] != field {
+						t.Errorf("column %q maps to %q", field, report.Columns[field])
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestImportTemplateEditedExample checks that a seller who types over the
+// example row gets their product imported
+func TestImportTemplateEditedExample(t *testing.T) {
+	example := templateExample(nil)
+	var buf bytes.Buffer
+	if err := writeTemplateCSV(&buf, example); err != nil {
+		t.Fatal(err)
+	}
+	edited := append([]string(nil), example...)
+	for i, field := range ProductExportColumns {
+		if field == importFieldName {
+			edited[i] = "Mie Goreng Jawa"
+		}
+	}
+	writer := csv.NewWriter(&buf)
+	writer.Write(edited)
+	writer.Flush()
+
+	report, err := ParseProductFile(FormatCSV, buf.Bytes(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Skipped != 1 || report.Accepted != 1 {
+		t.Fatalf("skipped %d, accepted %d; want the example skipped and the edited row accepted", report.Skipped, report.Accepted)
+	}
+	if p := report.Rows[0].Product; p.Name != "Mie Goreng Jawa" || p.Price != 25000 || len(p.Variants) != 2 {
+		t.Errorf("product = %+v", p)
+	}
+}
+
+func TestTemplateColumnsCoverExport(t *testing.T) {
+	for _, field := range ProductExportColumns {
+		if _, ok := templateColumns[field]; !ok {
+			t.Errorf("no template notes for column %q", field)
+		}
+	}
+	for _, line := range templateInstructions {
+		if strings.TrimSpace(line) == "" {
+			t.Error("blank instruction line")
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/divinecoid/oneagent/internal/db"
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// ProductImport applies imported rows to a seller's catalog inside one
+// transaction, so an import either lands completely or not at all. Rows run
+// in savepoints, so a failing row doesn't abort the others.
+type ProductImport struct {
+	tx       pgx.Tx
+	sellerID int64
+	mode     string
+	skus     map[string]bool // SKUs seen in the file
+	src      StockSource
+	batch    *model.ImportBatch // nil unless RecordBatch was called
+	source   []byte             // The batch's source file, stored on Commit
+
+	// Products of the current chunk's SKUs, nil for SKUs that match none
+	found map[string]*model.Product
+	// Categories resolved for copied rows, by the text in the file
+	categories map[string]importCategory
+
+	categoriesCreated int
+}
+
+// ValidImportMode reports whether mode is one of the import modes
+func ValidImportMode(mode string) bool {
+	switch mode {
+	case model.ImportModeAppend, model.ImportModeUpsert, model.ImportModeReplace:
+		return true
+	}
+	return false
+}
+
+// BeginImport starts importing into a seller's catalog. The caller must
+// Commit or Rollback the import. Stock changes are logged as imports by userID.
+func (s *ProductService) BeginImport(ctx context.Context, sellerID int64, mode string, userID int64) (*ProductImport, error) {
+	if !ValidImportMode(mode) {
+		return nil, fmt.Errorf("unknown import mode %q", mode)
+	}
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	return &ProductImport{tx: tx, sellerID: sellerID, mode: mode, skus: map[string]bool{},
+		found: map[string]*model.Product{}, categories: map[string]importCategory{},
+		src: StockSource{Reason: model.StockReasonImport, UserID: userID}}, nil
+}
+
+// Keep records a SKU from a row that was rejected, so replace mode doesn't
+// deactivate a product just because its row had a typo
+func (imp *ProductImport) Keep(sku string) {
+	if sku != "" {
+		imp.skus[sku] = true
+	}
+}
+
+// claimSKU checks a row's SKU before it is applied: it is required to match
+// rows to products, and may appear only once in a file
+func (imp *ProductImport) claimSKU(p *model.Product) error {
+	if imp.mode != model.ImportModeAppend && p.SKU == "" {
+		return fmt.Errorf("sku is required in %s mode", imp.mode)
+	}
+	if p.SKU != "" {
+		if imp.skus[p.SKU] {
+			return fmt.Errorf("sku %q appears more than once in the file", p.SKU)
+		}
+		imp.skus[p.SKU] = true
+	}
+	return nil
+}
+
+// apply writes one row, whose SKU was claimed, to the catalog. In upsert and
+// replace mode the row is matched to an existing product by SKU; a matching
+// product whose fields are all equal is left untouched, so its embedding
+// stays valid. Only edits to the name, category or description invalidate
+// the embedding.
+func (imp *ProductImport) apply(ctx context.Context, p *model.Product) (model.ImportAction, error) {
+	p.SellerID = imp.sellerID
+	sp, err := imp.tx.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin savepoint: %v", err)
+	}
+	defer sp.Rollback(ctx)
+
+	action := model.ImportActionCreated
+	var existing *model.Product
+	var embeddings []embeddingSnapshot
+	if imp.mode != model.ImportModeAppend {
+		var ok bool
+		if existing, ok = imp.found[p.SKU]; !ok {
+			existing, err = scanProduct(sp.QueryRow(ctx, `
+				SELECT `+productColumns+`
+				FROM products
+				WHERE seller_id = $1 AND sku = $2 AND deleted_at IS NULL
+				FOR UPDATE`, imp.sellerID, p.SKU))
+			if errors.Is(err, pgx.ErrNoRows) {
+				existing, err = nil, nil
+			}
+			if err != nil {
+				return "", fmt.Errorf("failed to look up sku %q: %v", p.SKU, err)
+			}
+		}
+	}
+	// Updates may drop the product's vectors, so they are kept for a rollback
+	if imp.batch != nil && existing != nil {
+		if embeddings, err = productEmbeddings(ctx, sp, existing.ID); err != nil {
+			return "", err
+		}
+	}
+
+	// Map the row's category onto the tree first, so different spellings of
+	// the product's current category don't count as a change
+	created, err := assignCategory(ctx, sp, p)
+	if err != nil {
+		return "", err
+	}
+
+	if existing != nil {
+		// Blank cells keep what the product already has
+		if p.Attributes == nil {
+			p.Attributes = existing.Attributes
+		}
+		if p.Variants == nil {
+			p.Variants = existing.Variants
+		}
+		if p.Stock == nil {
+			p.Stock = existing.Stock
+		}
+	}
+
+	switch {
+	case existing == nil:
+		err = insertProduct(ctx, sp, p, imp.src)
+	case sameProductFields(existing, p):
+		action = model.ImportActionUnchanged
+		p.ID, p.CreatedAt, p.UpdatedAt = existing.ID, existing.CreatedAt, existing.UpdatedAt
+	default:
+		action = model.ImportActionUpdated
+		p.ID, p.CreatedAt = existing.ID, existing.CreatedAt
+		err = updateProduct(ctx, sp, p, imp.src)
+	}
+	if err != nil {
+		return "", err
+	}
+	if imp.batch != nil && action != model.ImportActionUnchanged {
+		if err := imp.recordItem(ctx, sp, action, existing, p, embeddings); err != nil {
+			return "", err
+		}
+	}
+
+	if err := sp.Commit(ctx); err != nil {
+		return "", fmt.Errorf("failed to release savepoint: %v", err)
+	}
+	imp.categoriesCreated += created
+	return action, nil
+}
+
+// CategoriesCreated is how many categories applied rows added to the tree
+func (imp *ProductImport) CategoriesCreated() int {
+	return imp.categoriesCreated
+}
+
+// AttachImages adds images to a product saved by ApplyRows, skipping any it
+// already has, and returns how many were added. The files are written to the
+// blob store right away, so they outlive a rolled back import; their keys
+// come from their content, so importing again reuses them.
+func (imp *ProductImport) AttachImages(ctx context.Context, productID int64, images [][]byte) (int, error) {
+	sp, err := imp.tx.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin savepoint: %v", err)
+	}
+	defer sp.Rollback(ctx)
+
+	var added []int64
+	for _, data := range images {
+		img, isNew, err := addProductImage(ctx, sp, Blobs(), productID, data)
+		if err != nil {
+			return 0, err
+		}
+		if isNew {
+			added = append(added, img.ID)
+		}
+	}
+	if imp.batch != nil && len(added) > 0 {
+		if err := imp.recordImages(ctx, sp, productID, added); err != nil {
+			return 0, err
+		}
+	}
+	if err := sp.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to release savepoint: %v", err)
+	}
+	return len(added), nil
+}
+
+// sameProductFields reports whether an import row would change nothing
+func sameProductFields(a, b *model.Product) bool {
+	if a.Name != b.Name || a.Category != b.Category || !reflect.DeepEqual(a.CategoryID, b.CategoryID) || a.Price != b.Price ||
+		a.Description != b.Description || a.IsAvailable != b.IsAvailable || !reflect.DeepEqual(a.Stock, b.Stock) ||
+		!reflect.DeepEqual(a.Attributes, b.Attributes) || len(a.Variants) != len(b.Variants) {
+		return false
+	}
+	for i := range a.Variants {
+		va, vb := a.Variants[i], b.Variants[i]
+		if va.Name != vb.Name || va.SKU != vb.SKU || va.Price != vb.Price || va.IsAvailable != vb.IsAvailable ||
+			!reflect.DeepEqual(va.Stock, vb.Stock) || len(va.Options) != len(vb.Options) {
+			return false
+		}
+		for key, value := range va.Options {
+			if vb.Options[key] != value {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// DeactivateMissing marks the seller's products that weren't in the file as
+// unavailable, in replace mode only. Products are kept rather than deleted,
+// so carts and analytics still resolve and a later import can reactivate them.
+func (imp *ProductImport) DeactivateMissing(ctx context.Context) (int, error) {
+	if imp.mode != model.ImportModeReplace {
+		return 0, nil
+	}
+	skus := make([]string, 0, len(imp.skus))
+	for sku := range imp.skus {
+		skus = append(skus, sku)
+	}
+	if imp.batch != nil {
+		return imp.deactivateRecorded(ctx, skus)
+	}
+	result, err := imp.tx.Exec(ctx, `
+		UPDATE products SET is_available = FALSE
+		WHERE seller_id = $1 AND deleted_at IS NULL AND is_available
+		AND (sku IS NULL OR NOT (sku = ANY($2)))`, imp.sellerID, skus)
+	if err != nil {
+		return 0, fmt.Errorf("failed to deactivate missing products: %v", err)
+	}
+	return int(result.RowsAffected()), nil
+}
+
+// Commit saves the import, then stores the source file of its batch
+func (imp *ProductImport) Commit(ctx context.Context) error {
+	if err := imp.tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit import: %v", err)
+	}
+	// Stored only now, so an import that rolls back leaves no file behind.
+	// The catalog changes stand even if this fails; the batch just has no
+	// file to download.
+	if imp.batch != nil {
+		if err := Blobs().Put(ctx, imp.batch.SourceKey, imp.source, "application/octet-stream"); err != nil {
+			fmt.Printf("Import batch %d: failed to store import file: %v\n", imp.batch.ID, err)
+		}
+	}
+	return nil
+}
+
+// Rollback discards the import. It is a no-op after Commit.
+func (imp *ProductImport) Rollback(ctx context.Context) {
+	imp.tx.Rollback(ctx)
+}
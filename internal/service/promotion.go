@@ -0,0 +1,294 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/divinecoid/oneagent/internal/db"
+	"github.com/divinecoid/oneagent/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrPromotionNotFound = errors.New("promotion not found")
+	ErrInvalidPromotion  = errors.New("invalid promotion")
+)
+
+const promotionColumns = `id, name, kind, value, buy_qty, get_qty, ends_at, seller_id, product_ids, category_ids,
+	days_of_week, timezone, starts_at, is_active, created_at, updated_at`
+
+func scanPromotion(row pgx.Row) (*model.Promotion, error) {
+	p := &model.Promotion{}
+	err := row.Scan(&p.ID, &p.Name, &p.Kind, &p.Value, &p.BuyQty, &p.GetQty, &p.EndsAt, &p.SellerID,
+		&p.ProductIDs, &p.CategoryIDs, &p.DaysOfWeek, &p.Timezone, &p.StartsAt, &p.IsActive, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// PromotionsJSON returns a scalar subquery aggregating the rules of the
+// promotions running now on the product whose ID is productID into a JSON
+// array of model.PromotionRule
+func PromotionsJSON(productID string) string {
+	return `(SELECT COALESCE(jsonb_agg(jsonb_build_object(
+		'id', pr.id, 'name', pr.name, 'kind', pr.kind, 'value', pr.value,
+		'buy_qty', pr.buy_qty, 'get_qty', pr.get_qty, 'ends_at', pr.ends_at
+	) ORDER BY pr.id), '[]') FROM product_promotions(` + productID + `) pr)`
+}
+
+// ValidatePromotion checks a promotion's rule, window and schedule before it
+// is saved. Scope IDs are checked against the seller when saving.
+func ValidatePromotion(p *model.Promotion) error {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s", ErrInvalidPromotion, fmt.Sprintf(format, args...))
+	}
+	switch {
+	case strings.TrimSpace(p.Name) == "":
+		return invalid("name is required")
+	case len([]rune(p.Name)) > 100:
+		return invalid("name is longer than 100 characters")
+	case p.Value < 0 || p.Value > 99999999.99:
+		return invalid("value is out of range")
+	case p.BuyQty < 0 || p.GetQty < 0:
+		return invalid("quantities can't be negative")
+	}
+	switch p.Kind {
+	case model.PromotionPercentage:
+		if p.Value <= 0 || p.Value > 100 {
+			return invalid("a percentage must be above 0 and at most 100")
+		}
+		p.BuyQty, p.GetQty = 0, 0
+	case model.PromotionFixed:
+		if p.Value <= 0 {
+			return invalid("a fixed discount must be above 0")
+		}
+		p.BuyQty, p.GetQty = 0, 0
+	case model.PromotionBundle:
+		if p.BuyQty < 2 || p.Value <= 0 {
+			return invalid("a bundle needs buy_qty of at least 2 and a bundle price")
+		}
+		p.GetQty = 0
+	case model.PromotionBuyXGetY:
+		if p.BuyQty < 1 || p.GetQty < 1 {
+			return invalid("buy_x_get_y needs buy_qty and get_qty of at least 1")
+		}
+		p.Value = 0
+	default:
+		return invalid("kind must be one of percentage, fixed, bundle, buy_x_get_y")
+	}
+
+	if p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt) {
+		return invalid("ends_at must be after starts_at")
+	}
+	seen := map[int]bool{}
+	for _, day := range p.DaysOfWeek {
+		if day < 1 || day > 7 {
+			return invalid("days_of_week are 1 (Monday) to 7 (Sunday)")
+		}
+		if seen[day] {
+			return invalid("day %d is listed more than once", day)
+		}
+		seen[day] = true
+	}
+	if p.Timezone == "" {
+		p.Timezone = "Asia/Jakarta"
+	}
+	if _, err := time.LoadLocation(p.Timezone); err != nil {
+		return invalid("unknown timezone %q", p.Timezone)
+	}
+	if p.ProductIDs == nil {
+		p.ProductIDs = []int64{}
+	}
+	if p.CategoryIDs == nil {
+		p.CategoryIDs = []int64{}
+	}
+	if p.DaysOfWeek == nil {
+		p.DaysOfWeek = []int{}
+	}
+	return nil
+}
+
+type PromotionService struct{}
+
+func NewPromotionService() *PromotionService {
+	return &PromotionService{}
+}
+
+// ListPromotions returns a seller's promotions, newest first, whether or not
+// they are running
+func (s *PromotionService) ListPromotions(ctx context.Context, sellerID int64) ([]*model.Promotion, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+promotionColumns+`
+		FROM promotions
+		WHERE seller_id = $1
+		ORDER BY created_at DESC, id DESC`, sellerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list promotions: %v", err)
+	}
+	defer rows.Close()
+
+	promotions := []*model.Promotion{}
+	for rows.Next() {
+		p, err := scanPromotion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan promotion: %v", err)
+		}
+		promotions = append(promotions, p)
+	}
+	return promotions, rows.Err()
+}
+
+func (s *PromotionService) GetPromotion(ctx context.Context, id int64) (*model.Promotion, error) {
+	p, err := scanPromotion(db.Pool.QueryRow(ctx, `SELECT `+promotionColumns+` FROM promotions WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrPromotionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get promotion: %v", err)
+	}
+	return p, nil
+}
+
+// CreatePromotion saves a new promotion. It applies to prices as soon as it
+// is active and within its window.
+func (s *PromotionService) CreatePromotion(ctx context.Context, p *model.Promotion) error {
+	if err := ValidatePromotion(p); err != nil {
+		return err
+	}
+	if err := checkPromotionScope(ctx, p); err != nil {
+		return err
+	}
+	err := db.Pool.QueryRow(ctx, `
+		INSERT INTO promotions (seller_id, name, kind, value, buy_qty, get_qty, product_ids, category_ids,
+			days_of_week, timezone, starts_at, ends_at, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id, created_at, updated_at`,
+		p.SellerID, p.Name, p.Kind, p.Value, p.BuyQty, p.GetQty, p.ProductIDs, p.CategoryIDs,
+		p.DaysOfWeek, p.Timezone, p.StartsAt, p.EndsAt, p.IsActive,
+	).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create promotion: %v", err)
+	}
+	return nil
+}
+
+// UpdatePromotion saves every editable field of a promotion
+func (s *PromotionService) UpdatePromotion(ctx context.Context, p *model.Promotion) error {
+	if err := ValidatePromotion(p); err != nil {
+		return err
+	}
+	if err := checkPromotionScope(ctx, p); err != nil {
+		return err
+	}
+	err := db.Pool.QueryRow(ctx, `
+		UPDATE promotions SET name = $2, kind = $3, value = $4, buy_qty = $5, get_qty = $6, product_ids = $7,
+			category_ids = $8, days_of_week = $9, timezone = $10, starts_at = $11, ends_at = $12, is_active = $13
+		WHERE id = $1
+		RETURNING updated_at`,
+		p.ID, p.Name, p.Kind, p.Value, p.BuyQty, p.GetQty, p.ProductIDs, p.CategoryIDs,
+		p.DaysOfWeek, p.Timezone, p.StartsAt, p.EndsAt, p.IsActive,
+	).Scan(&p.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrPromotionNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update promotion: %v", err)
+	}
+	return nil
+}
+
+func (s *PromotionService) DeletePromotion(ctx context.Context, id int64) error {
+	result, err := db.Pool.Exec(ctx, `DELETE FROM promotions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete promotion: %v", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrPromotionNotFound
+	}
+	return nil
+}
+
+// checkPromotionScope verifies that the products and categories a promotion
+// is limited to belong to its seller
+func checkPromotionScope(ctx context.Context, p *model.Promotion) error {
+	var products, categories int
+	err := db.Pool.QueryRow(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM products WHERE id = ANY($2) AND seller_id = $1 AND deleted_at IS NULL),
+			(SELECT COUNT(*) FROM categories WHERE id = ANY($3) AND seller_id = $1)`,
+		p.SellerID, p.ProductIDs, p.CategoryIDs,
+	).Scan(&products, &categories)
+	if err != nil {
+		return fmt.Errorf("failed to check promotion scope: %v", err)
+	}
+	if products != countDistinct(p.ProductIDs) {
+		return fmt.Errorf("%w: unknown product in product_ids", ErrInvalidPromotion)
+	}
+	if categories != countDistinct(p.CategoryIDs) {
+		return fmt.Errorf("%w: unknown category in category_ids", ErrInvalidPromotion)
+	}
+	return nil
+}
+
+func countDistinct(ids []int64) int {
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		seen[id] = true
+	}
+	return len(seen)
+}
+
+// recordPriceChange logs a product or variant price that differs from
+// before; before is nil for a new item
+func recordPriceChange(ctx context.Context, tx pgx.Tx, productID int64, variantID *int64, before *float64, after float64, src StockSource) error {
+	if before != nil && *before == after {
+		return nil
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO price_history (product_id, variant_id, variant_name, old_price, new_price, reason, created_by)
+		VALUES ($1, $2, (SELECT name FROM product_variants WHERE id = $2), $3, $4, $5, NULLIF($6, 0))`,
+		productID, variantID, before, after, priceChangeReason(src), src.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to record price change: %v", err)
+	}
+	return nil
+}
+
+// priceChangeReason is the price history reason for changes from src: price
+// history only tells imports from other edits
+func priceChangeReason(src StockSource) string {
+	if src.Reason == model.StockReasonImport {
+		return model.StockReasonImport
+	}
+	return model.StockReasonAdjustment
+}
+
+// ListPriceHistory returns the most recent price changes of a product and
+// its variants, newest first
+func (s *ProductService) ListPriceHistory(ctx context.Context, productID int64, limit int) ([]model.PriceChange, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT id, product_id, variant_id, COALESCE(variant_name, ''), old_price, new_price, reason, created_by, created_at
+		FROM price_history
+		WHERE product_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`, productID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list price history: %v", err)
+	}
+	defer rows.Close()
+
+	changes := []model.PriceChange{}
+	for rows.Next() {
+		var c model.PriceChange
+		if err := rows.Scan(&c.ID, &c.ProductID, &c.VariantID, &c.VariantName, &c.OldPrice, &c.NewPrice, &c.Reason,
+			&c.CreatedBy, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan price change: %v", err)
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
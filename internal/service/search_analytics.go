@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/divinecoid/oneagent/internal/db"
+	"github.com/divinecoid/oneagent/internal/model"
+)
+
+type SearchAnalyticsService struct{}
+
+func NewSearchAnalyticsService() *SearchAnalyticsService {
+	return &SearchAnalyticsService{}
+}
+
+// normalizeQuery lowercases a query and collapses whitespace so trivially
+// different spellings of the same search are grouped together
+func normalizeQuery(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+// LogSearch stores one search or chat retrieval
+func (s *SearchAnalyticsService) LogSearch(ctx context.Context, entry *model.SearchLog) error {
+	if entry.ResultIDs == nil {
+		entry.ResultIDs = []int64{}
+	}
+	err := db.Pool.QueryRow(ctx, `
+		INSERT INTO search_logs (
+			seller_id, user_id, source, query, normalized_query, mode,
+			result_ids, result_count, top_similarity, latency_ms
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at`,
+		entry.SellerID, entry.UserID, entry.Source, entry.Query, normalizeQuery(entry.Query), entry.Mode,
+		entry.ResultIDs, len(entry.ResultIDs), entry.TopSimilarity, entry.LatencyMs,
+	).Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to log search: %v", err)
+	}
+	return nil
+}
+
+const queryStatColumns = `
+	normalized_query,
+	COUNT(*),
+	AVG(result_count)::FLOAT8,
+	COUNT(*) FILTER (WHERE result_count = 0),
+	AVG(top_similarity)::FLOAT8,
+	AVG(latency_ms)::FLOAT8,
+	MAX(created_at)`
+
+func scanQueryStats(ctx context.Context, query string, args ...any) ([]model.QueryStat, error) {
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query search stats: %v", err)
+	}
+	defer rows.Close()
+
+	stats := []model.QueryStat{}
+	for rows.Next() {
+		var st model.QueryStat
+		if err := rows.Scan(&st.Query, &st.Searches, &st.AvgResults, &st.ZeroResults,
+			&st.AvgTopSimilarity, &st.AvgLatencyMs, &st.LastSearchedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan search stats: %v", err)
+		}
+		stats = append(stats, st)
+	}
+	return stats, rows.Err()
+}
+
+// TopQueries returns the seller's most frequent queries since the given time
+func (s *SearchAnalyticsService) TopQueries(ctx context.Context, sellerID int64, since time.Time, limit int) ([]model.QueryStat, error) {
+	return scanQueryStats(ctx, `
+		SELECT `+queryStatColumns+`
+		FROM search_logs
+		WHERE seller_id = $1 AND created_at >= $2
+		GROUP BY normalized_query
+		ORDER BY COUNT(*) DESC, MAX(created_at) DESC
+		LIMIT $3`, sellerID, since, limit)
+}
+
+// PoorResultQueries returns the seller's queries that came back with at most
+// maxResults products, or whose best match was weaker than minSimilarity
+// (0 disables the similarity check). These point at products the seller
+// doesn't carry or descriptions that don't use the customers' words.
+func (s *SearchAnalyticsService) PoorResultQueries(ctx context.Context, sellerID int64, since time.Time, maxResults int, minSimilarity float64, limit int) ([]model.QueryStat, error) {
+	return scanQueryStats(ctx, `
+		SELECT `+queryStatColumns+`
+		FROM search_logs
+		WHERE seller_id = $1 AND created_at >= $2
+		AND (result_count <= $3 OR ($4::FLOAT8 > 0 AND top_similarity < $4::FLOAT8))
+		GROUP BY normalized_query
+		ORDER BY COUNT(*) DESC, MAX(created_at) DESC
+		LIMIT $5`, sellerID, since, maxResults, minSimilarity, limit)
+}
+
+// UnretrievedProducts returns the seller's products that no logged search
+// or chat returned since the given time, oldest first
+func (s *SearchAnalyticsService) UnretrievedProducts(ctx context.Context, sellerID int64, since time.Time, limit int) ([]model.UnretrievedProduct, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT p.id, p.name, COALESCE(p.category, ''), p.price, p.created_at
+		FROM products p
+		WHERE p.seller_id = $1
+		AND p.deleted_at IS NULL
+		AND NOT EXISTS (
+			SELECT 1 FROM search_logs l
+			WHERE l.seller_id = $1
+			AND l.created_at >= $2
+			AND l.result_ids @> ARRAY[p.id]
+		)
+		ORDER BY p.created_at, p.id
+		LIMIT $3`, sellerID, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query unretrieved products: %v", err)
+	}
+	defer rows.Close()
+
+	products := []model.UnretrievedProduct{}
+	for rows.Next() {
+		var p model.UnretrievedProduct
+		if err := rows.Scan(&p.ID, &p.Name, &p.Category, &p.Price, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan product: %v", err)
+		}
+		products = append(products, p)
+	}
+	return products, rows.Err()
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+    "context"
     "github.com/gin-gonic/gin"
     "github.com/divinecoid/oneagent/internal/db"
+    "github.com/divinecoid/oneagent/internal/service"
     "log"
+    "os"
+    "strconv"
     apiv1 "github.com/divinecoid/oneagent/internal/api/v1"
 )
 
@@ -31,12 +35,20 @@ func main() {
     r.Use(apiv1.CORSMiddleware()) 
     r.SetTrustedProxies(nil) 
     v1 := r.Group("/api/v1")
-    apiv1.RegisterRoutes(v1)
+    jobService := service.NewJobService()
+    apiv1.RegisterRoutes(v1, jobService)
     
     if err := db.Connect(); err != nil {
         log.Fatal("DB connection failed:", err)
     }
 
+    // Start background job workers (imports, embedding runs)
+    workers, err := strconv.Atoi(os.Getenv("JOB_WORKERS"))
+    if err != nil || workers <= 0 {
+        workers = 2
+    }
+    jobService.Start(context.Background(), workers)
+
     r.Run(":8080")
 
 
@@ -78,7 +78,7 @@ func main() {
 
 	// Check products without embeddings
 	var nullEmbeddingCount int
-	err = pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM products WHERE embedding IS NULL`).Scan(&nullEmbeddingCount)
+	err = pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM products p WHERE NOT EXISTS (SELECT 1 FROM product_embeddings e WHERE e.product_id = p.id)`).Scan(&nullEmbeddingCount)
 	if err != nil {
 		fmt.Printf("Error counting products without embeddings: %v\n", err)
 		os.Exit(1)
@@ -91,8 +91,8 @@ func main() {
 		fmt.Println("\n📋 Sample products:")
 		rows, err := pool.Query(context.Background(), `
 			SELECT id, name, category, price, description, 
-			       CASE WHEN embedding IS NULL THEN 'NULL' ELSE 'SET' END as embedding_status
-			FROM products 
+			       CASE WHEN EXISTS (SELECT 1 FROM product_embeddings e WHERE e.product_id = p.id) THEN 'SET' ELSE 'NULL' END as embedding_status
+			FROM products p
 			LIMIT 5
 		`)
 		if err != nil {